@@ -0,0 +1,16 @@
+package apps
+
+import "github.com/jfortunato/serverpilot-tools/internal/filter"
+
+const filterUsage = `Only display apps matching the expression, e.g. 'runtime < 8.1 && server.name =~ "^web"'.
+Fields: id, name, sysuserid, runtime, created, domains, server.id, server.name, server.ip, server.ipv6, server.created, account.
+Functions: any(list, cond), all(list, cond), len(x), lower(s), startswith, endswith, contains.`
+
+// compileFilter compiles the --filter expression, or returns nil when it wasn't given.
+func compileFilter(expr string) (*filter.Expression, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	return filter.Compile(expr)
+}
@@ -1,22 +1,27 @@
 package apps
 
 import (
+	"context"
 	"fmt"
+	"github.com/jfortunato/serverpilot-tools/cmd/global"
+	"github.com/jfortunato/serverpilot-tools/internal/convert"
 	"github.com/jfortunato/serverpilot-tools/internal/dns"
 	"github.com/jfortunato/serverpilot-tools/internal/filter"
 	"github.com/jfortunato/serverpilot-tools/internal/progressbar"
-	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
-	"github.com/jfortunato/serverpilot-tools/internal/servers"
+	"github.com/jfortunato/serverpilot-tools/pkg/inactive"
+	"github.com/jfortunato/serverpilot-tools/pkg/serverpilot"
 	"github.com/spf13/cobra"
-	"io"
-	"log"
 	"os"
+	"strings"
 	"text/tabwriter"
 )
 
 type inactiveOptions struct {
 	verbose        bool
 	includeUnknown bool
+	byApp          bool
+	explain        bool
+	filter         string
 }
 
 func newInactiveCommand() *cobra.Command {
@@ -28,127 +33,222 @@ func newInactiveCommand() *cobra.Command {
 		Long: `Check for inactive (stranded) apps. An app is considered inactive
   if it exists on the server but does not have DNS records pointing to it.
   This makes it easy to find apps that are no longer in use or have migrated
-  away and can be deleted.`,
-		Args: cobra.ExactArgs(2),
+  away and can be deleted. Domains that point to the server and to other
+  addresses are reported as partial, along with the foreign IPs. Use
+  --explain to see each step taken to determine the status of every domain
+  that isn't ok, including the unknown ones even without -u. CNAME hops are
+  only shown for records looked up with the Cloudflare API, since other
+  lookups follow CNAMEs within the resolver.`,
+		Args: global.CredentialsArgs,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runInactive(args[0], args[1], options)
+			return runInactive(cmd.Context(), args, options)
 		},
 	}
 
 	flags := cmd.Flags()
 	flags.BoolVarP(&options.verbose, "verbose", "v", false, "Verbose output")
 	flags.BoolVarP(&options.includeUnknown, "include-unknown", "u", false, "Include domains with unknown status")
+	flags.StringVar(&options.filter, "filter", "", filterUsage)
+	flags.BoolVar(&options.byApp, "by-app", false, "Show the status of each app, rolled up from its domains, instead of each domain")
+	flags.BoolVar(&options.explain, "explain", false, "Show how the status of each domain that isn't ok (including unknown ones) was determined, as a tree")
 
 	return cmd
 }
 
-func runInactive(user, key string, options inactiveOptions) error {
-	logger := createLogger(options.verbose)
-	cfChecker := dns.NewCloudflareCredentialsChecker(logger, &dns.Prompter{}, nil)
-	dnsChecker := createDomainChecker(logger, cfChecker)
+func runInactive(ctx context.Context, args []string, options inactiveOptions) error {
+	expr, err := compileFilter(options.filter)
+	if err != nil {
+		return err
+	}
+
+	logger := global.NewLogger(options.verbose)
 
-	apps, err := getAppServers(logger, user, key)
+	accounts, err := global.Accounts(args, logger)
 	if err != nil {
 		return err
 	}
 
-	// Transform the list of AppServers into a list of all their domains
-	domains := getAllDomains(apps)
+	apps, err := global.FetchAppServers(ctx, accounts)
+	if err != nil {
+		return err
+	}
 
-	bar := progressbar.NewProgressBar(len(domains), "Evaluating domains")
+	// Only check the apps that match the filter
+	apps, err = filterApps(apps, expr)
+	if err != nil {
+		return err
+	}
 
-	// Evaluate all domains, and determine if they are behind Cloudflare
-	unresolvedDomains := dnsChecker.EvaluateDomains(bar, domains)
+	// Only look up the sysusers when we need their names
+	var sysuserNames map[string]string
+	if options.byApp {
+		sysuserNames, err = fetchSysuserNames(ctx, accounts)
+		if err != nil {
+			return err
+		}
+	}
 
-	bar.Finish()
+	// The apps of every account are checked together, so each Cloudflare account is only prompted for once. Prompt
+	// for Cloudflare credentials for each unique account discovered, showing progress while the domains are
+	// evaluated and checked
+	checkerOptions := []inactive.Option{
+		inactive.WithLogger(logger),
+		inactive.WithPrompter(&dns.Prompter{}),
+		inactive.WithProgress(newProgress),
+		inactive.WithCache(global.CacheSettings()),
+		inactive.WithRetry(global.RetrySettings()),
+		inactive.WithTransport(global.Transport()),
+		inactive.WithCloudflareUrl(global.CloudflareUrl()),
+		inactive.WithLookups(inactive.IPLookupFunc(global.IpLookup()), inactive.NSLookupFunc(global.NsLookup())),
+	}
+	if options.explain {
+		checkerOptions = append(checkerOptions, inactive.WithExplain())
+	}
+	checker := inactive.NewChecker(checkerOptions...)
 
-	// Prompt for Cloudflare credentials for each unique account discovered
-	unresolvedDomains = cfChecker.PromptForCredentials(unresolvedDomains)
+	// When interrupted, we still print the domains that were checked
+	statuses, checkErr := checker.Check(ctx, apps)
+
+	if options.byApp {
+		byApp := inactive.FilterInactiveApps(inactive.ByApp(statuses, apps), options.includeUnknown)
+		if err := printAppStatuses(byApp, sysuserNames, global.MultiAccount()); err != nil {
+			return err
+		}
+		if options.explain {
+			printTraces(inactive.FilterInactive(statuses, true))
+		}
+		return checkErr
+	}
 
-	// Resolve the UnresolvedDomains, and determine if they are pointing to the correct server
 	// Only print out the inactive apps by default, but allow the user to include unknown domains with a flag
-	bar = progressbar.NewProgressBar(len(unresolvedDomains), "Checking domains")
-	filtered := dnsChecker.GetInactiveAppDomains(bar, unresolvedDomains, apps, options.includeUnknown)
-	bar.Finish()
-	bar.Clear()
+	filtered := inactive.FilterInactive(statuses, options.includeUnknown)
 
 	// Print out the inactive apps, with their status (INACTIVE/PARTIAL/UNKNOWN)
-	return printDomains(filtered)
-}
-
-func getAppServers(logger *log.Logger, user, key string) ([]serverpilot.AppServer, error) {
-	c := serverpilot.NewClient(logger, user, key)
-
-	// Get all servers, and extract their ip addresses
-	srvers, err := servers.GetServers(c)
-	if err != nil {
-		return nil, fmt.Errorf("error while getting servers: %w", err)
+	if err := printDomains(filtered, apps, global.MultiAccount()); err != nil {
+		return err
 	}
 
-	// Get all ServerPilot apps
-	apps, err := filter.FilterApps(c, "", "", 0, 0)
-	if err != nil {
-		return nil, fmt.Errorf("error while getting apps: %w", err)
+	// Unknown domains are the ones most in need of an explanation, so they are always explained
+	if options.explain {
+		printTraces(inactive.FilterInactive(statuses, true))
 	}
 
-	var appServers []serverpilot.AppServer
+	return checkErr
+}
 
-	// Add the matching server to each app
+// filterApps only returns the apps that match the filter expression. A nil expression matches everything.
+func filterApps(apps []serverpilot.AppServer, expr *filter.Expression) ([]serverpilot.AppServer, error) {
+	if expr == nil {
+		return apps, nil
+	}
+
+	var filtered []serverpilot.AppServer
 	for _, app := range apps {
-		server := getServerForApp(app, srvers)
-		appServers = append(appServers, serverpilot.AppServer{app, server})
+		ok, err := expr.Match(convert.AppServer(app))
+		if err != nil {
+			return nil, fmt.Errorf("app %s (%s): %w", app.Name, app.Id, err)
+		}
+		if ok {
+			filtered = append(filtered, app)
+		}
 	}
 
-	return appServers, nil
+	return filtered, nil
 }
 
-func getAllDomains(appServers []serverpilot.AppServer) []string {
-	var domains []string
-	for _, appServer := range appServers {
-		domains = append(domains, appServer.Domains...)
-	}
-	return domains
+// progress adapts a progress bar to an inactive.Progress
+type progress struct {
+	*progressbar.ProgressBar
 }
 
-func createLogger(isVerbose bool) *log.Logger {
-	logger := log.New(io.Discard, "", 0)
-	if isVerbose {
-		logger.SetOutput(os.Stdout)
-	}
-	return logger
+func newProgress(stage string, total int) inactive.Progress {
+	return progress{progressbar.NewProgressBar(total, stage)}
 }
 
-func createDomainChecker(logger *log.Logger, checker *dns.CloudflareCredentialsChecker) *dns.DnsChecker {
-	return dns.NewDnsChecker(dns.NewResolver(nil, checker, nil, logger), checker)
+func (p progress) Done() {
+	p.Finish()
+	p.Clear()
 }
 
-func getServerForApp(app serverpilot.App, servers []serverpilot.Server) serverpilot.Server {
-	for _, server := range servers {
-		if server.Id == app.Serverid {
-			return server
-		}
+func printDomains(domains []inactive.DomainStatus, apps []serverpilot.AppServer, showAccount bool) error {
+	accounts := make(map[string]string)
+	for _, app := range apps {
+		accounts[app.Id] = app.Account
 	}
 
-	return serverpilot.Server{}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	if showAccount {
+		fmt.Fprint(w, "ACCOUNT\t")
+	}
+	fmt.Fprintln(w, "APP ID\tDOMAIN\tSERVER\tSTATUS\tIPV4\tIPV6\tFOREIGN IPS\t")
+	for _, domain := range domains {
+		if showAccount {
+			fmt.Fprint(w, accounts[domain.AppId]+"\t")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", domain.AppId, domain.Domain, domain.ServerName, statusName(domain.Status), domain.IPv4, domain.IPv6, strings.Join(domain.ForeignIps, ","))
+	}
+	return w.Flush()
 }
 
-func printDomains(domains []dns.AppDomainStatus) error {
+func printAppStatuses(apps []inactive.AppStatus, sysuserNames map[string]string, showAccount bool) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
-	fmt.Fprintln(w, "APP ID\tDOMAIN\tSERVER\tSTATUS\t")
-	for _, domain := range domains {
-		stringStatus := ""
-		switch domain.Status {
-		case dns.OK:
-			stringStatus = "ok"
-		case dns.INACTIVE:
-			stringStatus = "inactive"
-		case dns.UNKNOWN:
-			stringStatus = "unknown"
+	if showAccount {
+		fmt.Fprint(w, "ACCOUNT\t")
+	}
+	fmt.Fprintln(w, "APP ID\tAPP\tSYSUSER\tRUNTIME\tSERVER\tSTATUS\tOK\tPARTIAL\tINACTIVE\tUNKNOWN\t")
+	for _, app := range apps {
+		if showAccount {
+			fmt.Fprint(w, app.Account+"\t")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t", app.Id, app.Name, sysuserNames[app.Sysuserid], app.Runtime, app.Server.Name, statusName(app.Status))
+		for _, status := range []int{inactive.OK, inactive.PARTIAL, inactive.INACTIVE, inactive.UNKNOWN} {
+			fmt.Fprint(w, strings.Join(app.DomainsByStatus[status], ",")+"\t")
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", domain.AppId, domain.Domain, domain.ServerName, stringStatus)
+		fmt.Fprintln(w)
 	}
 	return w.Flush()
 }
+
+// printTraces prints the trace of each domain, separated by blank lines.
+func printTraces(domains []inactive.DomainStatus) {
+	for _, domain := range domains {
+		if domain.Trace != nil {
+			fmt.Print("\n" + domain.Trace.String())
+		}
+	}
+}
+
+func statusName(status int) string {
+	switch status {
+	case inactive.OK:
+		return "ok"
+	case inactive.INACTIVE:
+		return "inactive"
+	case inactive.UNKNOWN:
+		return "unknown"
+	case inactive.PARTIAL:
+		return "partial"
+	}
+	return ""
+}
+
+// fetchSysuserNames returns the name of every sysuser in the accounts, by id.
+func fetchSysuserNames(ctx context.Context, accounts []global.Account) (map[string]string, error) {
+	users, err := global.FetchAll(ctx, accounts, func(ctx context.Context, a global.Account) ([]serverpilot.Sysuser, error) {
+		return a.Client.Sysusers(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error while getting sysusers: %w", err)
+	}
+
+	names := make(map[string]string)
+	for _, result := range users {
+		for _, user := range result {
+			names[user.Id] = user.Name
+		}
+	}
+	return names, nil
+}
@@ -1,77 +1,301 @@
 package apps
 
 import (
+	"context"
 	"fmt"
+	"github.com/jfortunato/serverpilot-tools/cmd/global"
+	"github.com/jfortunato/serverpilot-tools/internal/config"
+	"github.com/jfortunato/serverpilot-tools/internal/convert"
 	"github.com/jfortunato/serverpilot-tools/internal/filter"
-	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	model "github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"github.com/jfortunato/serverpilot-tools/pkg/serverpilot"
 	"github.com/spf13/cobra"
 	"io"
 	"log"
 	"os"
 	"strings"
 	"text/tabwriter"
+	"time"
 )
 
-var MinRuntime string
-var MaxRuntime string
-var CreatedAfter string
-var CreatedBefore string
+type listOptions struct {
+	runtime       string
+	minRuntime    string
+	maxRuntime    string
+	createdAfter  string
+	createdBefore string
+	createdWithin string
+	olderThan     string
+	filter        string
+	sortBy        string
+	groupBy       string
+	columns       []string
+	view          string
+}
 
 func newListCommand() *cobra.Command {
+	options := listOptions{}
+
 	cmd := &cobra.Command{
 		Use:     "list [OPTIONS]",
 		Aliases: []string{"ls"},
 		Short:   "List apps",
-		Args:    cobra.ExactArgs(2),
+		Args:    global.CredentialsArgs,
 		//PreRunE: func(cmd *cobra.Command, args []string) error {
 		//	// Validate here?
 		//},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			minRuntime := serverpilot.Runtime(MinRuntime)
-			maxRuntime := serverpilot.Runtime(MaxRuntime)
-			createdAfter, err := serverpilot.DateCreatedFromDate(CreatedAfter)
-			if err != nil {
-				return fmt.Errorf("created-after must be in the format YYYY-MM-DD")
-			}
-			createdBefore, err := serverpilot.DateCreatedFromDate(CreatedBefore)
-			if err != nil {
-				return fmt.Errorf("created-before must be in the format YYYY-MM-DD")
+			if options.view != "" {
+				if err := applyView(cmd, &options); err != nil {
+					return err
+				}
 			}
 
-			listApps(args[0], args[1], minRuntime, maxRuntime, createdAfter, createdBefore)
-
-			return nil
+			return runList(cmd.Context(), args, options)
 		},
 	}
 
 	flags := cmd.Flags()
-	flags.StringVar(&MinRuntime, "min-runtime", "", "Only display apps with a runtime greater than or equal to the specified runtime")
-	flags.StringVar(&MaxRuntime, "max-runtime", "", "Only display apps with a runtime less than or equal to the specified runtime")
-	flags.StringVar(&CreatedAfter, "created-after", "", "Only display apps created after the specified date")
-	flags.StringVar(&CreatedBefore, "created-before", "", "Only display apps created before the specified date")
+	flags.StringVar(&options.runtime, "runtime", "", "Only display apps with a runtime in the specified range, e.g. \">=7.4 <8.1\" or \"8.x\"")
+	flags.StringVar(&options.minRuntime, "min-runtime", "", "Only display apps with a runtime greater than or equal to the specified runtime")
+	flags.StringVar(&options.maxRuntime, "max-runtime", "", "Only display apps with a runtime less than or equal to the specified runtime")
+	flags.StringVar(&options.createdAfter, "created-after", "", "Only display apps created after the specified date")
+	flags.StringVar(&options.createdBefore, "created-before", "", "Only display apps created before the specified date")
+	flags.StringVar(&options.createdWithin, "created-within", "", "Only display apps created within the specified time, e.g. 90d (units are h, d, w, m, y)")
+	flags.StringVar(&options.olderThan, "older-than", "", "Only display apps created longer ago than the specified time, e.g. 2y (units are h, d, w, m, y)")
+	flags.StringVar(&options.filter, "filter", "", filterUsage)
+	flags.StringVar(&options.sortBy, "sort-by", "", "Sort apps by "+strings.Join(filter.SortFields, "|"))
+	flags.StringVar(&options.groupBy, "group-by", "", "Group apps by "+strings.Join(filter.GroupFields, "|")+", with a subtotal for each group")
+	flags.StringSliceVar(&options.columns, "columns", nil, "Comma separated columns to display ("+strings.Join(filter.Columns, ", ")+")")
+	flags.StringVar(&options.view, "view", "", "Use the filter, sort, grouping, columns, runtimes and dates of a saved view (see 'views list'). Other flags take precedence")
 
 	return cmd
 }
 
-func listApps(user, key string, minRuntime, maxRuntime serverpilot.Runtime, createdAfter, createdBefore serverpilot.DateCreated) {
+func runList(ctx context.Context, args []string, options listOptions) error {
+	runtimes, err := serverpilot.ParseRuntimeRange(options.runtime)
+	if err != nil {
+		return fmt.Errorf("runtime must be a version range such as \">=7.4 <8.1\" or \"8.x\": %w", err)
+	}
+	bounds, err := serverpilot.RuntimeRangeFromBounds(serverpilot.Runtime(options.minRuntime), serverpilot.Runtime(options.maxRuntime))
+	if err != nil {
+		return fmt.Errorf("min-runtime and max-runtime must be in the format phpX.Y: %w", err)
+	}
+	createdAfter, err := serverpilot.DateCreatedFromDate(options.createdAfter)
+	if err != nil {
+		return fmt.Errorf("created-after must be in the format YYYY-MM-DD")
+	}
+	createdBefore, err := serverpilot.DateCreatedFromDate(options.createdBefore)
+	if err != nil {
+		return fmt.Errorf("created-before must be in the format YYYY-MM-DD")
+	}
+
+	now := time.Now()
+	createdWithin, err := serverpilot.DateCreatedFromRelative(options.createdWithin, now)
+	if err != nil {
+		return fmt.Errorf("created-within must be a number followed by a unit, e.g. 90d: %w", err)
+	}
+	olderThan, err := serverpilot.DateCreatedFromRelative(options.olderThan, now)
+	if err != nil {
+		return fmt.Errorf("older-than must be a number followed by a unit, e.g. 2y: %w", err)
+	}
+
+	// When both an absolute and relative date are given, use whichever is the narrowest
+	if createdWithin > createdAfter {
+		createdAfter = createdWithin
+	}
+	if olderThan != 0 && (createdBefore == 0 || olderThan < createdBefore) {
+		createdBefore = olderThan
+	}
+
+	expr, err := compileFilter(options.filter)
+	if err != nil {
+		return err
+	}
+
+	columns := options.columns
+	if len(columns) == 0 {
+		columns = filter.DefaultColumns
+	}
+	if err := filter.ValidateColumns(columns); err != nil {
+		return err
+	}
+
+	// When querying several accounts, show which account each app belongs to
+	if global.MultiAccount() && !hasColumn(columns, "account") {
+		columns = append([]string{"account"}, columns...)
+	}
+
 	logger := log.New(io.Discard, "", 0)
 
-	c := serverpilot.NewClient(logger, user, key)
+	accounts, err := global.Accounts(args, logger)
+	if err != nil {
+		return err
+	}
+
+	// The apps are filtered, sorted and printed with the internal types the filter expressions are evaluated on
+	results, err := global.FetchAll(ctx, accounts, func(ctx context.Context, a global.Account) ([]model.AppServer, error) {
+		apps, err := a.Client.FilterApps(ctx, runtimes.Intersect(bounds), createdAfter, createdBefore)
+		if err != nil {
+			return nil, fmt.Errorf("error while filtering apps: %w", err)
+		}
+
+		srvers, err := a.Client.Servers(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error while getting servers: %w", err)
+		}
+
+		appServers := serverpilot.JoinAppServers(apps, srvers)
+		for i := range appServers {
+			appServers[i].Account = a.Name
+		}
+
+		return convert.AppServers(appServers), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var appServers []model.AppServer
+	for _, result := range results {
+		appServers = append(appServers, result...)
+	}
+
+	appServers, err = filter.FilterAppServers(appServers, expr)
+	if err != nil {
+		return err
+	}
+
+	if err := filter.SortAppServers(appServers, options.sortBy); err != nil {
+		return err
+	}
+
+	if options.groupBy == "" {
+		return printApps(appServers, columns)
+	}
+
+	// Only look up the sysusers when we need their names
+	sysuserNames := make(map[string]string)
+	if options.groupBy == "sysuser" {
+		sysuserNames, err = fetchSysuserNames(ctx, accounts)
+		if err != nil {
+			return err
+		}
+	}
+
+	groups, err := filter.GroupAppServers(appServers, options.groupBy, sysuserNames)
+	if err != nil {
+		return err
+	}
+
+	return printGroupedApps(groups, columns)
+}
+
+// applyView fills in any options that weren't given as flags from the saved view.
+func applyView(cmd *cobra.Command, options *listOptions) error {
+	path, err := config.Path()
+	if err != nil {
+		return err
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		return err
+	}
 
-	apps, err := filter.FilterApps(c, minRuntime, maxRuntime, createdAfter, createdBefore)
+	view, err := c.View(options.view)
 	if err != nil {
-		log.Fatalln("error while filtering apps: ", err)
+		return err
+	}
+
+	flags := cmd.Flags()
+	if !flags.Changed("filter") {
+		options.filter = view.Filter
+	}
+	if !flags.Changed("sort-by") {
+		options.sortBy = view.SortBy
+	}
+	if !flags.Changed("group-by") {
+		options.groupBy = view.GroupBy
+	}
+	if !flags.Changed("columns") {
+		options.columns = view.Columns
+	}
+	if !flags.Changed("runtime") {
+		options.runtime = view.Runtime
+	}
+	if !flags.Changed("min-runtime") {
+		options.minRuntime = view.MinRuntime
+	}
+	if !flags.Changed("max-runtime") {
+		options.maxRuntime = view.MaxRuntime
+	}
+	if !flags.Changed("created-after") {
+		options.createdAfter = view.CreatedAfter
+	}
+	if !flags.Changed("created-before") {
+		options.createdBefore = view.CreatedBefore
+	}
+	if !flags.Changed("created-within") {
+		options.createdWithin = view.CreatedWithin
+	}
+	if !flags.Changed("older-than") {
+		options.olderThan = view.OlderThan
 	}
 
-	printApps(apps)
+	return nil
 }
 
-func printApps(apps []serverpilot.App) {
+func printApps(apps []model.AppServer, columns []string) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
-	fmt.Fprintln(w, "ID\tNAME\tSERVER\tDOMAINS\tRUNTIME\tCREATED\t")
+	printHeader(w, columns)
 	for _, app := range apps {
-		domains := strings.Join(app.Domains, ", ")
-		fmt.Fprintln(w, app.Id+"\t"+app.Name+"\t"+app.Serverid+"\t"+domains+"\t"+string(app.Runtime)+"\t"+app.Datecreated.String()+"\t")
+		printAppRow(w, app, columns)
+	}
+	return w.Flush()
+}
+
+func printGroupedApps(groups []filter.AppGroup, columns []string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	printHeader(w, columns)
+
+	totalApps, totalDomains := 0, 0
+	for _, group := range groups {
+		domains := 0
+		for _, app := range group.Apps {
+			printAppRow(w, app, columns)
+			domains += len(app.Domains)
+		}
+		fmt.Fprintf(w, "SUBTOTAL\t%s\t%d apps\t%d domains\t\n", group.Key, len(group.Apps), domains)
+		fmt.Fprintln(w, "\t")
+
+		totalApps += len(group.Apps)
+		totalDomains += domains
+	}
+	fmt.Fprintf(w, "TOTAL\t\t%d apps\t%d domains\t\n", totalApps, totalDomains)
+
+	return w.Flush()
+}
+
+func printHeader(w io.Writer, columns []string) {
+	for _, column := range columns {
+		fmt.Fprint(w, strings.ToUpper(column)+"\t")
+	}
+	fmt.Fprintln(w)
+}
+
+func printAppRow(w io.Writer, app model.AppServer, columns []string) {
+	for _, column := range columns {
+		fmt.Fprint(w, filter.ColumnValue(app, column)+"\t")
+	}
+	fmt.Fprintln(w)
+}
+
+func hasColumn(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
 	}
-	w.Flush()
+	return false
 }
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+type clearOptions struct {
+	host string
+}
+
+func newClearCommand() *cobra.Command {
+	options := clearOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "clear [OPTIONS]",
+		Short: "Remove cached responses",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed, err := store().Clear(options.host)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Removed %d cached responses.\n", removed)
+
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.host, "host", "", "Only remove responses from this host, e.g. api.cloudflare.com")
+
+	return cmd
+}
@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"github.com/jfortunato/serverpilot-tools/cmd/global"
+	"github.com/jfortunato/serverpilot-tools/internal/http"
+	"github.com/spf13/cobra"
+)
+
+func NewCacheCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache COMMAND",
+		Short: "Manage the cache of API responses",
+		Long: `Manage the cache of API responses. Responses from the ServerPilot and
+  Cloudflare APIs are cached for 24 hours by default (see --cache-ttl), so
+  running several commands in a row doesn't hit the API rate limits.`,
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(
+		newStatsCommand(),
+		newClearCommand(),
+		newWarmCommand(),
+	)
+
+	return cmd
+}
+
+func store() *http.FileStore {
+	return global.CacheStore()
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+func newStatsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show the number and size of cached responses",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stats, err := store().Stats()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Directory: %s\n", stats.Dir)
+			fmt.Printf("Entries:   %d (%d expired)\n", stats.Entries, stats.Expired)
+			fmt.Printf("Size:      %.1f KB\n", float64(stats.Size)/1024)
+			if stats.Entries > 0 {
+				fmt.Printf("Oldest:    %s\n", stats.Oldest.Format(time.DateTime))
+				fmt.Printf("Newest:    %s\n", stats.Newest.Format(time.DateTime))
+			}
+
+			if len(stats.Hosts) == 0 {
+				return nil
+			}
+
+			var hosts []string
+			for host := range stats.Hosts {
+				hosts = append(hosts, host)
+			}
+			sort.Strings(hosts)
+
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+			fmt.Fprintln(w, "HOST\tENTRIES\t")
+			for _, host := range hosts {
+				fmt.Fprintf(w, "%s\t%d\t\n", host, stats.Hosts[host])
+			}
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/cmd/global"
+	"github.com/jfortunato/serverpilot-tools/pkg/httpclient"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+)
+
+func newWarmCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "warm [OPTIONS]",
+		Short: "Fetch and cache the apps, servers, sysusers and databases of an account",
+		Long: `Fetch and cache the apps, servers, sysusers and databases of an account,
+  so the following commands don't need to wait on the API. Responses that are
+  already cached are kept, unless --refresh is given.`,
+		Args: global.CredentialsArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if global.CacheSettings().Mode == httpclient.CacheOff {
+				return errors.New("the cache can't be warmed with --no-cache, --record or --replay")
+			}
+
+			accounts, err := global.Accounts(args, log.New(io.Discard, "", 0))
+			if err != nil {
+				return err
+			}
+
+			counts, err := global.FetchAll(cmd.Context(), accounts, warm)
+			if err != nil {
+				return err
+			}
+
+			for i, account := range accounts {
+				if account.Name != "" {
+					fmt.Print(account.Name + ": ")
+				}
+				fmt.Println(counts[i])
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func warm(ctx context.Context, a global.Account) (string, error) {
+	apps, err := a.Client.Apps(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error while getting apps: %w", err)
+	}
+
+	servers, err := a.Client.Servers(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error while getting servers: %w", err)
+	}
+
+	users, err := a.Client.Sysusers(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error while getting sysusers: %w", err)
+	}
+
+	dbs, err := a.Client.Databases(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error while getting databases: %w", err)
+	}
+
+	return fmt.Sprintf("Cached %d apps, %d servers, %d sysusers and %d databases.", len(apps), len(servers), len(users), len(dbs)), nil
+}
@@ -0,0 +1,17 @@
+package domains
+
+import "github.com/spf13/cobra"
+
+func NewDomainsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "domains COMMAND",
+		Short: "Manage domains",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(
+		newConflictsCommand(),
+	)
+
+	return cmd
+}
@@ -0,0 +1,121 @@
+package domains
+
+import (
+	"context"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/cmd/global"
+	"github.com/jfortunato/serverpilot-tools/internal/convert"
+	"github.com/jfortunato/serverpilot-tools/internal/dns"
+	"github.com/jfortunato/serverpilot-tools/internal/progressbar"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+type conflictsOptions struct {
+	verbose bool
+}
+
+func newConflictsCommand() *cobra.Command {
+	options := conflictsOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "conflicts [OPTIONS]",
+		Short: "Find domains that are attached to more than one app",
+		Long: `Find domains that are attached to more than one app. The www. and apex
+  variants of a domain are treated as the same domain. Each copy is checked
+  against public DNS (or the Cloudflare API) to determine which app is live,
+  and which ones are stale and can be removed.`,
+		Args: global.CredentialsArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConflicts(cmd.Context(), args, options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVarP(&options.verbose, "verbose", "v", false, "Verbose output")
+
+	return cmd
+}
+
+func runConflicts(ctx context.Context, args []string, options conflictsOptions) error {
+	logger := global.NewLogger(options.verbose)
+	cfChecker := dns.NewCloudflareCredentialsChecker(logger, &dns.Prompter{}, global.NsLookup())
+	dnsChecker := dns.NewDnsChecker(dns.NewResolver(nil, cfChecker, global.IpLookup(), logger, global.CloudflareSettings()), cfChecker)
+
+	accounts, err := global.Accounts(args, logger)
+	if err != nil {
+		return err
+	}
+
+	// Domains can conflict across accounts too, so look for conflicts in all the apps at once
+	apps, err := global.FetchAppServers(ctx, accounts)
+	if err != nil {
+		return err
+	}
+
+	conflicts := dns.FindDomainConflicts(convert.AppServers(apps))
+	if len(conflicts) == 0 {
+		fmt.Println("No conflicting domains found.")
+		return nil
+	}
+
+	domains := dns.ConflictDomains(conflicts)
+
+	bar := progressbar.NewProgressBar(len(domains), "Evaluating domains")
+	unresolvedDomains, err := dnsChecker.EvaluateDomains(ctx, bar, domains)
+	bar.Finish()
+	if err != nil {
+		return err
+	}
+
+	// Prompt for Cloudflare credentials for each unique account discovered
+	unresolvedDomains = cfChecker.PromptForCredentials(unresolvedDomains)
+
+	total := 0
+	for _, conflict := range conflicts {
+		total += len(conflict.Copies)
+	}
+
+	bar = progressbar.NewProgressBar(total, "Checking domains")
+	// When interrupted, we still print the conflicts that were checked
+	conflicts, checkErr := dnsChecker.CheckConflicts(ctx, bar, conflicts, unresolvedDomains)
+	bar.Finish()
+	bar.Clear()
+
+	if err := printConflicts(conflicts, global.MultiAccount()); err != nil {
+		return err
+	}
+
+	return checkErr
+}
+
+func printConflicts(conflicts []dns.DomainConflict, showAccount bool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprint(w, "DOMAIN\t")
+	if showAccount {
+		fmt.Fprint(w, "ACCOUNT\t")
+	}
+	fmt.Fprintln(w, "APP ID\tAPP\tSERVER\tSTATUS\t")
+	for _, conflict := range conflicts {
+		for _, c := range conflict.Copies {
+			stringStatus := ""
+			switch c.Status {
+			case dns.OK:
+				stringStatus = "live"
+			case dns.INACTIVE:
+				stringStatus = "stale"
+			case dns.UNKNOWN:
+				stringStatus = "unknown"
+			case dns.PARTIAL:
+				stringStatus = "partially live"
+			}
+			fmt.Fprint(w, c.Domain+"\t")
+			if showAccount {
+				fmt.Fprint(w, c.AppServer.Account+"\t")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", c.AppServer.Id, c.AppServer.Name, c.AppServer.Server.Name, stringStatus)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,387 @@
+package global
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/config"
+	"github.com/jfortunato/serverpilot-tools/internal/dns"
+	"github.com/jfortunato/serverpilot-tools/internal/http"
+	"github.com/jfortunato/serverpilot-tools/internal/replay"
+	"github.com/jfortunato/serverpilot-tools/pkg/httpclient"
+	"github.com/jfortunato/serverpilot-tools/pkg/inactive"
+	"github.com/jfortunato/serverpilot-tools/pkg/serverpilot"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	nethttp "net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// The options shared by every command, which are set with persistent flags on the root command.
+var (
+	profiles    []string
+	allProfiles bool
+	noCache     bool
+	refresh     bool
+	cacheTTL    time.Duration
+	cacheDir    string
+
+	serverPilotRate float64
+	cloudflareRate  float64
+	retries         int
+
+	timeout time.Duration
+
+	serverPilotUrl string
+	cloudflareUrl  string
+	proxy          string
+	caBundle       string
+
+	resolvers     []string
+	authoritative bool
+	dnsTransport  string
+	dohUrl        string
+	dohFormat     string
+	// lookups makes the DNS lookups with the --resolver or --authoritative nameservers, or over DNS-over-HTTPS, and
+	// is nil when using the system resolver.
+	lookups dns.Lookups
+
+	recordDir string
+	replayDir string
+	// network records or replays requests and lookups, and is nil when using the real network.
+	network replay.Network
+	// transport makes every API request, through the network when recording or replaying.
+	transport nethttp.RoundTripper
+)
+
+// CacheDirEnv can be set to use a cache directory other than the default.
+const CacheDirEnv = "SERVERPILOT_TOOLS_CACHE_DIR"
+
+// Account is a ServerPilot account a command runs against. The Name is the profile name, and is empty when the
+// credentials were given as arguments.
+type Account struct {
+	Name   string
+	Client *serverpilot.Client
+}
+
+// AddGlobalFlags adds the global options as persistent flags.
+func AddGlobalFlags(cmd *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	flags.StringSliceVar(&profiles, "profile", nil, "Comma separated profiles to use instead of <client_id> <api_key> (see 'profiles list')")
+	flags.BoolVar(&allProfiles, "all-profiles", false, "Use every saved profile")
+	flags.BoolVar(&noCache, "no-cache", false, "Don't read or write cached API responses")
+	flags.BoolVar(&refresh, "refresh", false, "Ignore cached API responses, and cache the new responses")
+	flags.DurationVar(&cacheTTL, "cache-ttl", http.CacheLifetime, "How long API responses are cached for, e.g. 30m or 2h")
+	flags.StringVar(&cacheDir, "cache-dir", os.Getenv(CacheDirEnv), "Directory API responses are cached in (default "+http.DefaultCacheDir()+", or $"+CacheDirEnv+")")
+	flags.Float64Var(&serverPilotRate, "serverpilot-rate-limit", http.DefaultRateLimits[http.ServerPilotHost].Rate, "Maximum ServerPilot API requests per second")
+	flags.Float64Var(&cloudflareRate, "cloudflare-rate-limit", http.DefaultRateLimits[http.CloudflareHost].Rate, "Maximum Cloudflare API requests per second")
+	flags.IntVar(&retries, "retries", http.DefaultRetrySettings.MaxRetries, "Number of times to retry API requests that fail with a network error, 5xx or 429")
+	flags.DurationVar(&timeout, "timeout", 0, "Give up after this long, e.g. 30s or 5m (default no timeout)")
+	flags.StringVar(&serverPilotUrl, "serverpilot-url", serverpilot.DefaultBaseUrl, "Base url of the ServerPilot API, e.g. a local stand-in for testing")
+	flags.StringVar(&cloudflareUrl, "cloudflare-url", inactive.DefaultCloudflareBaseUrl, "Base url of the Cloudflare API, e.g. a local stand-in for testing")
+	flags.StringVar(&proxy, "proxy", "", "Make API requests through this HTTP(S) proxy (default $HTTPS_PROXY or $HTTP_PROXY)")
+	flags.StringVar(&caBundle, "ca-bundle", "", "PEM file of extra certificates to trust, such as an inspecting proxy's")
+	flags.StringSliceVar(&resolvers, "resolver", nil, "Comma separated nameservers to send DNS queries to instead of the system resolver, e.g. 1.1.1.1:53")
+	flags.BoolVar(&authoritative, "authoritative", false, "Ask each domain's authoritative nameservers directly, walking the delegation from the root")
+	flags.StringVar(&dnsTransport, "dns-transport", "udp", "How DNS queries are sent: udp, or doh to send them over HTTPS to the --doh-url")
+	flags.StringVar(&dohUrl, "doh-url", dns.DefaultDohUrl, "DNS-over-HTTPS endpoint used with --dns-transport doh")
+	flags.StringVar(&dohFormat, "doh-format", string(dns.DohWire), "DNS-over-HTTPS format: wire (RFC 8484) or json")
+	flags.StringVar(&recordDir, "record", "", "Record every API request and DNS lookup to fixtures in this directory, with credentials redacted")
+	flags.StringVar(&replayDir, "replay", "", "Answer every API request and DNS lookup from the fixtures recorded in this directory, without using the network")
+	cmd.MarkFlagsMutuallyExclusive("no-cache", "refresh")
+	cmd.MarkFlagsMutuallyExclusive("record", "replay")
+	cmd.MarkFlagsMutuallyExclusive("resolver", "authoritative")
+	cmd.MarkFlagsMutuallyExclusive("resolver", "replay")
+	cmd.MarkFlagsMutuallyExclusive("authoritative", "replay")
+}
+
+// ApplyRateLimits sets the rate limit of each API from the global flags. The limits apply to the host of the
+// --serverpilot-url and --cloudflare-url, so a local stand-in API is rate limited like the real one.
+func ApplyRateLimits() error {
+	apis := []struct {
+		host    string
+		baseUrl string
+		rate    float64
+	}{
+		{http.ServerPilotHost, serverPilotUrl, serverPilotRate},
+		{http.CloudflareHost, cloudflareUrl, cloudflareRate},
+	}
+
+	for _, api := range apis {
+		if api.rate <= 0 {
+			return fmt.Errorf("the rate limit for %s must be greater than 0", api.host)
+		}
+		u, err := url.Parse(api.baseUrl)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid base url for %s: %s", api.host, api.baseUrl)
+		}
+		limit := http.DefaultRateLimits[api.host]
+		limit.Rate = api.rate
+		http.SetRateLimit(u.Hostname(), limit)
+	}
+
+	return nil
+}
+
+// ApplyNetwork sets up the transport for the --proxy and --ca-bundle, the nameservers for the --resolver or
+// --authoritative lookups or the DNS-over-HTTPS endpoint, and recording or replaying the requests and lookups when
+// --record or --replay is given.
+func ApplyNetwork() error {
+	t, err := http.NewTransport(http.TransportSettings{Proxy: proxy, CABundle: caBundle})
+	if err != nil {
+		return err
+	}
+	transport = t
+
+	if dnsTransport != "udp" && dnsTransport != "doh" {
+		return fmt.Errorf("invalid --dns-transport %s (use udp or doh)", dnsTransport)
+	}
+	if dnsTransport == "doh" && (len(resolvers) > 0 || authoritative || replayDir != "") {
+		return fmt.Errorf("--dns-transport doh can't be used with --resolver, --authoritative or --replay")
+	}
+
+	switch {
+	case dnsTransport == "doh":
+		// The queries go through the --proxy like the API requests
+		lookups, err = dns.NewDohResolver(dohUrl, dns.DohFormat(dohFormat), t)
+	case len(resolvers) > 0:
+		lookups, err = dns.NewRecursiveResolver(resolvers)
+	case authoritative:
+		lookups = dns.NewAuthoritativeResolver(nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case recordDir != "":
+		network, err = replay.NewRecorder(recordDir, t, lookups)
+	case replayDir != "":
+		network, err = replay.NewReplayer(replayDir)
+	}
+	if err != nil {
+		return err
+	}
+	if network != nil {
+		transport = network
+	}
+
+	return nil
+}
+
+// Transport returns the transport every API request is made with, or nil to use http.DefaultTransport.
+func Transport() nethttp.RoundTripper {
+	return transport
+}
+
+// CloudflareUrl is the base url of the Cloudflare API.
+func CloudflareUrl() string {
+	return cloudflareUrl
+}
+
+// IpLookup returns the func used to look up IP addresses, or nil to use the system resolver.
+func IpLookup() dns.IpLookupFunc {
+	switch {
+	case network != nil:
+		return network.LookupIP
+	case lookups != nil:
+		return lookups.LookupIP
+	}
+	return nil
+}
+
+// NsLookup returns the func used to look up nameservers, or nil to use the system resolver.
+func NsLookup() dns.NsLookupFunc {
+	switch {
+	case network != nil:
+		return network.LookupNS
+	case lookups != nil:
+		return lookups.LookupNS
+	}
+	return nil
+}
+
+// WithTimeout returns a context that is cancelled after the --timeout, if one was given. The cancel func must be
+// called once the command is done.
+func WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Timeout is the --timeout, or 0 when there is none.
+func Timeout() time.Duration {
+	return timeout
+}
+
+// CacheSettings returns the cache settings chosen with the global flags.
+func CacheSettings() httpclient.CacheSettings {
+	mode := httpclient.CacheOn
+	switch {
+	// Every request needs to go through the recorder or replayer
+	case noCache, recordDir != "", replayDir != "":
+		mode = httpclient.CacheOff
+	case refresh:
+		mode = httpclient.CacheRefresh
+	}
+
+	return httpclient.CacheSettings{Mode: mode, Dir: cacheDir, TTL: cacheTTL}
+}
+
+// CacheStore returns the cache chosen with the global flags.
+func CacheStore() *http.FileStore {
+	return internalCacheSettings().Store()
+}
+
+func internalCacheSettings() http.CacheSettings {
+	s := CacheSettings()
+	return http.CacheSettings{Mode: http.CacheMode(s.Mode), Dir: s.Dir, TTL: s.TTL, MaxSize: s.MaxSize}
+}
+
+// RetrySettings returns the retry settings chosen with the global flags.
+func RetrySettings() httpclient.RetrySettings {
+	s := httpclient.DefaultRetrySettings
+	s.MaxRetries = retries
+	// A replayed response is the same every time
+	if replayDir != "" {
+		s.MaxRetries = 0
+	}
+	return s
+}
+
+// CloudflareSettings returns the settings for Cloudflare API clients chosen with the global flags.
+func CloudflareSettings() http.ClientSettings {
+	return http.ClientSettings{Cache: internalCacheSettings(), Retry: http.RetrySettings(RetrySettings()), Transport: transport, BaseUrl: cloudflareUrl}
+}
+
+func newClient(clientId, apiKey string, logger *log.Logger) *serverpilot.Client {
+	return serverpilot.NewClient(
+		clientId,
+		apiKey,
+		serverpilot.WithLogger(logger),
+		serverpilot.WithCache(CacheSettings()),
+		serverpilot.WithRetry(RetrySettings()),
+		serverpilot.WithTransport(transport),
+		serverpilot.WithBaseUrl(serverPilotUrl),
+	)
+}
+
+// CredentialsArgs requires either <client_id> <api_key>, or no arguments when profiles are used.
+func CredentialsArgs(cmd *cobra.Command, args []string) error {
+	if MultiAccount() {
+		if len(args) != 0 {
+			return fmt.Errorf("<client_id> <api_key> can't be used with --profile or --all-profiles")
+		}
+		return nil
+	}
+
+	if len(args) != 2 {
+		return fmt.Errorf("requires <client_id> <api_key>, or --profile or --all-profiles, received %d arg(s)", len(args))
+	}
+
+	return nil
+}
+
+// MultiAccount is true when the accounts come from profiles, in which case results should include the account.
+func MultiAccount() bool {
+	return allProfiles || len(profiles) > 0
+}
+
+// NewLogger returns a logger that writes to stdout for commands run with --verbose, and discards everything otherwise.
+func NewLogger(verbose bool) *log.Logger {
+	logger := log.New(io.Discard, "", 0)
+	if verbose {
+		logger.SetOutput(os.Stdout)
+	}
+	return logger
+}
+
+// Accounts returns a client for the credentials given as arguments, or for each of the selected profiles.
+func Accounts(args []string, logger *log.Logger) ([]Account, error) {
+	if !MultiAccount() {
+		return []Account{{Client: newClient(args[0], args[1], logger)}}, nil
+	}
+
+	path, err := config.Path()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	names := profiles
+	if allProfiles {
+		names = c.ProfileNames()
+		if len(names) == 0 {
+			return nil, fmt.Errorf("%w: no profiles have been saved (see 'profiles save')", config.ErrProfileNotFound)
+		}
+	}
+
+	var accounts []Account
+	for _, name := range names {
+		p, err := c.Profile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, Account{name, newClient(p.ClientId, p.ApiKey, logger)})
+	}
+
+	return accounts, nil
+}
+
+// FetchAll calls f for every account concurrently, and returns the results in the same order as the accounts. If
+// any of the accounts fail, the errors are returned together, prefixed with the account name.
+func FetchAll[T any](ctx context.Context, accounts []Account, f func(ctx context.Context, a Account) (T, error)) ([]T, error) {
+	results := make([]T, len(accounts))
+	errs := make([]error, len(accounts))
+
+	var wg sync.WaitGroup
+	for i, account := range accounts {
+		wg.Add(1)
+		go func(i int, account Account) {
+			defer wg.Done()
+
+			results[i], errs[i] = f(ctx, account)
+			if errs[i] != nil && account.Name != "" {
+				errs[i] = fmt.Errorf("%s: %w", account.Name, errs[i])
+			}
+		}(i, account)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// FetchAppServers fetches the apps of every account, tagged with the account they belong to.
+func FetchAppServers(ctx context.Context, accounts []Account) ([]serverpilot.AppServer, error) {
+	results, err := FetchAll(ctx, accounts, func(ctx context.Context, a Account) ([]serverpilot.AppServer, error) {
+		apps, err := a.Client.AppServers(ctx)
+		for i := range apps {
+			apps[i].Account = a.Name
+		}
+		return apps, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var apps []serverpilot.AppServer
+	for _, result := range results {
+		apps = append(apps, result...)
+	}
+
+	return apps, nil
+}
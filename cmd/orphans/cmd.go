@@ -0,0 +1,128 @@
+package orphans
+
+import (
+	"context"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/cmd/global"
+	"github.com/jfortunato/serverpilot-tools/internal/convert"
+	"github.com/jfortunato/serverpilot-tools/internal/dns"
+	"github.com/jfortunato/serverpilot-tools/internal/orphans"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+type orphansOptions struct {
+	fix bool
+}
+
+func NewOrphansCommand() *cobra.Command {
+	options := orphansOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "orphans [OPTIONS]",
+		Short: "Find orphaned servers, sysusers, databases and apps",
+		Long: `Find orphaned resources: servers with no apps, sysusers with no apps,
+  databases whose app is gone, and apps with no domains. Each finding includes
+  a suggested cleanup action. With --fix, each finding can be deleted through
+  the API after confirming it.`,
+		Args: global.CredentialsArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runOrphans(cmd.Context(), args, options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&options.fix, "fix", false, "Delete the orphaned resources, after confirming each one")
+
+	return cmd
+}
+
+func runOrphans(ctx context.Context, args []string, options orphansOptions) error {
+	logger := log.New(io.Discard, "", 0)
+
+	accounts, err := global.Accounts(args, logger)
+	if err != nil {
+		return err
+	}
+
+	// Resources can only be orphaned within their own account, so each account is checked separately
+	findings, err := global.FetchAll(ctx, accounts, findOrphans)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, f := range findings {
+		total += len(f)
+	}
+	if total == 0 {
+		fmt.Println("No orphaned resources found.")
+		return nil
+	}
+
+	if err := printFindings(accounts, findings, global.MultiAccount()); err != nil {
+		return err
+	}
+
+	if !options.fix {
+		return nil
+	}
+
+	fmt.Println()
+	deleted := 0
+	for i, account := range accounts {
+		var d []orphans.Finding
+		d, err = orphans.Fix(ctx, account.Client, &dns.Prompter{}, findings[i])
+		deleted += len(d)
+		if err != nil {
+			break
+		}
+	}
+	fmt.Printf("Deleted %d of %d orphaned resources.\n", deleted, total)
+
+	return err
+}
+
+func findOrphans(ctx context.Context, a global.Account) ([]orphans.Finding, error) {
+	srvers, err := a.Client.Servers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting servers: %w", err)
+	}
+
+	apps, err := a.Client.Apps(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting apps: %w", err)
+	}
+
+	users, err := a.Client.Sysusers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting sysusers: %w", err)
+	}
+
+	dbs, err := a.Client.Databases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting databases: %w", err)
+	}
+
+	return orphans.Find(convert.Servers(srvers), convert.Apps(apps), convert.Sysusers(users), convert.Databases(dbs)), nil
+}
+
+func printFindings(accounts []global.Account, findings [][]orphans.Finding, showAccount bool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	if showAccount {
+		fmt.Fprint(w, "ACCOUNT\t")
+	}
+	fmt.Fprintln(w, "TYPE\tID\tNAME\tSERVER\tSUGGESTED ACTION\t")
+	for i, account := range accounts {
+		for _, finding := range findings[i] {
+			if showAccount {
+				fmt.Fprint(w, account.Name+"\t")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", orphans.TypeName(finding.Type), finding.Id, finding.Name, finding.ServerName, finding.Action)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,36 @@
+package profiles
+
+import (
+	"github.com/jfortunato/serverpilot-tools/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func NewProfilesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profiles COMMAND",
+		Short: "Manage ServerPilot account profiles for use with --profile",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(
+		newListCommand(),
+		newSaveCommand(),
+		newDeleteCommand(),
+	)
+
+	return cmd
+}
+
+func loadConfig() (*config.Config, string, error) {
+	path, err := config.Path()
+	if err != nil {
+		return nil, "", err
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return c, path, nil
+}
@@ -0,0 +1,32 @@
+package profiles
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+func newListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List saved profiles",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, _, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+			fmt.Fprintln(w, "NAME\tCLIENT ID\t")
+			for _, name := range c.ProfileNames() {
+				fmt.Fprintf(w, "%s\t%s\t\n", name, c.Profiles[name].ClientId)
+			}
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
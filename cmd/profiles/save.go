@@ -0,0 +1,38 @@
+package profiles
+
+import (
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func newSaveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "save NAME <client_id> <api_key>",
+		Short: "Save the credentials of a ServerPilot account as a profile",
+		Long: `Save the credentials of a ServerPilot account as a profile. Use it with
+  '--profile NAME', or query every profile at once with '--all-profiles'.
+  Saving a profile with an existing name replaces it.`,
+		Args: cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, path, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			if err := c.SaveProfile(args[0], config.Profile{ClientId: args[1], ApiKey: args[2]}); err != nil {
+				return err
+			}
+
+			if err := c.Save(path); err != nil {
+				return err
+			}
+
+			fmt.Printf("Saved profile %s to %s\n", args[0], path)
+
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,140 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/cmd/global"
+	"github.com/jfortunato/serverpilot-tools/internal/convert"
+	"github.com/jfortunato/serverpilot-tools/internal/report"
+	model "github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"github.com/jfortunato/serverpilot-tools/pkg/serverpilot"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func newCapacityCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "capacity [OPTIONS]",
+		Short: "Show how apps and resources are distributed across servers",
+		Long: `Show how apps and resources are distributed across servers. For each
+  server this lists the number of apps, sysusers, databases and domains, the
+  runtimes in use, and the oldest and newest app. The imbalance score is the
+  coefficient of variation of apps per server, where 0 is perfectly balanced.`,
+		Args: global.CredentialsArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := log.New(io.Discard, "", 0)
+
+			accounts, err := global.Accounts(args, logger)
+			if err != nil {
+				return err
+			}
+
+			results, err := global.FetchAll(cmd.Context(), accounts, fetchResources)
+			if err != nil {
+				return err
+			}
+
+			// Combine every account into a single report, remembering which account each server belongs to
+			all := resources{}
+			serverAccounts := make(map[string]string)
+			for i, r := range results {
+				for _, server := range r.servers {
+					serverAccounts[server.Id] = accounts[i].Name
+				}
+				all.servers = append(all.servers, r.servers...)
+				all.apps = append(all.apps, r.apps...)
+				all.sysusers = append(all.sysusers, r.sysusers...)
+				all.databases = append(all.databases, r.databases...)
+			}
+
+			return printCapacity(report.Capacity(all.servers, all.apps, all.sysusers, all.databases), serverAccounts, global.MultiAccount())
+		},
+	}
+
+	return cmd
+}
+
+// resources is everything in a single account that the capacity report needs.
+type resources struct {
+	servers   []model.Server
+	apps      []model.AppServer
+	sysusers  []model.Sysuser
+	databases []model.Database
+}
+
+func fetchResources(ctx context.Context, a global.Account) (resources, error) {
+	var r resources
+
+	servers, err := a.Client.Servers(ctx)
+	if err != nil {
+		return r, fmt.Errorf("error while getting servers: %w", err)
+	}
+	r.servers = convert.Servers(servers)
+
+	// Join the apps with the servers that were already fetched, instead of fetching them again
+	apps, err := a.Client.Apps(ctx)
+	if err != nil {
+		return r, fmt.Errorf("error while getting apps: %w", err)
+	}
+	r.apps = convert.AppServers(serverpilot.JoinAppServers(apps, servers))
+
+	sysusers, err := a.Client.Sysusers(ctx)
+	if err != nil {
+		return r, fmt.Errorf("error while getting sysusers: %w", err)
+	}
+	r.sysusers = convert.Sysusers(sysusers)
+
+	databases, err := a.Client.Databases(ctx)
+	if err != nil {
+		return r, fmt.Errorf("error while getting databases: %w", err)
+	}
+	r.databases = convert.Databases(databases)
+
+	return r, nil
+}
+
+func printCapacity(r report.CapacityReport, serverAccounts map[string]string, showAccount bool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	if showAccount {
+		fmt.Fprint(w, "ACCOUNT\t")
+	}
+	fmt.Fprintln(w, "SERVER\tAPPS\tSYSUSERS\tDATABASES\tDOMAINS\tRUNTIMES\tOLDEST\tNEWEST\t")
+	for _, server := range r.Servers {
+		if showAccount {
+			fmt.Fprint(w, serverAccounts[server.Server.Id]+"\t")
+		}
+		printCapacityRow(w, server)
+	}
+	if showAccount {
+		fmt.Fprint(w, "\t")
+	}
+	printCapacityRow(w, r.Totals)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nImbalance score: %.2f\n", r.Imbalance)
+
+	return nil
+}
+
+func printCapacityRow(w io.Writer, c report.ServerCapacity) {
+	var runtimes []string
+	for _, runtime := range c.SortedRuntimes() {
+		runtimes = append(runtimes, fmt.Sprintf("%s:%d", runtime, c.Runtimes[runtime]))
+	}
+
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\t%s\t%s\t\n", c.Server.Name, c.Apps, c.Sysusers, c.Databases, c.Domains, strings.Join(runtimes, ", "), appSummary(c.Oldest), appSummary(c.Newest))
+}
+
+func appSummary(app *model.App) string {
+	if app == nil {
+		return "-"
+	}
+
+	return fmt.Sprintf("%s (%s)", app.Name, app.Datecreated)
+}
@@ -0,0 +1,17 @@
+package report
+
+import "github.com/spf13/cobra"
+
+func NewReportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report COMMAND",
+		Short: "Generate reports",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(
+		newCapacityCommand(),
+	)
+
+	return cmd
+}
@@ -1,11 +1,22 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/jfortunato/serverpilot-tools/cmd/apps"
+	"github.com/jfortunato/serverpilot-tools/cmd/cache"
+	"github.com/jfortunato/serverpilot-tools/cmd/domains"
+	"github.com/jfortunato/serverpilot-tools/cmd/global"
+	"github.com/jfortunato/serverpilot-tools/cmd/orphans"
+	"github.com/jfortunato/serverpilot-tools/cmd/profiles"
+	"github.com/jfortunato/serverpilot-tools/cmd/report"
 	"github.com/jfortunato/serverpilot-tools/cmd/servers"
+	"github.com/jfortunato/serverpilot-tools/cmd/views"
 	"github.com/spf13/cobra"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 type VersionDetails struct {
@@ -18,17 +29,62 @@ var rootCmd = &cobra.Command{
 	Use:   "serverpilot-tools",
 	Short: "A collection of tools for ServerPilot.io",
 	Long:  `A collection of tools for ServerPilot.io`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// The flags and args are valid by now, so don't show the usage for errors such as a timeout
+		cmd.SilenceUsage = true
+
+		ctx, cancel := global.WithTimeout(cmd.Context())
+		cancelTimeout = cancel
+		cmd.SetContext(ctx)
+
+		if err := global.ApplyNetwork(); err != nil {
+			return err
+		}
+
+		return global.ApplyRateLimits()
+	},
 }
 
+// cancelTimeout releases the --timeout context once the command is done.
+var cancelTimeout context.CancelFunc = func() {}
+
 func Execute(v VersionDetails) {
 	rootCmd.Version = v.Version
 
+	global.AddGlobalFlags(rootCmd)
+
 	rootCmd.AddCommand(
 		apps.NewAppsCommand(),
+		cache.NewCacheCommand(),
+		domains.NewDomainsCommand(),
+		orphans.NewOrphansCommand(),
+		profiles.NewProfilesCommand(),
+		report.NewReportCommand(),
 		servers.NewServersCommand(),
+		views.NewViewsCommand(),
 	)
 
-	if err := rootCmd.Execute(); err != nil {
+	// Commands stop what they are doing on Ctrl-C, and print what they have so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		// A second Ctrl-C exits right away
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	cancelTimeout()
+	stop()
+
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled):
+		fmt.Println("Interrupted, the results may be incomplete")
+		os.Exit(130)
+	case errors.Is(err, context.DeadlineExceeded) && global.Timeout() > 0:
+		fmt.Printf("Timed out after %s, the results may be incomplete\n", global.Timeout())
+		os.Exit(1)
+	default:
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -1,9 +1,10 @@
 package servers
 
 import (
+	"context"
 	"fmt"
-	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
-	"github.com/jfortunato/serverpilot-tools/internal/servers"
+	"github.com/jfortunato/serverpilot-tools/cmd/global"
+	"github.com/jfortunato/serverpilot-tools/pkg/serverpilot"
 	"github.com/spf13/cobra"
 	"io"
 	"log"
@@ -16,18 +17,23 @@ func newListCommand() *cobra.Command {
 		Use:     "list [OPTIONS]",
 		Aliases: []string{"ls"},
 		Short:   "List servers",
-		Args:    cobra.ExactArgs(2),
+		Args:    global.CredentialsArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			logger := log.New(io.Discard, "", 0)
 
-			c := serverpilot.NewClient(logger, args[0], args[1])
+			accounts, err := global.Accounts(args, logger)
+			if err != nil {
+				return err
+			}
 
-			s, err := servers.GetServers(c)
+			s, err := global.FetchAll(cmd.Context(), accounts, func(ctx context.Context, a global.Account) ([]serverpilot.Server, error) {
+				return a.Client.Servers(ctx)
+			})
 			if err != nil {
 				return fmt.Errorf("error while getting servers: %w", err)
 			}
 
-			printServers(s)
+			printServers(accounts, s, global.MultiAccount())
 
 			return nil
 		},
@@ -36,11 +42,19 @@ func newListCommand() *cobra.Command {
 	return cmd
 }
 
-func printServers(servers []serverpilot.Server) {
+func printServers(accounts []global.Account, servers [][]serverpilot.Server, showAccount bool) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
-	fmt.Fprintln(w, "ID\tNAME\tIP\tCREATED\t")
-	for _, server := range servers {
-		fmt.Fprintln(w, server.Id+"\t"+server.Name+"\t"+server.Ipaddress+"\t"+server.Datecreated.String()+"\t")
+	if showAccount {
+		fmt.Fprint(w, "ACCOUNT\t")
+	}
+	fmt.Fprintln(w, "ID\tNAME\tIP\tIPV6\tCREATED\t")
+	for i, account := range accounts {
+		for _, server := range servers[i] {
+			if showAccount {
+				fmt.Fprint(w, account.Name+"\t")
+			}
+			fmt.Fprintln(w, server.Id+"\t"+server.Name+"\t"+server.IPv4()+"\t"+server.IPv6()+"\t"+server.Datecreated.String()+"\t")
+		}
 	}
 	w.Flush()
 }
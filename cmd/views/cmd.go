@@ -0,0 +1,36 @@
+package views
+
+import (
+	"github.com/jfortunato/serverpilot-tools/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func NewViewsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "views COMMAND",
+		Short: "Manage saved views for 'apps list'",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(
+		newListCommand(),
+		newSaveCommand(),
+		newDeleteCommand(),
+	)
+
+	return cmd
+}
+
+func loadConfig() (*config.Config, string, error) {
+	path, err := config.Path()
+	if err != nil {
+		return nil, "", err
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return c, path, nil
+}
@@ -0,0 +1,35 @@
+package views
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+func newDeleteCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "delete NAME",
+		Aliases: []string{"rm"},
+		Short:   "Delete a saved view",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, path, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			if err := c.DeleteView(args[0]); err != nil {
+				return err
+			}
+
+			if err := c.Save(path); err != nil {
+				return err
+			}
+
+			fmt.Printf("Deleted view %s\n", args[0])
+
+			return nil
+		},
+	}
+
+	return cmd
+}
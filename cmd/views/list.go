@@ -0,0 +1,68 @@
+package views
+
+import (
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/config"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func newListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List saved views",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, _, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+			fmt.Fprintln(w, "NAME\tFILTER\tSORT BY\tGROUP BY\tCOLUMNS\tRUNTIME\tCREATED\t")
+			for _, name := range c.ViewNames() {
+				v := c.Views[name]
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", name, v.Filter, v.SortBy, v.GroupBy, strings.Join(v.Columns, ","), runtimeText(v), createdText(v))
+			}
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
+
+// runtimeText describes the runtime options of a view, e.g. ">=7.4 <8.1, min php7.0".
+func runtimeText(v config.View) string {
+	var parts []string
+	if v.Runtime != "" {
+		parts = append(parts, v.Runtime)
+	}
+	if v.MinRuntime != "" {
+		parts = append(parts, "min "+v.MinRuntime)
+	}
+	if v.MaxRuntime != "" {
+		parts = append(parts, "max "+v.MaxRuntime)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// createdText describes the creation date options of a view, e.g. "within 90d, before 2023-12-31".
+func createdText(v config.View) string {
+	var parts []string
+	if v.CreatedAfter != "" {
+		parts = append(parts, "after "+v.CreatedAfter)
+	}
+	if v.CreatedBefore != "" {
+		parts = append(parts, "before "+v.CreatedBefore)
+	}
+	if v.CreatedWithin != "" {
+		parts = append(parts, "within "+v.CreatedWithin)
+	}
+	if v.OlderThan != "" {
+		parts = append(parts, "older than "+v.OlderThan)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,56 @@
+package views
+
+import (
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/config"
+	"github.com/jfortunato/serverpilot-tools/internal/filter"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+func newSaveCommand() *cobra.Command {
+	view := config.View{}
+
+	cmd := &cobra.Command{
+		Use:   "save NAME [OPTIONS]",
+		Short: "Save a filter, sort and column set as a view",
+		Long: `Save a filter, sort and column set as a view, along with any
+  runtime and creation date options. Use it with 'apps list --view NAME'.
+  Relative dates such as --created-within 90d are relative to when the view
+  is used. Saving a view with an existing name replaces it.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, path, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			if err := c.SaveView(args[0], view); err != nil {
+				return err
+			}
+
+			if err := c.Save(path); err != nil {
+				return err
+			}
+
+			fmt.Printf("Saved view %s to %s\n", args[0], path)
+
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&view.Filter, "filter", "", "Filter expression, e.g. 'runtime < 8.1'")
+	flags.StringVar(&view.SortBy, "sort-by", "", "Sort apps by "+strings.Join(filter.SortFields, "|"))
+	flags.StringVar(&view.GroupBy, "group-by", "", "Group apps by "+strings.Join(filter.GroupFields, "|"))
+	flags.StringSliceVar(&view.Columns, "columns", nil, "Comma separated columns to display ("+strings.Join(filter.Columns, ", ")+")")
+	flags.StringVar(&view.Runtime, "runtime", "", "Only display apps with a runtime in the specified range, e.g. \">=7.4 <8.1\" or \"8.x\"")
+	flags.StringVar(&view.MinRuntime, "min-runtime", "", "Only display apps with a runtime greater than or equal to the specified runtime")
+	flags.StringVar(&view.MaxRuntime, "max-runtime", "", "Only display apps with a runtime less than or equal to the specified runtime")
+	flags.StringVar(&view.CreatedAfter, "created-after", "", "Only display apps created after the specified date")
+	flags.StringVar(&view.CreatedBefore, "created-before", "", "Only display apps created before the specified date")
+	flags.StringVar(&view.CreatedWithin, "created-within", "", "Only display apps created within the specified time, e.g. 90d (units are h, d, w, m, y)")
+	flags.StringVar(&view.OlderThan, "older-than", "", "Only display apps created longer ago than the specified time, e.g. 2y (units are h, d, w, m, y)")
+
+	return cmd
+}
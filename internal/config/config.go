@@ -0,0 +1,251 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/filter"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCouldNotLoad = errors.New("could not load config file")
+	ErrCouldNotSave = errors.New("could not save config file")
+	ErrViewNotFound = errors.New("view not found")
+	ErrInvalidView  = errors.New("invalid view")
+
+	ErrProfileNotFound = errors.New("profile not found")
+	ErrInvalidProfile  = errors.New("invalid profile")
+)
+
+// PathEnv can be set to use a config file other than the default.
+const PathEnv = "SERVERPILOT_TOOLS_CONFIG"
+
+// Config is the contents of the serverpilot-tools config file.
+type Config struct {
+	Views    map[string]View    `json:"views,omitempty"`
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+}
+
+// Profile is a named set of ServerPilot API credentials, so commands can be run against several accounts.
+type Profile struct {
+	ClientId string `json:"client_id"`
+	ApiKey   string `json:"api_key"`
+}
+
+// View is a saved filter, sort and column set for listing apps, along with the runtime and creation date options.
+// The relative dates (CreatedWithin and OlderThan) are kept as they were given, such as 90d, so they are relative to
+// when the view is used.
+type View struct {
+	Filter        string   `json:"filter,omitempty"`
+	SortBy        string   `json:"sort_by,omitempty"`
+	GroupBy       string   `json:"group_by,omitempty"`
+	Columns       []string `json:"columns,omitempty"`
+	Runtime       string   `json:"runtime,omitempty"`
+	MinRuntime    string   `json:"min_runtime,omitempty"`
+	MaxRuntime    string   `json:"max_runtime,omitempty"`
+	CreatedAfter  string   `json:"created_after,omitempty"`
+	CreatedBefore string   `json:"created_before,omitempty"`
+	CreatedWithin string   `json:"created_within,omitempty"`
+	OlderThan     string   `json:"older_than,omitempty"`
+}
+
+// Path returns the location of the config file, which is under the user's config directory unless overridden
+// with the SERVERPILOT_TOOLS_CONFIG environment variable.
+func Path() (string, error) {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "serverpilot-tools", "config.json"), nil
+}
+
+// Load reads the config file. A missing config file is treated as an empty config.
+func Load(path string) (*Config, error) {
+	c := &Config{}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCouldNotLoad, err)
+	}
+
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", ErrCouldNotLoad, path, err)
+	}
+
+	return c, nil
+}
+
+// Save writes the config file, creating its directory if needed. The file is written to a temporary file first
+// so a failed write never leaves a partial config behind.
+func (c *Config) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrCouldNotSave, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("%w: %s", ErrCouldNotSave, err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0600); err != nil {
+		return fmt.Errorf("%w: %s", ErrCouldNotSave, err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("%w: %s", ErrCouldNotSave, err)
+	}
+
+	return nil
+}
+
+// View returns the view with the given name.
+func (c *Config) View(name string) (View, error) {
+	v, ok := c.Views[name]
+	if !ok {
+		return View{}, fmt.Errorf("%w: %s", ErrViewNotFound, name)
+	}
+	return v, nil
+}
+
+// SaveView adds or replaces a view, after validating it.
+func (c *Config) SaveView(name string, v View) error {
+	if name == "" {
+		return fmt.Errorf("%w: a name is required", ErrInvalidView)
+	}
+	if err := v.Validate(); err != nil {
+		return err
+	}
+
+	if c.Views == nil {
+		c.Views = make(map[string]View)
+	}
+	c.Views[name] = v
+
+	return nil
+}
+
+// DeleteView removes the view with the given name.
+func (c *Config) DeleteView(name string) error {
+	if _, ok := c.Views[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrViewNotFound, name)
+	}
+	delete(c.Views, name)
+	return nil
+}
+
+// ViewNames returns the names of all views, in alphabetical order.
+func (c *Config) ViewNames() []string {
+	var names []string
+	for name := range c.Views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns the profile with the given name.
+func (c *Config) Profile(name string) (Profile, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	return p, nil
+}
+
+// SaveProfile adds or replaces a profile.
+func (c *Config) SaveProfile(name string, p Profile) error {
+	if name == "" {
+		return fmt.Errorf("%w: a name is required", ErrInvalidProfile)
+	}
+	if strings.Contains(name, ",") {
+		return fmt.Errorf("%w: the name can't contain a comma", ErrInvalidProfile)
+	}
+	if p.ClientId == "" || p.ApiKey == "" {
+		return fmt.Errorf("%w: a client id and api key are required", ErrInvalidProfile)
+	}
+
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]Profile)
+	}
+	c.Profiles[name] = p
+
+	return nil
+}
+
+// DeleteProfile removes the profile with the given name.
+func (c *Config) DeleteProfile(name string) error {
+	if _, ok := c.Profiles[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	delete(c.Profiles, name)
+	return nil
+}
+
+// ProfileNames returns the names of all profiles, in alphabetical order.
+func (c *Config) ProfileNames() []string {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate ensures the filter compiles, the sort, group and columns are known, and the runtimes and dates are valid.
+func (v View) Validate() error {
+	if v.Filter != "" {
+		if _, err := filter.Compile(v.Filter); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidView, err)
+		}
+	}
+	if v.SortBy != "" && !contains(filter.SortFields, v.SortBy) {
+		return fmt.Errorf("%w: %w: %s", ErrInvalidView, filter.ErrInvalidSort, v.SortBy)
+	}
+	if v.GroupBy != "" && !contains(filter.GroupFields, v.GroupBy) {
+		return fmt.Errorf("%w: %w: %s", ErrInvalidView, filter.ErrInvalidGroup, v.GroupBy)
+	}
+	if err := filter.ValidateColumns(v.Columns); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidView, err)
+	}
+	if _, err := serverpilot.ParseRuntimeRange(v.Runtime); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidView, err)
+	}
+	if _, err := serverpilot.RuntimeRangeFromBounds(serverpilot.Runtime(v.MinRuntime), serverpilot.Runtime(v.MaxRuntime)); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidView, err)
+	}
+	for _, date := range []string{v.CreatedAfter, v.CreatedBefore} {
+		if _, err := serverpilot.DateCreatedFromDate(date); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidView, err)
+		}
+	}
+	for _, age := range []string{v.CreatedWithin, v.OlderThan} {
+		if _, err := serverpilot.DateCreatedFromRelative(age, time.Now()); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidView, err)
+		}
+	}
+	return nil
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
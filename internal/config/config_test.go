@@ -0,0 +1,139 @@
+package config
+
+import (
+	"github.com/jfortunato/serverpilot-tools/internal/filter"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"gotest.tools/v3/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("it should treat a missing config file as empty", func(t *testing.T) {
+		c, err := Load(filepath.Join(t.TempDir(), "config.json"))
+
+		assert.NilError(t, err)
+		assert.Equal(t, len(c.Views), 0)
+	})
+
+	t.Run("it should return an error for an invalid config file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.json")
+		os.WriteFile(path, []byte("{nonsense"), 0600)
+
+		_, err := Load(path)
+
+		assert.ErrorIs(t, err, ErrCouldNotLoad)
+	})
+
+	t.Run("it should save and load views", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nested", "config.json")
+		view := View{
+			Filter:        `runtime < 8.1`,
+			SortBy:        "created",
+			GroupBy:       "server",
+			Columns:       []string{"name", "runtime"},
+			Runtime:       ">=7.4 <8.1",
+			MinRuntime:    "php7.0",
+			MaxRuntime:    "php8.2",
+			CreatedAfter:  "2023-01-01",
+			CreatedBefore: "2023-12-31",
+			CreatedWithin: "90d",
+			OlderThan:     "1w",
+		}
+
+		c := &Config{}
+		assert.NilError(t, c.SaveView("legacy-php", view))
+		assert.NilError(t, c.Save(path))
+
+		loaded, err := Load(path)
+		assert.NilError(t, err)
+
+		got, err := loaded.View("legacy-php")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, view)
+		assert.DeepEqual(t, loaded.ViewNames(), []string{"legacy-php"})
+	})
+
+	t.Run("it should delete views", func(t *testing.T) {
+		c := &Config{Views: map[string]View{"a": {}, "b": {}}}
+
+		assert.NilError(t, c.DeleteView("a"))
+		assert.DeepEqual(t, c.ViewNames(), []string{"b"})
+		assert.ErrorIs(t, c.DeleteView("a"), ErrViewNotFound)
+	})
+
+	t.Run("it should return an error for an unknown view", func(t *testing.T) {
+		_, err := (&Config{}).View("missing")
+
+		assert.ErrorIs(t, err, ErrViewNotFound)
+	})
+
+	t.Run("it should validate views before saving them", func(t *testing.T) {
+		var tests = []struct {
+			name    string
+			view    View
+			wantErr error
+		}{
+			{"invalid filter", View{Filter: `runtime <`}, filter.ErrInvalidExpression},
+			{"invalid sort", View{SortBy: "domains"}, filter.ErrInvalidSort},
+			{"invalid group", View{GroupBy: "name"}, filter.ErrInvalidGroup},
+			{"invalid column", View{Columns: []string{"bogus"}}, filter.ErrInvalidColumn},
+			{"invalid runtime range", View{Runtime: ">=latest"}, serverpilot.ErrInvalidRuntimeRange},
+			{"invalid min runtime", View{MinRuntime: "eight"}, serverpilot.ErrInvalidRuntime},
+			{"invalid date", View{CreatedAfter: "yesterday"}, serverpilot.ErrInvalidDateString},
+			{"invalid relative date", View{CreatedWithin: "3x"}, serverpilot.ErrInvalidRelativeDate},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := (&Config{}).SaveView("view", tt.view)
+
+				assert.ErrorIs(t, err, ErrInvalidView)
+				assert.ErrorIs(t, err, tt.wantErr)
+			})
+		}
+	})
+
+	t.Run("it should save, load and delete profiles", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.json")
+		profile := Profile{ClientId: "cid", ApiKey: "key"}
+
+		c := &Config{}
+		assert.NilError(t, c.SaveProfile("agency", profile))
+		assert.NilError(t, c.SaveProfile("legacy", profile))
+		assert.NilError(t, c.Save(path))
+
+		loaded, err := Load(path)
+		assert.NilError(t, err)
+
+		got, err := loaded.Profile("agency")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, profile)
+		assert.DeepEqual(t, loaded.ProfileNames(), []string{"agency", "legacy"})
+
+		assert.NilError(t, loaded.DeleteProfile("agency"))
+		_, err = loaded.Profile("agency")
+		assert.ErrorIs(t, err, ErrProfileNotFound)
+	})
+
+	t.Run("it should validate profiles before saving them", func(t *testing.T) {
+		var tests = []struct {
+			name        string
+			profileName string
+			profile     Profile
+		}{
+			{"missing name", "", Profile{ClientId: "cid", ApiKey: "key"}},
+			{"name with a comma", "a,b", Profile{ClientId: "cid", ApiKey: "key"}},
+			{"missing api key", "agency", Profile{ClientId: "cid"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := (&Config{}).SaveProfile(tt.profileName, tt.profile)
+
+				assert.ErrorIs(t, err, ErrInvalidProfile)
+			})
+		}
+	})
+}
@@ -0,0 +1,78 @@
+// Package convert converts the exported types of pkg/serverpilot into the internal types, for the packages that take
+// them from the public API (or the CLI) and hand them to the internal ones.
+package convert
+
+import (
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	public "github.com/jfortunato/serverpilot-tools/pkg/serverpilot"
+)
+
+// App converts an app.
+func App(a public.App) serverpilot.App {
+	return serverpilot.App{
+		Id:          a.Id,
+		Name:        a.Name,
+		Serverid:    a.Serverid,
+		Sysuserid:   a.Sysuserid,
+		Runtime:     serverpilot.Runtime(a.Runtime),
+		Domains:     a.Domains,
+		Datecreated: serverpilot.DateCreated(a.Datecreated),
+	}
+}
+
+// Server converts a server.
+func Server(s public.Server) serverpilot.Server {
+	return serverpilot.Server{Id: s.Id, Name: s.Name, Ipaddress: s.Ipaddress, Datecreated: serverpilot.DateCreated(s.Datecreated)}
+}
+
+// AppServer converts an app along with its server.
+func AppServer(a public.AppServer) serverpilot.AppServer {
+	return serverpilot.AppServer{App: App(a.App), Server: Server(a.Server), Account: a.Account}
+}
+
+// Sysuser converts a sysuser.
+func Sysuser(u public.Sysuser) serverpilot.Sysuser {
+	return serverpilot.Sysuser(u)
+}
+
+// Database converts a database.
+func Database(d public.Database) serverpilot.Database {
+	return serverpilot.Database{Id: d.Id, Name: d.Name, Appid: d.Appid, Serverid: d.Serverid, User: serverpilot.DatabaseUser(d.User)}
+}
+
+// Apps converts every app.
+func Apps(apps []public.App) []serverpilot.App {
+	return All(apps, App)
+}
+
+// AppServers converts every app, along with its server.
+func AppServers(apps []public.AppServer) []serverpilot.AppServer {
+	return All(apps, AppServer)
+}
+
+// Servers converts every server.
+func Servers(servers []public.Server) []serverpilot.Server {
+	return All(servers, Server)
+}
+
+// Sysusers converts every sysuser.
+func Sysusers(users []public.Sysuser) []serverpilot.Sysuser {
+	return All(users, Sysuser)
+}
+
+// Databases converts every database.
+func Databases(dbs []public.Database) []serverpilot.Database {
+	return All(dbs, Database)
+}
+
+// All converts every item of a slice, keeping a nil slice nil.
+func All[T, U any](items []T, f func(T) U) []U {
+	if items == nil {
+		return nil
+	}
+	results := make([]U, len(items))
+	for i, item := range items {
+		results[i] = f(item)
+	}
+	return results
+}
@@ -0,0 +1,34 @@
+package databases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/filter"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"strings"
+)
+
+var (
+	ErrInvalidRequest = errors.New("error while making request")
+	ErrInvalidJson    = errors.New("error while decoding json")
+)
+
+func GetDatabases(ctx context.Context, c filter.HttpClient) ([]serverpilot.Database, error) {
+	resp, err := c.Get(ctx, "/dbs")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+
+	// Transform the JSON response into a slice of Database structs.
+	var databaseResponse serverpilot.DatabaseResponse
+
+	decoder := json.NewDecoder(strings.NewReader(resp))
+	err = decoder.Decode(&databaseResponse)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidJson, err)
+	}
+
+	return databaseResponse.Data, nil
+}
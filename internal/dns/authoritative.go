@@ -0,0 +1,376 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+var ErrDelegation = errors.New("could not follow the delegation")
+
+// RootServers are the addresses of the root nameservers (a, b, c, d, e and f.root-servers.net), where every
+// delegation walk starts.
+var RootServers = []string{
+	"198.41.0.4:53",
+	"170.247.170.2:53",
+	"192.33.4.12:53",
+	"199.7.91.13:53",
+	"192.203.230.10:53",
+	"192.5.5.241:53",
+}
+
+const (
+	// maxReferrals is how many referrals (and CNAMEs) are followed before giving up, in case of a loop.
+	maxReferrals = 16
+	// maxDepth is how many nameserver names without glue are resolved within each other.
+	maxDepth = 4
+	// queryTimeout is how long to wait for each nameserver to answer.
+	queryTimeout = 3 * time.Second
+	// maxUDPSize is the largest response we accept over UDP, advertised with EDNS(0).
+	maxUDPSize = 1232
+)
+
+// Exchanger sends a DNS query to a nameserver (host:port), and returns its response.
+type Exchanger func(ctx context.Context, server string, query dnsmessage.Message) (dnsmessage.Message, error)
+
+// AuthoritativeResolver answers queries by walking the delegation from the root nameservers, and asking each
+// domain's authoritative nameservers directly. Nothing is cached, so the answers reflect DNS changes right away.
+type AuthoritativeResolver struct {
+	roots    []string
+	exchange Exchanger
+}
+
+// NewAuthoritativeResolver returns an AuthoritativeResolver that starts from the RootServers. A nil exchange sends
+// the queries over UDP, retrying over TCP when the response is truncated.
+func NewAuthoritativeResolver(exchange Exchanger) *AuthoritativeResolver {
+	if exchange == nil {
+		exchange = exchangeUDP
+	}
+	return &AuthoritativeResolver{roots: RootServers, exchange: exchange}
+}
+
+// LookupIP looks up the IPv4 and IPv6 addresses of the host with its authoritative nameservers.
+func (r *AuthoritativeResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	a, errA := r.resolve(ctx, host, dnsmessage.TypeA, 0)
+	aaaa, errAAAA := r.resolve(ctx, host, dnsmessage.TypeAAAA, 0)
+	if err := lookupIPError(errA, errAAAA); err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, rr := range append(a, aaaa...) {
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			ips = append(ips, net.IP(body.A[:]))
+		case *dnsmessage.AAAAResource:
+			ips = append(ips, net.IP(body.AAAA[:]))
+		}
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return ips, nil
+}
+
+// LookupNS looks up the nameservers of the host with its authoritative nameservers.
+func (r *AuthoritativeResolver) LookupNS(ctx context.Context, host string) ([]*net.NS, error) {
+	answers, err := r.resolve(ctx, host, dnsmessage.TypeNS, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var ns []*net.NS
+	for _, rr := range answers {
+		if body, ok := rr.Body.(*dnsmessage.NSResource); ok {
+			ns = append(ns, &net.NS{Host: body.NS.String()})
+		}
+	}
+	if len(ns) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return ns, nil
+}
+
+// lookupIPError returns the first error of the A and AAAA lookups, unless it only means the host doesn't exist.
+// Returning the addresses of one family when the lookup of the other failed would make the domain look like it has
+// no records of that family.
+func lookupIPError(errs ...error) error {
+	for _, err := range errs {
+		var dnsErr *net.DNSError
+		if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve walks the delegation for the name, following referrals and CNAMEs, and returns the records of the type.
+// An empty result means the name exists but has no records of the type.
+func (r *AuthoritativeResolver) resolve(ctx context.Context, host string, t dnsmessage.Type, depth int) ([]dnsmessage.Resource, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("%w: nameservers for %s are nested too deeply", ErrDelegation, host)
+	}
+
+	name, err := dnsmessage.NewName(fqdn(host))
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: host}
+	}
+
+	servers := r.roots
+	for i := 0; i < maxReferrals; i++ {
+		resp, err := r.query(ctx, servers, name, t)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.RCode {
+		case dnsmessage.RCodeSuccess:
+		case dnsmessage.RCodeNameError:
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		default:
+			return nil, &net.DNSError{Err: "server misbehaving: " + resp.RCode.String(), Name: host}
+		}
+
+		if len(resp.Answers) > 0 {
+			records, target := answerFor(resp.Answers, name, t)
+			if len(records) > 0 || target == nil {
+				return records, nil
+			}
+			// Only the CNAME was given, so start over for its target
+			name, servers = *target, r.roots
+			continue
+		}
+
+		nameservers := referral(resp)
+		if resp.Authoritative || len(nameservers) == 0 {
+			// The name exists, but has no records of the type
+			return nil, nil
+		}
+
+		servers, err = r.addressesOf(ctx, nameservers, resp.Additionals, depth)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("%w: too many referrals for %s", ErrDelegation, host)
+}
+
+// query asks each server in turn until one of them answers.
+func (r *AuthoritativeResolver) query(ctx context.Context, servers []string, name dnsmessage.Name, t dnsmessage.Type) (dnsmessage.Message, error) {
+	q := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Intn(1 << 16))},
+		Questions: []dnsmessage.Question{{Name: name, Type: t, Class: dnsmessage.ClassINET}},
+	}
+
+	var errs []error
+	for _, server := range servers {
+		resp, err := r.exchange(ctx, server, q)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return dnsmessage.Message{}, ctx.Err()
+		}
+		errs = append(errs, err)
+	}
+
+	return dnsmessage.Message{}, &net.DNSError{Err: errors.Join(errs...).Error(), Name: name.String()}
+}
+
+// addressesOf returns the addresses of the nameservers, from the glue records when there are any, or by resolving
+// their names.
+func (r *AuthoritativeResolver) addressesOf(ctx context.Context, nameservers []dnsmessage.Name, additionals []dnsmessage.Resource, depth int) ([]string, error) {
+	var addrs []string
+	for _, ns := range nameservers {
+		for _, rr := range additionals {
+			if !strings.EqualFold(rr.Header.Name.String(), ns.String()) {
+				continue
+			}
+			if body, ok := rr.Body.(*dnsmessage.AResource); ok {
+				addrs = append(addrs, net.JoinHostPort(net.IP(body.A[:]).String(), "53"))
+			}
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs, nil
+	}
+
+	// Without glue, the nameservers are in another zone, so resolve them from the root too
+	var lastErr error
+	for _, ns := range nameservers {
+		records, err := r.resolve(ctx, ns.String(), dnsmessage.TypeA, depth+1)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, rr := range records {
+			if body, ok := rr.Body.(*dnsmessage.AResource); ok {
+				addrs = append(addrs, net.JoinHostPort(net.IP(body.A[:]).String(), "53"))
+			}
+		}
+		if len(addrs) > 0 {
+			return addrs, nil
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("%w: no addresses for the nameservers", ErrDelegation)
+	}
+	return nil, lastErr
+}
+
+// answerFor returns the records of the type for the name, following any CNAMEs within the answers. When the chain
+// ends in a CNAME without records, its target is returned instead.
+func answerFor(answers []dnsmessage.Resource, name dnsmessage.Name, t dnsmessage.Type) ([]dnsmessage.Resource, *dnsmessage.Name) {
+	for i := 0; i < maxReferrals; i++ {
+		var records []dnsmessage.Resource
+		var cname *dnsmessage.Name
+		for _, rr := range answers {
+			if !strings.EqualFold(rr.Header.Name.String(), name.String()) {
+				continue
+			}
+			if rr.Header.Type == t {
+				records = append(records, rr)
+			}
+			if body, ok := rr.Body.(*dnsmessage.CNAMEResource); ok && t != dnsmessage.TypeCNAME {
+				cname = &body.CNAME
+			}
+		}
+
+		if len(records) > 0 || cname == nil {
+			return records, nil
+		}
+		name = *cname
+		if !hasRecordsFor(answers, name) {
+			return nil, cname
+		}
+	}
+
+	return nil, nil
+}
+
+func hasRecordsFor(answers []dnsmessage.Resource, name dnsmessage.Name) bool {
+	for _, rr := range answers {
+		if strings.EqualFold(rr.Header.Name.String(), name.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// referral returns the nameservers a response delegates to, if it is a referral.
+func referral(resp dnsmessage.Message) []dnsmessage.Name {
+	var nameservers []dnsmessage.Name
+	for _, rr := range resp.Authorities {
+		if body, ok := rr.Body.(*dnsmessage.NSResource); ok {
+			nameservers = append(nameservers, body.NS)
+		}
+	}
+	return nameservers
+}
+
+func fqdn(host string) string {
+	if strings.HasSuffix(host, ".") {
+		return host
+	}
+	return host + "."
+}
+
+// exchangeUDP sends the query over UDP, and retries over TCP when the response is truncated.
+func exchangeUDP(ctx context.Context, server string, query dnsmessage.Message) (dnsmessage.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	// Advertise a larger UDP size, so fewer responses are truncated
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(maxUDPSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return dnsmessage.Message{}, err
+	}
+	query.Additionals = append(query.Additionals, dnsmessage.Resource{Header: opt, Body: &dnsmessage.OPTResource{}})
+
+	packed, err := query.Pack()
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(packed); err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	buf := make([]byte, maxUDPSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return dnsmessage.Message{}, err
+		}
+
+		var resp dnsmessage.Message
+		// Ignore anything that isn't the response to our query
+		if resp.Unpack(buf[:n]) != nil || resp.ID != query.ID {
+			continue
+		}
+		if resp.Truncated {
+			return exchangeTCP(ctx, server, packed, query.ID)
+		}
+		return resp, nil
+	}
+}
+
+// exchangeTCP sends the packed query over TCP, where each message is prefixed with its length.
+func exchangeTCP(ctx context.Context, server string, packed []byte, id uint16) (dnsmessage.Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	msg := make([]byte, 2+len(packed))
+	binary.BigEndian.PutUint16(msg, uint16(len(packed)))
+	copy(msg[2:], packed)
+	if _, err := conn.Write(msg); err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return dnsmessage.Message{}, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf); err != nil {
+		return dnsmessage.Message{}, err
+	}
+	if resp.ID != id {
+		return dnsmessage.Message{}, fmt.Errorf("%w: response id does not match the query", ErrDelegation)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,249 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/net/dns/dnsmessage"
+	"gotest.tools/v3/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestAuthoritativeResolver(t *testing.T) {
+	t.Run("it should walk the delegation to the authoritative nameservers", func(t *testing.T) {
+		var tests = []struct {
+			name string
+			host string
+			want []string
+		}{
+			{"with glue", "example.com", []string{"1.2.3.4", "2001:db8::1"}},
+			{"cname within the zone", "www.example.com", []string{"1.2.3.4", "2001:db8::1"}},
+			{"nameservers without glue", "other.com", []string{"5.6.7.8"}},
+			{"cname to another zone", "alias.example.com", []string{"5.6.7.8"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				r := newAuthoritativeResolverWithStubs()
+
+				ips, err := r.LookupIP(context.Background(), tt.host)
+
+				assert.NilError(t, err)
+				var got []string
+				for _, ip := range ips {
+					got = append(got, ip.String())
+				}
+				assert.DeepEqual(t, got, tt.want)
+			})
+		}
+	})
+
+	t.Run("it should look up the nameservers of a domain", func(t *testing.T) {
+		r := newAuthoritativeResolverWithStubs()
+
+		ns, err := r.LookupNS(context.Background(), "example.com")
+
+		assert.NilError(t, err)
+		assert.Equal(t, len(ns), 1)
+		assert.Equal(t, ns[0].Host, "ns1.example.com.")
+	})
+
+	t.Run("it should return a not found error for a domain that doesn't exist", func(t *testing.T) {
+		r := newAuthoritativeResolverWithStubs()
+
+		_, err := r.LookupIP(context.Background(), "missing.com")
+
+		var dnsErr *net.DNSError
+		assert.Assert(t, errors.As(err, &dnsErr))
+		assert.Assert(t, dnsErr.IsNotFound)
+	})
+
+	t.Run("it should return the addresses of one family when the other has no records", func(t *testing.T) {
+		r := newAuthoritativeResolverWithStubs()
+
+		ips, err := r.LookupIP(context.Background(), "v6only.example.com")
+
+		assert.NilError(t, err)
+		assert.Equal(t, len(ips), 1)
+		assert.Equal(t, ips[0].String(), "2001:db8::2")
+	})
+
+	t.Run("it should return an error when the lookup of one family fails", func(t *testing.T) {
+		r := newAuthoritativeResolverWithStubs()
+
+		_, err := r.LookupIP(context.Background(), "flaky.example.com")
+
+		var dnsErr *net.DNSError
+		assert.Assert(t, errors.As(err, &dnsErr))
+		assert.Assert(t, !dnsErr.IsNotFound)
+		assert.ErrorContains(t, err, "server misbehaving")
+	})
+
+	t.Run("it should return an error when no nameserver answers", func(t *testing.T) {
+		r := newAuthoritativeResolverWithStubs()
+		r.roots = []string{"192.0.2.1:53"}
+
+		_, err := r.LookupIP(context.Background(), "example.com")
+
+		assert.ErrorContains(t, err, "unreachable")
+	})
+
+	t.Run("it should send queries over udp", func(t *testing.T) {
+		server := startNameserver(t, map[string]string{"example.com.": "1.2.3.4"})
+		r := NewAuthoritativeResolver(nil)
+		r.roots = []string{server}
+
+		ips, err := r.LookupIP(context.Background(), "example.com")
+
+		assert.NilError(t, err)
+		assert.Equal(t, len(ips), 1)
+		assert.Equal(t, ips[0].String(), "1.2.3.4")
+	})
+}
+
+// newAuthoritativeResolverWithStubs returns a resolver for a small, made up DNS tree. The com. nameserver delegates
+// example.com. with glue, and other.com. to a nameserver in the net. zone without glue.
+func newAuthoritativeResolverWithStubs() *AuthoritativeResolver {
+	nameservers := map[string]func(q dnsmessage.Question) dnsmessage.Message{
+		"root:53": func(q dnsmessage.Question) dnsmessage.Message {
+			if strings.HasSuffix(q.Name.String(), ".net.") {
+				return delegate("net.", "a.gtld.net.", "10.0.0.3")
+			}
+			return delegate("com.", "a.gtld.net.", "10.0.0.1")
+		},
+		"10.0.0.1:53": func(q dnsmessage.Question) dnsmessage.Message {
+			switch {
+			case strings.HasSuffix(q.Name.String(), "example.com."):
+				return delegate("example.com.", "ns1.example.com.", "10.0.0.2")
+			case q.Name.String() == "other.com.":
+				return delegate("other.com.", "ns.dns-host.net.", "")
+			}
+			return dnsmessage.Message{Header: dnsmessage.Header{Response: true, RCode: dnsmessage.RCodeNameError}}
+		},
+		"10.0.0.2:53": func(q dnsmessage.Question) dnsmessage.Message {
+			var answers []dnsmessage.Resource
+			switch q.Name.String() + " " + q.Type.String() {
+			case "example.com. TypeA":
+				answers = []dnsmessage.Resource{aRecord("example.com.", "1.2.3.4")}
+			case "example.com. TypeAAAA":
+				answers = []dnsmessage.Resource{aRecord("example.com.", "2001:db8::1")}
+			case "example.com. TypeNS":
+				answers = []dnsmessage.Resource{nsRecord("example.com.", "ns1.example.com.")}
+			case "www.example.com. TypeA":
+				answers = []dnsmessage.Resource{cnameRecord("www.example.com.", "example.com."), aRecord("example.com.", "1.2.3.4")}
+			case "www.example.com. TypeAAAA":
+				answers = []dnsmessage.Resource{cnameRecord("www.example.com.", "example.com."), aRecord("example.com.", "2001:db8::1")}
+			case "alias.example.com. TypeA", "alias.example.com. TypeAAAA":
+				answers = []dnsmessage.Resource{cnameRecord("alias.example.com.", "other.com.")}
+			case "v6only.example.com. TypeAAAA", "flaky.example.com. TypeAAAA":
+				answers = []dnsmessage.Resource{aRecord(q.Name.String(), "2001:db8::2")}
+			case "flaky.example.com. TypeA":
+				return dnsmessage.Message{Header: dnsmessage.Header{Response: true, RCode: dnsmessage.RCodeServerFailure}}
+			}
+			return authoritative(answers...)
+		},
+		"10.0.0.3:53": func(q dnsmessage.Question) dnsmessage.Message {
+			if q.Name.String() == "ns.dns-host.net." && q.Type == dnsmessage.TypeA {
+				return authoritative(aRecord("ns.dns-host.net.", "10.0.0.4"))
+			}
+			return authoritative()
+		},
+		"10.0.0.4:53": func(q dnsmessage.Question) dnsmessage.Message {
+			if q.Name.String() == "other.com." && q.Type == dnsmessage.TypeA {
+				return authoritative(aRecord("other.com.", "5.6.7.8"))
+			}
+			return authoritative()
+		},
+	}
+
+	r := NewAuthoritativeResolver(func(ctx context.Context, server string, query dnsmessage.Message) (dnsmessage.Message, error) {
+		answer, ok := nameservers[server]
+		if !ok {
+			return dnsmessage.Message{}, errors.New(server + " is unreachable")
+		}
+		resp := answer(query.Questions[0])
+		resp.ID = query.ID
+		return resp, nil
+	})
+	r.roots = []string{"root:53"}
+
+	return r
+}
+
+func delegate(zone, ns, glue string) dnsmessage.Message {
+	m := dnsmessage.Message{
+		Header:      dnsmessage.Header{Response: true},
+		Authorities: []dnsmessage.Resource{nsRecord(zone, ns)},
+	}
+	if glue != "" {
+		m.Additionals = []dnsmessage.Resource{aRecord(ns, glue)}
+	}
+	return m
+}
+
+func authoritative(answers ...dnsmessage.Resource) dnsmessage.Message {
+	return dnsmessage.Message{Header: dnsmessage.Header{Response: true, Authoritative: true}, Answers: answers}
+}
+
+// aRecord returns an A record, or an AAAA record for an IPv6 address.
+func aRecord(name, ip string) dnsmessage.Resource {
+	parsed := net.ParseIP(ip)
+	if v4 := parsed.To4(); v4 != nil {
+		var a [4]byte
+		copy(a[:], v4)
+		return dnsmessage.Resource{Header: resourceHeader(name, dnsmessage.TypeA), Body: &dnsmessage.AResource{A: a}}
+	}
+	var aaaa [16]byte
+	copy(aaaa[:], parsed)
+	return dnsmessage.Resource{Header: resourceHeader(name, dnsmessage.TypeAAAA), Body: &dnsmessage.AAAAResource{AAAA: aaaa}}
+}
+
+func nsRecord(name, ns string) dnsmessage.Resource {
+	return dnsmessage.Resource{Header: resourceHeader(name, dnsmessage.TypeNS), Body: &dnsmessage.NSResource{NS: dnsmessage.MustNewName(ns)}}
+}
+
+func cnameRecord(name, target string) dnsmessage.Resource {
+	return dnsmessage.Resource{Header: resourceHeader(name, dnsmessage.TypeCNAME), Body: &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(target)}}
+}
+
+func resourceHeader(name string, t dnsmessage.Type) dnsmessage.ResourceHeader {
+	return dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: t, Class: dnsmessage.ClassINET, TTL: 60}
+}
+
+// startNameserver answers A queries over UDP with the given records, until the test ends. It returns its address.
+func startNameserver(t *testing.T, records map[string]string) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var query dnsmessage.Message
+			if query.Unpack(buf[:n]) != nil || len(query.Questions) == 0 {
+				continue
+			}
+			q := query.Questions[0]
+
+			resp := authoritative()
+			if ip, ok := records[q.Name.String()]; ok && q.Type == dnsmessage.TypeA {
+				resp = authoritative(aRecord(q.Name.String(), ip))
+			}
+			resp.ID = query.ID
+			resp.RecursionAvailable = true
+			resp.Questions = query.Questions
+
+			packed, _ := resp.Pack()
+			conn.WriteTo(packed, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
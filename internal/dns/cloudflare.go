@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -18,11 +19,15 @@ var (
 // PerPage How many zones/records to fetch per page.
 const PerPage = 50
 
+// DefaultCloudflareBaseUrl is the base url of the Cloudflare API.
+const DefaultCloudflareBaseUrl = "https://api.cloudflare.com/client/v4"
+
 // CloudflareResolver is a DNS resolver that uses the Cloudflare API to resolve DNS records.
 type CloudflareResolver struct {
-	l      *log.Logger
-	parent IpResolver
-	c      http.CachingRateLimitedClient
+	l       *log.Logger
+	parent  IpResolver
+	c       http.CachingRateLimitedClient
+	baseUrl string
 }
 
 // Credentials are the credentials used to authenticate with the Cloudflare API.
@@ -73,38 +78,84 @@ type CloudflareResponse[T any] struct {
 	Messages   []CodedMessage `json:"messages"`
 }
 
+// CloudflareError is an error response from the Cloudflare API, along with the errors listed in its body. The
+// StatusCode is 200 when the request succeeded but the response says it was unsuccessful.
+type CloudflareError struct {
+	StatusCode int
+	Errors     []CodedMessage
+	err        *http.StatusError
+}
+
+func (e *CloudflareError) Error() string {
+	msg := fmt.Sprintf("cloudflare api error (%d)", e.StatusCode)
+
+	var messages []string
+	for _, m := range e.Errors {
+		messages = append(messages, fmt.Sprintf("%s [%d]", m.Message, m.Code))
+	}
+	if len(messages) > 0 {
+		msg = fmt.Sprintf("%s: %s", msg, strings.Join(messages, ", "))
+	}
+
+	switch {
+	case e.StatusCode == 400 || e.StatusCode == 401 || e.StatusCode == 403:
+		msg += " (check the Cloudflare email and API key you entered)"
+	case e.StatusCode == 429:
+		msg += " (rate limited, try again later or lower --cloudflare-rate-limit)"
+	case e.StatusCode >= 500:
+		msg += " (Cloudflare is having problems, try again later)"
+	}
+
+	return msg
+}
+
+func (e *CloudflareError) Unwrap() error {
+	if e.err == nil {
+		return nil
+	}
+	return e.err
+}
+
 // NewCloudflareResolver creates a new CloudflareResolver. Caching and rate limiting of the API requests is handled by the http.CachingRateLimitedClient.
 // The nameservers are used to determine if the domain is managed by the Cloudflare account that we have credentials for.
-func NewCloudflareResolver(l *log.Logger, parent IpResolver, c http.CachingRateLimitedClient) *CloudflareResolver {
+// An empty baseUrl uses the DefaultCloudflareBaseUrl.
+func NewCloudflareResolver(l *log.Logger, parent IpResolver, c http.CachingRateLimitedClient, baseUrl string) *CloudflareResolver {
+	if baseUrl == "" {
+		baseUrl = DefaultCloudflareBaseUrl
+	}
+
 	return &CloudflareResolver{
-		l:      l,
-		parent: parent,
-		c:      c,
+		l:       l,
+		parent:  parent,
+		c:       c,
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
 	}
 }
 
 // Resolve resolves the domain using the Cloudflare API. It implements the IpResolver interface.
-func (r *CloudflareResolver) Resolve(domain UnresolvedDomain) ([]string, error) {
+func (r *CloudflareResolver) Resolve(ctx context.Context, domain UnresolvedDomain) ([]string, error) {
 	creds := domain.CloudflareMetadata.CloudflareCredentials
 
 	if creds == nil {
 		return nil, fmt.Errorf("%w: no credentials provided", ErrCouldNotMakeRequest)
 	}
 
-	zone, err := r.getZoneForDomain(domain.Name, creds)
+	zone, err := r.getZoneForDomain(ctx, domain.Name, creds)
 	if err != nil {
 		return nil, err
 	}
+	traceStep(ctx, "matched Cloudflare zone %s (%s)", getBaseDomain(domain.Name), zone.Id)
 
-	records, err := r.getDnsRecordsForZone(zone, creds)
+	records, err := r.getDnsRecordsForZone(ctx, zone, creds)
 	if err != nil {
 		return nil, err
 	}
+	traceStep(ctx, "fetched %d DNS records from the zone", len(records))
 
-	return r.findMatchingRecord(domain.Name, records)
+	return r.findMatchingRecord(ctx, domain.Name, records)
 }
 
-func (r *CloudflareResolver) findMatchingRecord(domain string, records []DnsRecord) ([]string, error) {
+func (r *CloudflareResolver) findMatchingRecord(ctx context.Context, domain string, records []DnsRecord) ([]string, error) {
 	var matched []string
 
 	// Find the DNS record for the domain
@@ -121,37 +172,38 @@ func (r *CloudflareResolver) findMatchingRecord(domain string, records []DnsReco
 			if record.Type == "CNAME" {
 				target := record.Content
 
-				// If the target is for the same base domain, then re-check the records for a matching A record
+				// If the target is for the same base domain, then re-check the records for a matching A or AAAA record
 				if getBaseDomain(target) == getBaseDomain(domain) {
-					return r.findMatchingRecord(target, records)
+					ctx := traceStep(ctx, "record CNAME %s -> %s, within the zone", record.Name, target)
+					return r.findMatchingRecord(ctx, target, records)
 				}
 
-				return r.parent.Resolve(UnresolvedDomain{Name: target})
+				ctx := traceStep(ctx, "record CNAME %s -> %s, outside the zone", record.Name, target)
+				return r.parent.Resolve(ctx, UnresolvedDomain{Name: target})
 			}
 
-			if record.Type == "A" {
+			if record.Type == "A" || record.Type == "AAAA" {
+				traceStep(ctx, "record %s %s -> %s", record.Type, record.Name, record.Content)
 				matched = append(matched, record.Content)
 			}
 		}
 	}
 
+	if len(matched) == 0 {
+		traceStep(ctx, "no A, AAAA or CNAME records match %s", domain)
+	}
+
 	return matched, nil
 }
 
-func (r *CloudflareResolver) getZoneForDomain(domain string, creds *Credentials) (Zone, error) {
+func (r *CloudflareResolver) getZoneForDomain(ctx context.Context, domain string, creds *Credentials) (Zone, error) {
 	baseDomain := getBaseDomain(domain)
-	endpoint := "https://api.cloudflare.com/client/v4/zones?name=" + baseDomain
+	endpoint := r.baseUrl + "/zones?name=" + baseDomain
 	request := r.makeCloudflareRequest(endpoint, creds)
-	contents, err := r.c.GetFromCacheOrFetchWithRateLimit(request)
+	cloudflareResponse, err := getCloudflareResponse[[]Zone](ctx, r.c, request)
 	if err != nil {
 		return Zone{}, err
 	}
-	cloudflareResponse := CloudflareResponse[[]Zone]{}
-	// Unmarshal the response into a CloudflareResponse
-	err = json.Unmarshal([]byte(contents), &cloudflareResponse)
-	if err != nil {
-		return Zone{}, fmt.Errorf("error while unmarshalling response body: %s", err)
-	}
 
 	if len(cloudflareResponse.Result) > 0 {
 		return cloudflareResponse.Result[0], nil
@@ -167,11 +219,11 @@ func (r *CloudflareResolver) makeCloudflareRequest(url string, creds *Credential
 		"Content-Type": "application/json",
 	}
 
-	return http.Request{url, headers}
+	return http.Request{Url: url, Headers: headers}
 }
 
-func (r *CloudflareResolver) getDnsRecordsForZone(z Zone, creds *Credentials) ([]DnsRecord, error) {
-	endpoint := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/dns_records", z.Id)
+func (r *CloudflareResolver) getDnsRecordsForZone(ctx context.Context, z Zone, creds *Credentials) ([]DnsRecord, error) {
+	endpoint := fmt.Sprintf("%s/zones/%s/dns_records", r.baseUrl, z.Id)
 
 	page := 1
 	haveMadeRequest := false
@@ -181,16 +233,12 @@ func (r *CloudflareResolver) getDnsRecordsForZone(z Zone, creds *Credentials) ([
 
 	for !haveMadeRequest || lastResponse.ResultInfo.Page < lastResponse.ResultInfo.TotalPages {
 		request := r.makeCloudflareRequest(fmt.Sprintf("%s?page=%d&per_page=%d", endpoint, page, PerPage), creds)
-		contents, err := r.c.GetFromCacheOrFetchWithRateLimit(request)
+		response, err := getCloudflareResponse[[]DnsRecord](ctx, r.c, request)
 		if err != nil {
 			return nil, err
 		}
 		haveMadeRequest = true
-		// Unmarshal the response into a CloudflareResponse
-		err = json.Unmarshal([]byte(contents), &lastResponse)
-		if err != nil {
-			return nil, fmt.Errorf("error while unmarshalling response body: %s", err)
-		}
+		lastResponse = response
 
 		for _, item := range lastResponse.Result {
 			items = append(items, item)
@@ -202,6 +250,35 @@ func (r *CloudflareResolver) getDnsRecordsForZone(z Zone, creds *Credentials) ([
 	return items, nil
 }
 
+// getCloudflareResponse makes the request and unmarshals the response. Error responses, and responses that say they
+// were unsuccessful, are returned as a CloudflareError.
+func getCloudflareResponse[T any](ctx context.Context, c http.CachingRateLimitedClient, request http.Request) (CloudflareResponse[T], error) {
+	var response CloudflareResponse[T]
+
+	contents, err := c.GetFromCacheOrFetchWithRateLimit(ctx, request)
+	if err != nil {
+		var statusErr *http.StatusError
+		if errors.As(err, &statusErr) {
+			// The body lists the reasons for the error when it came from the API itself
+			_ = json.Unmarshal([]byte(statusErr.Body), &response)
+			return response, &CloudflareError{StatusCode: statusErr.StatusCode, Errors: response.Errors, err: statusErr}
+		}
+		return response, err
+	}
+
+	// Unmarshal the response into a CloudflareResponse
+	err = json.Unmarshal([]byte(contents), &response)
+	if err != nil {
+		return response, fmt.Errorf("error while unmarshalling response body: %s", err)
+	}
+
+	if !response.Success {
+		return response, &CloudflareError{StatusCode: 200, Errors: response.Errors}
+	}
+
+	return response, nil
+}
+
 func getDomainsText(domains []string, totalToShow int) string {
 	var firstFewDomains []string
 
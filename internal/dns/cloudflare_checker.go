@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
@@ -53,15 +54,15 @@ type NameserverDomains struct {
 func NewCloudflareCredentialsChecker(l *log.Logger, p CredentialsPrompter, nsLookup NsLookupFunc) *CloudflareCredentialsChecker {
 	// Default to net.LookupNS
 	if nsLookup == nil {
-		nsLookup = net.LookupNS
+		nsLookup = net.DefaultResolver.LookupNS
 	}
 
 	return &CloudflareCredentialsChecker{l: l, p: p, lookupNs: nsLookup}
 }
 
 // IsBehindCloudFlare checks if the domain is behind CloudFlare by looking up the nameservers for the base domain.
-func (c *CloudflareCredentialsChecker) IsBehindCloudFlare(domain string) bool {
-	ns, _ := c.GetNameserversForBaseDomain(domain)
+func (c *CloudflareCredentialsChecker) IsBehindCloudFlare(ctx context.Context, domain string) bool {
+	ns, _ := c.GetNameserversForBaseDomain(ctx, domain)
 
 	for _, n := range ns {
 		// Check if the nameserver format matches *.ns.cloudflare.com
@@ -74,13 +75,13 @@ func (c *CloudflareCredentialsChecker) IsBehindCloudFlare(domain string) bool {
 }
 
 // GetNameserversForBaseDomain looks up the nameservers for the base domain. It caches the nameservers for each domain so additional lookups are not needed.
-func (c *CloudflareCredentialsChecker) GetNameserversForBaseDomain(domain string) ([]string, error) {
+func (c *CloudflareCredentialsChecker) GetNameserversForBaseDomain(ctx context.Context, domain string) ([]string, error) {
 	baseDomain := getBaseDomain(domain)
 
 	// Check if we've already looked up the nameservers for this domain
 	if c.cachedNs == nil || c.cachedNs[baseDomain] == nil {
 		c.l.Println("Looking up nameservers for", baseDomain, "...")
-		ns, _ := c.lookupNs(baseDomain)
+		ns, _ := c.lookupNs(ctx, baseDomain)
 		var nsStrings []string
 		for _, n := range ns {
 			// Remove trailing dot
@@ -114,20 +115,47 @@ func (c *CloudflareCredentialsChecker) checkDomains(domains []UnresolvedDomain)
 	return nameserverDomains, nil
 }
 
+// CredentialsProvider is given every Cloudflare account (unique set of nameservers) that was detected. It returns the
+// accounts with Credentials set for the ones that should be checked using the Cloudflare API.
+type CredentialsProvider func(accounts []NameserverDomains) []NameserverDomains
+
+// PromptForCredentials interactively asks for the API credentials of each Cloudflare account.
 func (c *CloudflareCredentialsChecker) PromptForCredentials(domains []UnresolvedDomain) []UnresolvedDomain {
+	return c.AssignCredentials(domains, c.promptForAccounts)
+}
+
+// AssignCredentials gets the credentials for each Cloudflare account from the provider, and sets them on the
+// matching domains.
+func (c *CloudflareCredentialsChecker) AssignCredentials(domains []UnresolvedDomain, provider CredentialsProvider) []UnresolvedDomain {
 	nameserverDomains, err := c.checkDomains(domains)
 	if err != nil {
 		return nil
 	}
 
+	result := provider(nameserverDomains)
+
+	// Loop through all the domains and set the matching credentials
+	for i, domain := range domains {
+		for _, nsd := range result {
+			if contains(nsd.Domains, domain.Name) {
+				domains[i].CloudflareMetadata.CloudflareCredentials = nsd.Credentials
+				break
+			}
+		}
+	}
+
+	return domains
+}
+
+func (c *CloudflareCredentialsChecker) promptForAccounts(nameserverDomains []NameserverDomains) []NameserverDomains {
 	validYesNoResponses := []string{"y", "Y", "n", "N"}
 
 	// The first thing we want it to say is the number of accounts detected, and ask if they want to enter credentials
 	response := c.p.Prompt(fmt.Sprintf("Detected %v CloudFlare accounts. Do you want to use the CloudFlare API to check DNS records? [y/N]", len(nameserverDomains)), "N", validYesNoResponses)
 
-	// If they say no, then we should just return the original domains
+	// If they say no, then we should leave all the domains without credentials
 	if response == "n" || response == "N" {
-		return domains
+		return nil
 	}
 
 	// Then the prompter should be called for each unique nameserver
@@ -139,17 +167,7 @@ func (c *CloudflareCredentialsChecker) PromptForCredentials(domains []Unresolved
 		result = append(result, nsd)
 	}
 
-	// Loop through all the domains and set the matching credentials
-	for i, domain := range domains {
-		for _, nsd := range result {
-			if contains(nsd.Domains, domain.Name) {
-				domains[i].CloudflareMetadata.CloudflareCredentials = nsd.Credentials
-				break
-			}
-		}
-	}
-
-	return domains
+	return result
 }
 
 func (c *CloudflareCredentialsChecker) promptForCredentials(nsd NameserverDomains) *Credentials {
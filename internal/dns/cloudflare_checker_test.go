@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"context"
 	"gotest.tools/v3/assert"
 	"io"
 	"log"
@@ -24,7 +25,7 @@ func TestCloudflareCredentialsChecker(t *testing.T) {
 			t.Run(tt.name, func(t *testing.T) {
 				checker := newCloudflareCredentialsCheckerWithStubs()
 
-				got := checker.IsBehindCloudFlare(tt.domain)
+				got := checker.IsBehindCloudFlare(context.Background(), tt.domain)
 
 				assert.DeepEqual(t, got, tt.want)
 			})
@@ -34,13 +35,13 @@ func TestCloudflareCredentialsChecker(t *testing.T) {
 	t.Run("it should cache nameserver lookups for the base domain", func(t *testing.T) {
 		spyCalls := 0
 		checker := newCloudflareCredentialsCheckerWithStubs()
-		checker.lookupNs = func(host string) ([]*net.NS, error) {
+		checker.lookupNs = func(ctx context.Context, host string) ([]*net.NS, error) {
 			spyCalls++
-			return NsLookupStub(host)
+			return NsLookupStub(ctx, host)
 		}
 
-		checker.IsBehindCloudFlare("example.com")
-		checker.IsBehindCloudFlare("sub.example.com")
+		checker.IsBehindCloudFlare(context.Background(), "example.com")
+		checker.IsBehindCloudFlare(context.Background(), "sub.example.com")
 
 		assert.Equal(t, spyCalls, 1)
 	})
@@ -345,6 +346,30 @@ func TestCloudflareCredentialsChecker(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("it should assign credentials from a provider without prompting", func(t *testing.T) {
+		domains := []UnresolvedDomain{
+			{Name: "domain-behind-cloudflare.com", CloudflareMetadata: &CloudflareDomainMetadata{BaseDomainNameservers: []string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"}}},
+			{Name: "another-domain-behind-cloudflare.com", CloudflareMetadata: &CloudflareDomainMetadata{BaseDomainNameservers: []string{"baz.ns.cloudflare.com", "bing.ns.cloudflare.com"}}},
+			{Name: "example.com"},
+		}
+
+		spy := &SpyPrompter{}
+		checker := newCloudflareCredentialsCheckerWithStubs()
+		checker.p = spy
+
+		var gotAccounts int
+		got := checker.AssignCredentials(domains, func(accounts []NameserverDomains) []NameserverDomains {
+			gotAccounts = len(accounts)
+			accounts[0].Credentials = &Credentials{"foo@example.com", "1234567890"}
+			return accounts
+		})
+
+		assert.Equal(t, gotAccounts, 2)
+		assert.Equal(t, len(spy.Calls), 0)
+		assert.DeepEqual(t, got[0].CloudflareMetadata.CloudflareCredentials, &Credentials{"foo@example.com", "1234567890"})
+		assert.Assert(t, got[1].CloudflareMetadata.CloudflareCredentials == nil)
+	})
 }
 
 type ExpectedResponse struct {
@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/jfortunato/serverpilot-tools/internal/http"
@@ -15,7 +16,7 @@ func TestCloudflare(t *testing.T) {
 	t.Run("it should not be able to resolve a domain behind CloudFlare nameservers that we don't have api credentials for", func(t *testing.T) {
 		resolver := newCloudflareResolverWithStubs()
 
-		got, _ := resolver.Resolve(UnresolvedDomain{
+		got, _ := resolver.Resolve(context.Background(), UnresolvedDomain{
 			Name: "domain-behind-cloudflare.com",
 			CloudflareMetadata: &CloudflareDomainMetadata{
 				BaseDomainNameservers: []string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"},
@@ -66,6 +67,16 @@ func TestCloudflare(t *testing.T) {
 				},
 				[]string{"127.0.0.1", "127.0.0.2"},
 			},
+			{
+				"matches A and AAAA records",
+				"example.com",
+				[]DnsRecord{
+					{"A", "example.com", "127.0.0.1"},
+					{"AAAA", "example.com", "2001:db8::1"},
+					{"TXT", "example.com", "v=spf1 -all"},
+				},
+				[]string{"127.0.0.1", "2001:db8::1"},
+			},
 			{
 				"matched domain is a CNAME - matches A record",
 				"www.example.com",
@@ -106,7 +117,7 @@ func TestCloudflare(t *testing.T) {
 				resolver.c = &ClientStub{responses: combineResponses(stubbedZoneResponses, stubbedDnsResponses)}
 
 				// We always want these test have credentials for the domain, so stub the domain with credentials
-				got, _ := resolver.Resolve(UnresolvedDomain{Name: tt.domain, CloudflareMetadata: &CloudflareDomainMetadata{
+				got, _ := resolver.Resolve(context.Background(), UnresolvedDomain{Name: tt.domain, CloudflareMetadata: &CloudflareDomainMetadata{
 					BaseDomainNameservers: []string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"},
 					CloudflareCredentials: &Credentials{"foo@example.com", "123456789"},
 				}})
@@ -116,13 +127,28 @@ func TestCloudflare(t *testing.T) {
 		}
 	})
 
+	t.Run("it should make requests to the configured base url", func(t *testing.T) {
+		responses := combineResponses(
+			makeStubbedZoneResponse("http://localhost:8080/client/v4/zones?name=example.com", []Zone{{"1"}}),
+			makeStubbedDnsResponse("http://localhost:8080/client/v4/zones/1/dns_records?page=1&per_page=50", []DnsRecord{{"A", "example.com", "127.0.0.1"}}),
+		)
+		resolver := NewCloudflareResolver(log.New(io.Discard, "", 0), &IpResolverStub{}, &ClientStub{responses: responses}, "http://localhost:8080/client/v4/")
+
+		got, err := resolver.Resolve(context.Background(), UnresolvedDomain{Name: "example.com", CloudflareMetadata: &CloudflareDomainMetadata{
+			CloudflareCredentials: &Credentials{"foo@example.com", "123456789"},
+		}})
+
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, []string{"127.0.0.1"})
+	})
+
 	t.Run("it should return an error when no zone is found for the base domain", func(t *testing.T) {
 		stubbedZoneResponse := makeStubbedZoneResponse("https://api.cloudflare.com/client/v4/zones?name=example.com", []Zone{})
 
 		resolver := newCloudflareResolverWithStubs()
 		resolver.c = &ClientStub{responses: stubbedZoneResponse}
 
-		got, err := resolver.Resolve(UnresolvedDomain{
+		got, err := resolver.Resolve(context.Background(), UnresolvedDomain{
 			Name: "example.com",
 			CloudflareMetadata: &CloudflareDomainMetadata{
 				BaseDomainNameservers: []string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"},
@@ -149,7 +175,7 @@ func TestCloudflare(t *testing.T) {
 			"other-host.com": "127.0.0.8",
 		}}
 
-		got, _ := resolver.Resolve(UnresolvedDomain{
+		got, _ := resolver.Resolve(context.Background(), UnresolvedDomain{
 			Name: "www.example.com",
 			CloudflareMetadata: &CloudflareDomainMetadata{
 				BaseDomainNameservers: []string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"},
@@ -164,7 +190,7 @@ func TestCloudflare(t *testing.T) {
 		resolver := newCloudflareResolverWithStubs()
 		resolver.c = &ClientStub{errStub: errors.New("http error")}
 
-		got, _ := resolver.Resolve(UnresolvedDomain{
+		got, _ := resolver.Resolve(context.Background(), UnresolvedDomain{
 			Name: "example.com",
 			CloudflareMetadata: &CloudflareDomainMetadata{
 				BaseDomainNameservers: []string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"},
@@ -175,6 +201,51 @@ func TestCloudflare(t *testing.T) {
 		assert.Assert(t, got == nil)
 	})
 
+	t.Run("it should return the errors sent by the api", func(t *testing.T) {
+		var tests = []struct {
+			name    string
+			client  *ClientStub
+			want    *CloudflareError
+			wantMsg string
+		}{
+			{
+				"error response",
+				&ClientStub{errStub: &http.StatusError{StatusCode: 403, Body: `{"success": false, "errors": [{"code": 9103, "message": "Unknown X-Auth-Key or X-Auth-Email"}]}`}},
+				&CloudflareError{StatusCode: 403, Errors: []CodedMessage{{9103, "Unknown X-Auth-Key or X-Auth-Email"}}},
+				"cloudflare api error (403): Unknown X-Auth-Key or X-Auth-Email [9103] (check the Cloudflare email and API key you entered)",
+			},
+			{
+				"unsuccessful response",
+				&ClientStub{responses: map[string]string{
+					"https://api.cloudflare.com/client/v4/zones?name=example.com": `{"success": false, "errors": [{"code": 1000, "message": "Something went wrong"}]}`,
+				}},
+				&CloudflareError{StatusCode: 200, Errors: []CodedMessage{{1000, "Something went wrong"}}},
+				"cloudflare api error (200): Something went wrong [1000]",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resolver := newCloudflareResolverWithStubs()
+				resolver.c = tt.client
+
+				_, err := resolver.Resolve(context.Background(), UnresolvedDomain{
+					Name: "example.com",
+					CloudflareMetadata: &CloudflareDomainMetadata{
+						BaseDomainNameservers: []string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"},
+						CloudflareCredentials: &Credentials{"foo@example.com", "123456789"},
+					},
+				})
+
+				var cfErr *CloudflareError
+				assert.Assert(t, errors.As(err, &cfErr))
+				assert.Equal(t, cfErr.StatusCode, tt.want.StatusCode)
+				assert.DeepEqual(t, cfErr.Errors, tt.want.Errors)
+				assert.Equal(t, err.Error(), tt.wantMsg)
+			})
+		}
+	})
+
 	t.Run("it should not attempt an api request if the credentials dont match the nameservers", func(t *testing.T) {
 	})
 }
@@ -224,6 +295,7 @@ func newCloudflareResolverWithStubs() *CloudflareResolver {
 		log.New(io.Discard, "", 0),
 		&IpResolverStub{},
 		&ClientStub{},
+		"",
 	)
 }
 
@@ -244,7 +316,7 @@ type ClientStub struct {
 	calls     int
 }
 
-func (c *ClientStub) GetFromCacheOrFetchWithRateLimit(req http.Request) (string, error) {
+func (c *ClientStub) GetFromCacheOrFetchWithRateLimit(ctx context.Context, req http.Request) (string, error) {
 	c.calls++
 	return c.responses[req.Url], c.errStub
 }
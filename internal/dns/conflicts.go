@@ -0,0 +1,100 @@
+package dns
+
+import (
+	"context"
+	"github.com/jfortunato/serverpilot-tools/internal/progressbar"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"sort"
+	"strings"
+)
+
+// DomainConflict is a domain that is attached to more than one app. The www. and apex variants of a
+// domain are treated as the same domain, since only one app can really be serving them.
+type DomainConflict struct {
+	Domain string
+	Copies []DomainCopy
+}
+
+// DomainCopy is one of the apps a conflicting domain is attached to. The Status tells us if this copy is
+// the one that is live (OK), only partially live (PARTIAL), or if it is stale (INACTIVE).
+type DomainCopy struct {
+	Domain    string
+	AppServer serverpilot.AppServer
+	Status    int
+}
+
+// FindDomainConflicts groups all app domains by their apex form, and returns the ones attached to more
+// than one app.
+func FindDomainConflicts(appservers []serverpilot.AppServer) []DomainConflict {
+	copies := make(map[string][]DomainCopy)
+	apps := make(map[string]map[string]bool)
+
+	for _, appserver := range appservers {
+		for _, domain := range appserver.Domains {
+			apex := strings.TrimPrefix(strings.ToLower(domain), "www.")
+
+			copies[apex] = append(copies[apex], DomainCopy{Domain: domain, AppServer: appserver})
+
+			if apps[apex] == nil {
+				apps[apex] = make(map[string]bool)
+			}
+			apps[apex][appserver.Id] = true
+		}
+	}
+
+	var conflicts []DomainConflict
+	for apex, c := range copies {
+		// The same app having both the www. and apex variant is not a conflict
+		if len(apps[apex]) > 1 {
+			conflicts = append(conflicts, DomainConflict{Domain: apex, Copies: c})
+		}
+	}
+
+	// Keep the output stable
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Domain < conflicts[j].Domain
+	})
+
+	return conflicts
+}
+
+// ConflictDomains returns the name of every copy of every conflict, so they can be evaluated.
+func ConflictDomains(conflicts []DomainConflict) []string {
+	var domains []string
+	for _, conflict := range conflicts {
+		for _, c := range conflict.Copies {
+			if !contains(domains, c.Domain) {
+				domains = append(domains, c.Domain)
+			}
+		}
+	}
+	return domains
+}
+
+// CheckConflicts determines the status of each copy of the conflicting domains, based on the server the
+// copy's app is assigned to. The domains are the evaluated (and possibly Cloudflare enabled) domains
+// returned by EvaluateDomains. If the context is cancelled, only the conflicts that were fully checked are returned,
+// along with the context's error.
+func (c *DnsChecker) CheckConflicts(ctx context.Context, ticker progressbar.Ticker, conflicts []DomainConflict, domains []UnresolvedDomain) ([]DomainConflict, error) {
+	for i, conflict := range conflicts {
+		for j, cp := range conflict.Copies {
+			domain := UnresolvedDomain{Name: cp.Domain}
+			for _, d := range domains {
+				if d.Name == cp.Domain {
+					domain = d
+					break
+				}
+			}
+
+			conflicts[i].Copies[j].Status, _ = c.CheckStatus(ctx, domain, cp.AppServer.Server)
+			if ctx.Err() != nil {
+				return conflicts[:i], ctx.Err()
+			}
+
+			// Tick the progress bar
+			ticker.Tick()
+		}
+	}
+
+	return conflicts, nil
+}
@@ -0,0 +1,84 @@
+package dns
+
+import (
+	"context"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"gotest.tools/v3/assert"
+	"testing"
+)
+
+func TestDomainConflicts(t *testing.T) {
+	server1 := serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}
+	server2 := serverpilot.Server{Name: "server2", Ipaddress: "127.0.0.2"}
+
+	t.Run("it should find domains attached to more than one app", func(t *testing.T) {
+		var tests = []struct {
+			name       string
+			appservers []serverpilot.AppServer
+			want       []string
+		}{
+			{
+				"no conflicts",
+				[]serverpilot.AppServer{
+					{App: serverpilot.App{Id: "1", Domains: []string{"example.com"}}, Server: server1},
+					{App: serverpilot.App{Id: "2", Domains: []string{"other.com"}}, Server: server2},
+				},
+				nil,
+			},
+			{
+				"same domain on two apps",
+				[]serverpilot.AppServer{
+					{App: serverpilot.App{Id: "1", Domains: []string{"example.com"}}, Server: server1},
+					{App: serverpilot.App{Id: "2", Domains: []string{"example.com"}}, Server: server2},
+				},
+				[]string{"example.com"},
+			},
+			{
+				"www and apex variants on two apps",
+				[]serverpilot.AppServer{
+					{App: serverpilot.App{Id: "1", Domains: []string{"www.example.com"}}, Server: server1},
+					{App: serverpilot.App{Id: "2", Domains: []string{"Example.com"}}, Server: server2},
+				},
+				[]string{"example.com"},
+			},
+			{
+				"www and apex variants on the same app",
+				[]serverpilot.AppServer{
+					{App: serverpilot.App{Id: "1", Domains: []string{"example.com", "www.example.com"}}, Server: server1},
+				},
+				nil,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var got []string
+				for _, conflict := range FindDomainConflicts(tt.appservers) {
+					got = append(got, conflict.Domain)
+				}
+
+				assert.DeepEqual(t, got, tt.want)
+			})
+		}
+	})
+
+	t.Run("it should determine which copy of a conflicting domain is live", func(t *testing.T) {
+		conflicts := FindDomainConflicts([]serverpilot.AppServer{
+			{App: serverpilot.App{Id: "1", Domains: []string{"example.com"}}, Server: server1},
+			{App: serverpilot.App{Id: "2", Domains: []string{"www.example.com"}}, Server: server2},
+		})
+
+		checker := NewDnsChecker(&IpResolverStub{map[string]string{
+			"example.com":     "127.0.0.2",
+			"www.example.com": "127.0.0.2",
+		}}, nil)
+
+		got, _ := checker.CheckConflicts(context.Background(), &FakeTicker{}, conflicts, []UnresolvedDomain{{Name: "example.com"}, {Name: "www.example.com"}})
+
+		assert.Equal(t, len(got), 1)
+		assert.Equal(t, got[0].Copies[0].AppServer.Id, "1")
+		assert.Equal(t, got[0].Copies[0].Status, INACTIVE)
+		assert.Equal(t, got[0].Copies[1].AppServer.Id, "2")
+		assert.Equal(t, got[0].Copies[1].Status, OK)
+	})
+}
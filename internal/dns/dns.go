@@ -1,9 +1,11 @@
 package dns
 
 import (
+	"context"
 	"github.com/jfortunato/serverpilot-tools/internal/progressbar"
 	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
 	"golang.org/x/net/publicsuffix"
+	"net"
 	"strings"
 	"sync"
 )
@@ -12,6 +14,9 @@ const (
 	OK int = iota
 	INACTIVE
 	UNKNOWN
+	// PARTIAL means the domain resolves to the server and to other addresses, such as a migration that was left half
+	// done, or round-robin DNS that still includes an old host.
+	PARTIAL
 )
 
 type DnsChecker struct {
@@ -31,22 +36,65 @@ type AppDomainStatus struct {
 	Domain     string
 	ServerName string
 	Status     int
+	AddressMatches
+	// Trace is the steps taken to determine the status, and is only recorded when checking WithTracing.
+	Trace *Trace
+}
+
+// FamilyMatch is how the records of one address family (IPv4 or IPv6) compare with the server's address.
+type FamilyMatch int
+
+const (
+	// NoRecords means the domain has no records of the family, or couldn't be resolved.
+	NoRecords FamilyMatch = iota
+	// Match means one of the records is the server's address.
+	Match
+	// Mismatch means none of the records are the server's address.
+	Mismatch
+	// NotComparable means the domain has records of the family, but the server has no address of the family (or it
+	// isn't known, such as the IPv6 address of a dual-stack server) to compare them with.
+	NotComparable
+)
+
+func (m FamilyMatch) String() string {
+	switch m {
+	case Match:
+		return "match"
+	case Mismatch:
+		return "mismatch"
+	case NotComparable:
+		return "not comparable"
+	}
+	return "none"
+}
+
+// AddressMatches is how a domain's A and AAAA records compare with the server's IPv4 and IPv6 addresses.
+type AddressMatches struct {
+	IPv4 FamilyMatch
+	IPv6 FamilyMatch
+	// ForeignIps are the resolved addresses that aren't the server's.
+	ForeignIps []string
 }
 
 // UnresolvedDomain is the result of evaluating a domain's metadata, before it is resolved.
 type UnresolvedDomain struct {
-	Name               string
+	Name string
+	// Nameservers are the nameservers of the base domain, which were used to tell if it is behind Cloudflare.
+	Nameservers        []string
 	CloudflareMetadata *CloudflareDomainMetadata
 }
 
-func (c *DnsChecker) EvaluateDomains(ticker progressbar.Ticker, domains []string) []UnresolvedDomain {
+// EvaluateDomains looks up whether each domain is behind Cloudflare. If the context is cancelled, the domains evaluated
+// so far are returned along with the context's error.
+func (c *DnsChecker) EvaluateDomains(ctx context.Context, ticker progressbar.Ticker, domains []string) ([]UnresolvedDomain, error) {
 	var results []UnresolvedDomain
 
 	for _, domain := range domains {
+		// The nameservers are cached, so this doesn't look them up again
+		ns, _ := c.cfChecker.GetNameserversForBaseDomain(ctx, domain)
+
 		var cloudflareMetadata *CloudflareDomainMetadata
-		if c.cfChecker.IsBehindCloudFlare(domain) {
-			// Only get the nameservers if the domain is behind Cloudflare
-			ns, _ := c.cfChecker.GetNameserversForBaseDomain(domain)
+		if c.cfChecker.IsBehindCloudFlare(ctx, domain) {
 			cloudflareMetadata = &CloudflareDomainMetadata{
 				BaseDomainNameservers: ns,
 				CloudflareCredentials: nil, // Will be prompted for later
@@ -55,64 +103,97 @@ func (c *DnsChecker) EvaluateDomains(ticker progressbar.Ticker, domains []string
 
 		result := UnresolvedDomain{
 			Name:               domain,
+			Nameservers:        ns,
 			CloudflareMetadata: cloudflareMetadata,
 		}
 
+		// The nameserver lookups were interrupted, so we can't tell
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+
 		results = append(results, result)
 
 		// Tick the progress bar
 		ticker.Tick()
 	}
 
-	return results
+	return results, nil
 }
 
 // GetInactiveAppDomains will return a list of domains that are not resolving to the server they are
 // assigned to.
-func (c *DnsChecker) GetInactiveAppDomains(ticker progressbar.Ticker, domains []UnresolvedDomain, appservers []serverpilot.AppServer, includeUnknown bool) []AppDomainStatus {
+func (c *DnsChecker) GetInactiveAppDomains(ctx context.Context, ticker progressbar.Ticker, domains []UnresolvedDomain, appservers []serverpilot.AppServer, includeUnknown bool) ([]AppDomainStatus, error) {
 	var results []AppDomainStatus
 
-	for _, domain := range c.getAppDomainStatuses(ticker, domains, appservers) {
-		// Filter out the results for only the inactive domains
-		if domain.Status == INACTIVE || (includeUnknown && domain.Status == UNKNOWN) {
+	statuses, err := c.GetAppDomainStatuses(ctx, ticker, domains, appservers)
+	for _, domain := range statuses {
+		// Filter out the results for only the inactive (or partially inactive) domains
+		if domain.Status == INACTIVE || domain.Status == PARTIAL || (includeUnknown && domain.Status == UNKNOWN) {
 			results = append(results, domain)
 		}
 	}
 
-	return results
+	return results, err
 }
 
-// Resolve a list of UnresolvedDomains and determine it's "status" (OK, INACTIVE, UNKNOWN) based on the
-// server it is supposed to be pointing to.
-func (c *DnsChecker) getAppDomainStatuses(ticker progressbar.Ticker, domains []UnresolvedDomain, appservers []serverpilot.AppServer) []AppDomainStatus {
+// GetAppDomainStatuses resolves a list of UnresolvedDomains and determines it's "status" (OK, INACTIVE, UNKNOWN, PARTIAL) based
+// on the server it is supposed to be pointing to. If the context is cancelled, it stops starting new checks and waits
+// for the running ones to finish, then returns the statuses that were determined along with the context's error.
+func (c *DnsChecker) GetAppDomainStatuses(ctx context.Context, ticker progressbar.Ticker, domains []UnresolvedDomain, appservers []serverpilot.AppServer) ([]AppDomainStatus, error) {
 	var results = make([]AppDomainStatus, len(domains))
+	var checked = make([]bool, len(domains))
 
 	var sem = make(chan bool, 100) // Use a semaphore to limit the number of concurrent goroutines
 	var wg sync.WaitGroup
 	// Loop through each domain, and check if it resolves to the server
 	for i, domain := range domains {
-		sem <- true // Blocks if the channel is full
+		select {
+		case sem <- true: // Blocks if the channel is full
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
 
 		wg.Add(1)
 		go func(i int, domain UnresolvedDomain) {
 			defer wg.Done()
+			defer func() { <-sem }() // Release a spot
 
 			// Find the appserver that matches the domain
 			appserver := findMatchingAppServer(domain, appservers)
 
-			status := c.CheckStatus(domain, appserver.Server.Ipaddress)
+			// Each domain gets its own trace, when tracing
+			ctx, trace := startTrace(ctx, domain.Name)
+			status, matches := c.CheckStatus(ctx, domain, appserver.Server)
 
-			results[i] = AppDomainStatus{appserver.Id, domain.Name, appserver.Server.Name, status}
+			// A check that was interrupted would be reported as UNKNOWN, so leave it out instead
+			if ctx.Err() != nil {
+				return
+			}
+
+			results[i] = AppDomainStatus{appserver.Id, domain.Name, appserver.Server.Name, status, matches, trace}
+			checked[i] = true
 
 			// Tick the progress bar
 			ticker.Tick()
-
-			<-sem // Release a spot
 		}(i, domain)
 	}
 	wg.Wait()
 
-	return results
+	if ctx.Err() == nil {
+		return results, nil
+	}
+
+	var partial []AppDomainStatus
+	for i, result := range results {
+		if checked[i] {
+			partial = append(partial, result)
+		}
+	}
+
+	return partial, ctx.Err()
 }
 
 func findMatchingAppServer(domain UnresolvedDomain, appservers []serverpilot.AppServer) serverpilot.AppServer {
@@ -127,19 +208,91 @@ func findMatchingAppServer(domain UnresolvedDomain, appservers []serverpilot.App
 	return serverpilot.AppServer{}
 }
 
-func (c *DnsChecker) CheckStatus(domain UnresolvedDomain, serverIp string) int {
-	resolvedIps, err := c.r.Resolve(domain)
+// CheckStatus resolves the domain and compares its addresses with the server's, for each address family. The domain
+// is OK when it only resolves to the server, and PARTIAL when it also resolves to other addresses of a family the
+// server has an address of.
+func (c *DnsChecker) CheckStatus(ctx context.Context, domain UnresolvedDomain, server serverpilot.Server) (int, AddressMatches) {
+	traceStep(ctx, "nameservers for %s: %s", getBaseDomain(domain.Name), listOrNone(domain.Nameservers))
+
+	resolvedIps, err := c.r.Resolve(ctx, domain)
 	if err != nil {
-		return UNKNOWN
+		traceStep(ctx, "status %s: %s", statusText(UNKNOWN), err)
+		return UNKNOWN, AddressMatches{}
+	}
+
+	var v4, v6 []net.IP
+	for _, resolved := range resolvedIps {
+		ip := net.ParseIP(resolved)
+		if ip == nil {
+			continue
+		}
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+
+	matches := AddressMatches{
+		IPv4:       matchFamily(v4, server.IPv4()),
+		IPv6:       matchFamily(v6, server.IPv6()),
+		ForeignIps: append(foreignIps(v4, server.IPv4()), foreignIps(v6, server.IPv6())...),
+	}
+
+	status := OK
+	switch {
+	// The records that couldn't be compared may well point at the server
+	case matches.IPv4 != Match && matches.IPv6 != Match && (matches.IPv4 == NotComparable || matches.IPv6 == NotComparable):
+		status = UNKNOWN
+	case matches.IPv4 != Match && matches.IPv6 != Match:
+		status = INACTIVE
+	case len(matches.ForeignIps) > 0:
+		status = PARTIAL
+	}
+
+	traceStep(ctx, "compared with server %s: IPv4 %s, IPv6 %s", server.Name, orNone(server.IPv4()), orNone(server.IPv6()))
+	traceStep(ctx, "status %s: IPv4 %s, IPv6 %s, foreign IPs %s", statusText(status), matches.IPv4, matches.IPv6, listOrNone(matches.ForeignIps))
+
+	return status, matches
+}
+
+// foreignIps returns the addresses of one family that aren't the server's address of that family, without duplicates.
+// When the server has no address of the family (or it isn't known, such as the IPv6 address of a dual-stack server)
+// none of them are foreign, since they can't be compared.
+func foreignIps(ips []net.IP, serverIp string) []string {
+	server := net.ParseIP(serverIp)
+	if server == nil {
+		return nil
+	}
+
+	var foreign []string
+	for _, ip := range ips {
+		if ip.Equal(server) || contains(foreign, ip.String()) {
+			continue
+		}
+		foreign = append(foreign, ip.String())
+	}
+	return foreign
+}
+
+// matchFamily compares the resolved addresses of one family with the server's address of that family.
+func matchFamily(ips []net.IP, serverIp string) FamilyMatch {
+	if len(ips) == 0 {
+		return NoRecords
+	}
+
+	server := net.ParseIP(serverIp)
+	if server == nil {
+		return NotComparable
 	}
 
-	for _, ip := range resolvedIps {
-		if ip == serverIp {
-			return OK
+	for _, ip := range ips {
+		if ip.Equal(server) {
+			return Match
 		}
 	}
 
-	return INACTIVE
+	return Mismatch
 }
 
 func getBaseDomain(domain string) string {
@@ -159,5 +312,5 @@ func getBaseDomain(domain string) string {
 // IpResolver is an interface for resolving a domain to its IP address(s). It will return
 // the ip addresses when it can, or an error if it cannot.
 type IpResolver interface {
-	Resolve(domain UnresolvedDomain) ([]string, error)
+	Resolve(ctx context.Context, domain UnresolvedDomain) ([]string, error)
 }
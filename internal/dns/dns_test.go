@@ -1,11 +1,13 @@
 package dns
 
 import (
+	"context"
 	"errors"
 	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
 	"gotest.tools/v3/assert"
 	"io"
 	"log"
+	"strings"
 	"testing"
 )
 
@@ -14,41 +16,116 @@ func TestDNS(t *testing.T) {
 		var tests = []struct {
 			name        string
 			domain      string
-			serverIp    string
+			server      serverpilot.Server
 			resolvedIps map[string]string
 			want        int
+			wantMatches AddressMatches
 		}{
 			{
 				"ok",
 				"example.com",
-				"127.0.0.1",
+				serverpilot.Server{Ipaddress: "127.0.0.1"},
 				map[string]string{
 					"example.com": "127.0.0.1",
 				},
 				OK,
+				AddressMatches{IPv4: Match},
 			},
 			{
 				"inactive",
 				"inactive.example.com",
-				"127.0.0.1",
+				serverpilot.Server{Ipaddress: "127.0.0.1"},
 				map[string]string{
 					"inactive.example.com": "0.0.0.0",
 				},
 				INACTIVE,
+				AddressMatches{IPv4: Mismatch, ForeignIps: []string{"0.0.0.0"}},
+			},
+			{
+				"partial",
+				"partial.example.com",
+				serverpilot.Server{Ipaddress: "127.0.0.1"},
+				map[string]string{
+					"partial.example.com": "127.0.0.1,10.0.0.1,10.0.0.1",
+				},
+				PARTIAL,
+				AddressMatches{IPv4: Match, ForeignIps: []string{"10.0.0.1"}},
 			},
 			{
 				"unknown",
 				"unknown.example.com",
-				"127.0.0.1",
+				serverpilot.Server{Ipaddress: "127.0.0.1"},
 				nil,
 				UNKNOWN,
+				AddressMatches{},
 			},
 			{
 				"expired/not pointed",
 				"expired.com",
-				"127.0.0.1",
+				serverpilot.Server{Ipaddress: "127.0.0.1"},
 				map[string]string{},
 				INACTIVE,
+				AddressMatches{},
+			},
+			{
+				"dual-stack domain on an ipv4 server",
+				"example.com",
+				serverpilot.Server{Ipaddress: "127.0.0.1"},
+				map[string]string{
+					"example.com": "127.0.0.1,2001:db8::1",
+				},
+				OK,
+				AddressMatches{IPv4: Match, IPv6: NotComparable},
+			},
+			{
+				"ipv6-only domain on a server whose ipv6 address isn't known",
+				"example.com",
+				serverpilot.Server{Ipaddress: "127.0.0.1"},
+				map[string]string{
+					"example.com": "2001:db8::1",
+				},
+				UNKNOWN,
+				AddressMatches{IPv4: NoRecords, IPv6: NotComparable},
+			},
+			{
+				"dual-stack domain on an ipv4 server with a foreign ipv4 address",
+				"example.com",
+				serverpilot.Server{Ipaddress: "127.0.0.1"},
+				map[string]string{
+					"example.com": "127.0.0.1,10.0.0.1,2001:db8::1",
+				},
+				PARTIAL,
+				AddressMatches{IPv4: Match, IPv6: NotComparable, ForeignIps: []string{"10.0.0.1"}},
+			},
+			{
+				"ipv6-only server",
+				"example.com",
+				serverpilot.Server{Ipaddress: "2001:db8::1"},
+				map[string]string{
+					"example.com": "2001:0db8:0000::0001",
+				},
+				OK,
+				AddressMatches{IPv6: Match},
+			},
+			{
+				"ipv6 records pointing elsewhere",
+				"example.com",
+				serverpilot.Server{Ipaddress: "2001:db8::1"},
+				map[string]string{
+					"example.com": "127.0.0.1,2001:db8::2",
+				},
+				UNKNOWN,
+				AddressMatches{IPv4: NotComparable, IPv6: Mismatch, ForeignIps: []string{"2001:db8::2"}},
+			},
+			{
+				"ipv6-only domain pointing elsewhere",
+				"example.com",
+				serverpilot.Server{Ipaddress: "2001:db8::1"},
+				map[string]string{
+					"example.com": "2001:db8::2",
+				},
+				INACTIVE,
+				AddressMatches{IPv6: Mismatch, ForeignIps: []string{"2001:db8::2"}},
 			},
 		}
 
@@ -56,10 +133,31 @@ func TestDNS(t *testing.T) {
 			t.Run(tt.name, func(t *testing.T) {
 				checker := NewDnsChecker(&IpResolverStub{tt.resolvedIps}, nil)
 
-				got := checker.CheckStatus(UnresolvedDomain{Name: tt.domain}, tt.serverIp)
-				want := tt.want
+				got, matches := checker.CheckStatus(context.Background(), UnresolvedDomain{Name: tt.domain}, tt.server)
+
+				assert.Equal(t, got, tt.want)
+				assert.DeepEqual(t, matches, tt.wantMatches)
+			})
+		}
+	})
+
+	t.Run("it should report a domain as unknown when its lookup fails", func(t *testing.T) {
+		var tests = []struct {
+			name   string
+			domain string
+			want   int
+		}{
+			{"servfail", "servfail.example.com", UNKNOWN},
+			{"domain that doesn't exist", "missing.example.com", INACTIVE},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				checker := NewDnsChecker(newResolverWithStubs(), nil)
 
-				assert.Equal(t, got, want)
+				got, _ := checker.CheckStatus(context.Background(), UnresolvedDomain{Name: tt.domain}, serverpilot.Server{Ipaddress: "127.0.0.1"})
+
+				assert.Equal(t, got, tt.want)
 			})
 		}
 	})
@@ -79,20 +177,24 @@ func TestDNS(t *testing.T) {
 					{Name: "ok.example.com"},
 					{Name: "inactive.example.com"},
 					{Name: "unknown.example.com"},
+					{Name: "partial.example.com"},
 				},
 				[]serverpilot.AppServer{
-					{serverpilot.App{Id: "1", Domains: []string{"ok.example.com"}}, serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
-					{serverpilot.App{Id: "2", Domains: []string{"inactive.example.com"}}, serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
-					{serverpilot.App{Id: "3", Domains: []string{"unknown.example.com"}}, serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
+					{App: serverpilot.App{Id: "1", Domains: []string{"ok.example.com"}}, Server: serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
+					{App: serverpilot.App{Id: "2", Domains: []string{"inactive.example.com"}}, Server: serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
+					{App: serverpilot.App{Id: "3", Domains: []string{"unknown.example.com"}}, Server: serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
+					{App: serverpilot.App{Id: "4", Domains: []string{"partial.example.com"}}, Server: serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
 				},
 				map[string]string{
 					"ok.example.com":       "127.0.0.1",
 					"inactive.example.com": "0.0.0.0",
 					"unknown.example.com":  "unknown",
+					"partial.example.com":  "127.0.0.1,10.0.0.1",
 				},
 				false,
 				[]AppDomainStatus{
-					{AppId: "2", Domain: "inactive.example.com", ServerName: "server1", Status: INACTIVE},
+					{AppId: "2", Domain: "inactive.example.com", ServerName: "server1", Status: INACTIVE, AddressMatches: AddressMatches{IPv4: Mismatch, ForeignIps: []string{"0.0.0.0"}}},
+					{AppId: "4", Domain: "partial.example.com", ServerName: "server1", Status: PARTIAL, AddressMatches: AddressMatches{IPv4: Match, ForeignIps: []string{"10.0.0.1"}}},
 				},
 			},
 			{
@@ -103,9 +205,9 @@ func TestDNS(t *testing.T) {
 					{Name: "unknown.example.com"},
 				},
 				[]serverpilot.AppServer{
-					{serverpilot.App{Id: "1", Domains: []string{"ok.example.com"}}, serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
-					{serverpilot.App{Id: "2", Domains: []string{"inactive.example.com"}}, serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
-					{serverpilot.App{Id: "3", Domains: []string{"unknown.example.com"}}, serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
+					{App: serverpilot.App{Id: "1", Domains: []string{"ok.example.com"}}, Server: serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
+					{App: serverpilot.App{Id: "2", Domains: []string{"inactive.example.com"}}, Server: serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
+					{App: serverpilot.App{Id: "3", Domains: []string{"unknown.example.com"}}, Server: serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
 				},
 				map[string]string{
 					"ok.example.com":       "127.0.0.1",
@@ -114,7 +216,7 @@ func TestDNS(t *testing.T) {
 				},
 				true,
 				[]AppDomainStatus{
-					{AppId: "2", Domain: "inactive.example.com", ServerName: "server1", Status: INACTIVE},
+					{AppId: "2", Domain: "inactive.example.com", ServerName: "server1", Status: INACTIVE, AddressMatches: AddressMatches{IPv4: Mismatch, ForeignIps: []string{"0.0.0.0"}}},
 					{AppId: "3", Domain: "unknown.example.com", ServerName: "server1", Status: UNKNOWN},
 				},
 			},
@@ -124,7 +226,7 @@ func TestDNS(t *testing.T) {
 			t.Run(tt.name, func(t *testing.T) {
 				checker := NewDnsChecker(&IpResolverStub{tt.resolvedIps}, nil)
 
-				got := checker.GetInactiveAppDomains(&FakeTicker{}, tt.domains, tt.appservers, tt.includeUnknown)
+				got, _ := checker.GetInactiveAppDomains(context.Background(), &FakeTicker{}, tt.domains, tt.appservers, tt.includeUnknown)
 				want := tt.want
 
 				assert.DeepEqual(t, got, want)
@@ -132,6 +234,17 @@ func TestDNS(t *testing.T) {
 		}
 	})
 
+	t.Run("it should not check any more domains once the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		checker := NewDnsChecker(&IpResolverStub{map[string]string{"example.com": "127.0.0.1"}}, nil)
+
+		got, err := checker.GetAppDomainStatuses(ctx, &FakeTicker{}, []UnresolvedDomain{{Name: "example.com"}}, nil)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, len(got), 0)
+	})
+
 	t.Run("it should evaluate domains", func(t *testing.T) {
 		var tests = []struct {
 			name    string
@@ -143,7 +256,8 @@ func TestDNS(t *testing.T) {
 				[]string{"example.com"},
 				[]UnresolvedDomain{
 					{
-						Name: "example.com",
+						Name:        "example.com",
+						Nameservers: []string{"ns1.example.com"},
 					},
 				},
 			},
@@ -152,7 +266,8 @@ func TestDNS(t *testing.T) {
 				[]string{"sub.example.com"},
 				[]UnresolvedDomain{
 					{
-						Name: "sub.example.com",
+						Name:        "sub.example.com",
+						Nameservers: []string{"ns1.example.com"},
 					},
 				},
 			},
@@ -161,7 +276,8 @@ func TestDNS(t *testing.T) {
 				[]string{"domain-behind-cloudflare.com"},
 				[]UnresolvedDomain{
 					{
-						Name: "domain-behind-cloudflare.com",
+						Name:        "domain-behind-cloudflare.com",
+						Nameservers: []string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"},
 						CloudflareMetadata: &CloudflareDomainMetadata{
 							[]string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"},
 							nil,
@@ -180,7 +296,7 @@ func TestDNS(t *testing.T) {
 					cachedNs: nil,
 				})
 
-				got := checker.EvaluateDomains(&FakeTicker{}, tt.domains)
+				got, _ := checker.EvaluateDomains(context.Background(), &FakeTicker{}, tt.domains)
 
 				assert.DeepEqual(t, got, tt.want)
 			})
@@ -196,7 +312,7 @@ type IpResolverStub struct {
 	ips map[string]string
 }
 
-func (s *IpResolverStub) Resolve(domain UnresolvedDomain) ([]string, error) {
+func (s *IpResolverStub) Resolve(ctx context.Context, domain UnresolvedDomain) ([]string, error) {
 	if s.ips == nil {
 		return nil, errors.New("no ips")
 	}
@@ -209,5 +325,6 @@ func (s *IpResolverStub) Resolve(domain UnresolvedDomain) ([]string, error) {
 	if ip == "unknown" {
 		return nil, errors.New("unknown")
 	}
-	return []string{ip}, nil
+	// A domain can resolve to several comma separated ips
+	return strings.Split(ip, ","), nil
 }
@@ -0,0 +1,240 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidDoh = errors.New("invalid DNS-over-HTTPS settings")
+	ErrDohRequest = errors.New("DNS-over-HTTPS request failed")
+)
+
+// DefaultDohUrl is the DNS-over-HTTPS endpoint used when none is given.
+const DefaultDohUrl = "https://cloudflare-dns.com/dns-query"
+
+// DohFormat is how DNS-over-HTTPS queries and responses are encoded.
+type DohFormat string
+
+const (
+	// DohWire sends DNS messages in the RFC 8484 wire format.
+	DohWire DohFormat = "wire"
+	// DohJson uses the JSON API offered by Cloudflare and Google.
+	DohJson DohFormat = "json"
+)
+
+const dohTimeout = 10 * time.Second
+
+// DohResolver sends every query to a DNS-over-HTTPS endpoint, for when UDP/53 can't reach the internet but HTTPS can.
+type DohResolver struct {
+	url    string
+	format DohFormat
+	client *nethttp.Client
+}
+
+// NewDohResolver returns a DohResolver for the endpoint. A nil transport uses http.DefaultTransport.
+func NewDohResolver(endpoint string, format DohFormat, transport nethttp.RoundTripper) (*DohResolver, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("%w: invalid url %s", ErrInvalidDoh, endpoint)
+	}
+	if format != DohWire && format != DohJson {
+		return nil, fmt.Errorf("%w: unknown format %s (use %s or %s)", ErrInvalidDoh, format, DohWire, DohJson)
+	}
+
+	return &DohResolver{
+		url:    endpoint,
+		format: format,
+		client: &nethttp.Client{Timeout: dohTimeout, Transport: transport},
+	}, nil
+}
+
+// LookupIP looks up the IPv4 and IPv6 addresses of the host.
+func (r *DohResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	a, errA := r.query(ctx, host, dnsmessage.TypeA)
+	aaaa, errAAAA := r.query(ctx, host, dnsmessage.TypeAAAA)
+	if err := lookupIPError(errA, errAAAA); err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, record := range append(a, aaaa...) {
+		if ip := net.ParseIP(record); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return ips, nil
+}
+
+// LookupNS looks up the nameservers of the host.
+func (r *DohResolver) LookupNS(ctx context.Context, host string) ([]*net.NS, error) {
+	records, err := r.query(ctx, host, dnsmessage.TypeNS)
+	if err != nil {
+		return nil, err
+	}
+
+	var ns []*net.NS
+	for _, record := range records {
+		ns = append(ns, &net.NS{Host: fqdn(record)})
+	}
+	if len(ns) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return ns, nil
+}
+
+// query returns the records of the type for the host, as strings. Any CNAMEs have already been followed by the
+// endpoint, so the records of the type are returned whatever name they are for.
+func (r *DohResolver) query(ctx context.Context, host string, t dnsmessage.Type) ([]string, error) {
+	if r.format == DohJson {
+		return r.queryJson(ctx, host, t)
+	}
+	return r.queryWire(ctx, host, t)
+}
+
+func (r *DohResolver) queryWire(ctx context.Context, host string, t dnsmessage.Type) ([]string, error) {
+	name, err := dnsmessage.NewName(fqdn(host))
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: host}
+	}
+
+	// RFC 8484 recommends an ID of 0, so responses can be cached by HTTP caches
+	q := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: t, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := q.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodPost, r.url, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	body, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(body); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDohRequest, err)
+	}
+	if err := rcodeError(int(resp.RCode), host); err != nil {
+		return nil, err
+	}
+
+	var records []string
+	for _, rr := range resp.Answers {
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			if t == dnsmessage.TypeA {
+				records = append(records, net.IP(body.A[:]).String())
+			}
+		case *dnsmessage.AAAAResource:
+			if t == dnsmessage.TypeAAAA {
+				records = append(records, net.IP(body.AAAA[:]).String())
+			}
+		case *dnsmessage.NSResource:
+			if t == dnsmessage.TypeNS {
+				records = append(records, body.NS.String())
+			}
+		}
+	}
+
+	return records, nil
+}
+
+// dohJsonResponse is the response of the JSON API. The Type of each answer is the numeric record type.
+type dohJsonResponse struct {
+	Status int `json:"Status"`
+	Answer []struct {
+		Name string `json:"name"`
+		Type int    `json:"type"`
+		Data string `json:"data"`
+	} `json:"Answer"`
+}
+
+func (r *DohResolver) queryJson(ctx context.Context, host string, t dnsmessage.Type) ([]string, error) {
+	u, _ := url.Parse(r.url)
+	query := u.Query()
+	query.Set("name", host)
+	query.Set("type", strings.TrimPrefix(t.String(), "Type"))
+	u.RawQuery = query.Encode()
+
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-json")
+
+	body, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp dohJsonResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDohRequest, err)
+	}
+	if err := rcodeError(resp.Status, host); err != nil {
+		return nil, err
+	}
+
+	var records []string
+	for _, answer := range resp.Answer {
+		if answer.Type == int(t) {
+			records = append(records, answer.Data)
+		}
+	}
+
+	return records, nil
+}
+
+func (r *DohResolver) do(req *nethttp.Request) ([]byte, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDohRequest, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDohRequest, err)
+	}
+	if resp.StatusCode != nethttp.StatusOK {
+		return nil, fmt.Errorf("%w: %s returned %d %s", ErrDohRequest, r.url, resp.StatusCode, nethttp.StatusText(resp.StatusCode))
+	}
+
+	return body, nil
+}
+
+// rcodeError converts an unsuccessful response code into the error the system resolver would return.
+func rcodeError(rcode int, host string) error {
+	switch dnsmessage.RCode(rcode) {
+	case dnsmessage.RCodeSuccess:
+		return nil
+	case dnsmessage.RCodeNameError:
+		return &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return &net.DNSError{Err: "server misbehaving: " + dnsmessage.RCode(rcode).String(), Name: host}
+}
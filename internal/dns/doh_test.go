@@ -0,0 +1,193 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"golang.org/x/net/dns/dnsmessage"
+	"gotest.tools/v3/assert"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDohResolver(t *testing.T) {
+	for _, format := range []DohFormat{DohWire, DohJson} {
+		t.Run(string(format), func(t *testing.T) {
+			t.Run("it should look up the ip addresses of a host", func(t *testing.T) {
+				var tests = []struct {
+					name string
+					host string
+					want []string
+				}{
+					{"ipv4 and ipv6", "example.com", []string{"1.2.3.4", "2001:db8::1"}},
+					{"through a cname", "www.example.com", []string{"1.2.3.4", "2001:db8::1"}},
+				}
+
+				for _, tt := range tests {
+					t.Run(tt.name, func(t *testing.T) {
+						r := newDohResolverWithStubs(t, format)
+
+						ips, err := r.LookupIP(context.Background(), tt.host)
+
+						assert.NilError(t, err)
+						var got []string
+						for _, ip := range ips {
+							got = append(got, ip.String())
+						}
+						assert.DeepEqual(t, got, tt.want)
+					})
+				}
+			})
+
+			t.Run("it should look up the nameservers of a host", func(t *testing.T) {
+				r := newDohResolverWithStubs(t, format)
+
+				ns, err := r.LookupNS(context.Background(), "example.com")
+
+				assert.NilError(t, err)
+				assert.Equal(t, len(ns), 1)
+				assert.Equal(t, ns[0].Host, "ns1.example.com.")
+			})
+
+			t.Run("it should return the addresses of one family when the other has no records", func(t *testing.T) {
+				r := newDohResolverWithStubs(t, format)
+
+				ips, err := r.LookupIP(context.Background(), "v6only.example.com")
+
+				assert.NilError(t, err)
+				assert.Equal(t, len(ips), 1)
+				assert.Equal(t, ips[0].String(), "2001:db8::2")
+			})
+
+			t.Run("it should return an error when the lookup of one family fails", func(t *testing.T) {
+				r := newDohResolverWithStubs(t, format)
+
+				_, err := r.LookupIP(context.Background(), "flaky.example.com")
+
+				var dnsErr *net.DNSError
+				assert.Assert(t, errors.As(err, &dnsErr))
+				assert.Assert(t, !dnsErr.IsNotFound)
+				assert.ErrorContains(t, err, "server misbehaving")
+			})
+
+			t.Run("it should return a not found error for a domain that doesn't exist", func(t *testing.T) {
+				r := newDohResolverWithStubs(t, format)
+
+				_, err := r.LookupIP(context.Background(), "missing.com")
+
+				var dnsErr *net.DNSError
+				assert.Assert(t, errors.As(err, &dnsErr))
+				assert.Assert(t, dnsErr.IsNotFound)
+			})
+		})
+	}
+
+	t.Run("it should return an error when the endpoint fails", func(t *testing.T) {
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			w.WriteHeader(nethttp.StatusBadGateway)
+		}))
+		defer server.Close()
+		r, _ := NewDohResolver(server.URL, DohWire, nil)
+
+		_, err := r.LookupIP(context.Background(), "example.com")
+
+		assert.ErrorIs(t, err, ErrDohRequest)
+		assert.ErrorContains(t, err, "502")
+	})
+
+	t.Run("it should not accept an invalid url or format", func(t *testing.T) {
+		var tests = []struct {
+			url    string
+			format DohFormat
+		}{
+			{"dns.example.com/dns-query", DohWire},
+			{"https://dns.example.com/dns-query", "xml"},
+		}
+
+		for _, tt := range tests {
+			_, err := NewDohResolver(tt.url, tt.format, nil)
+
+			assert.ErrorIs(t, err, ErrInvalidDoh)
+		}
+	})
+}
+
+// newDohResolverWithStubs returns a resolver for a local DNS-over-HTTPS endpoint, which answers in both formats
+// like a public one does, with any CNAMEs already followed.
+func newDohResolverWithStubs(t *testing.T, format DohFormat) *DohResolver {
+	answers := map[string][]dnsmessage.Resource{
+		"example.com. TypeA":           {aRecord("example.com.", "1.2.3.4")},
+		"example.com. TypeAAAA":        {aRecord("example.com.", "2001:db8::1")},
+		"example.com. TypeNS":          {nsRecord("example.com.", "ns1.example.com.")},
+		"www.example.com. TypeA":       {cnameRecord("www.example.com.", "example.com."), aRecord("example.com.", "1.2.3.4")},
+		"www.example.com. TypeAAAA":    {cnameRecord("www.example.com.", "example.com."), aRecord("example.com.", "2001:db8::1")},
+		"v6only.example.com. TypeAAAA": {aRecord("v6only.example.com.", "2001:db8::2")},
+		"flaky.example.com. TypeAAAA":  {aRecord("flaky.example.com.", "2001:db8::2")},
+	}
+	respond := func(q dnsmessage.Question) dnsmessage.Message {
+		resp := dnsmessage.Message{Header: dnsmessage.Header{Response: true, RecursionAvailable: true}, Questions: []dnsmessage.Question{q}}
+		switch {
+		case q.Name.String() == "missing.com.":
+			resp.RCode = dnsmessage.RCodeNameError
+		case q.Name.String() == "flaky.example.com." && q.Type == dnsmessage.TypeA:
+			resp.RCode = dnsmessage.RCodeServerFailure
+		}
+		resp.Answers = answers[q.Name.String()+" "+q.Type.String()]
+		return resp
+	}
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Header.Get("Accept") == "application/dns-json" {
+			var q dnsmessage.Question
+			q.Name = dnsmessage.MustNewName(fqdn(r.URL.Query().Get("name")))
+			for _, typ := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA, dnsmessage.TypeNS} {
+				if "Type"+r.URL.Query().Get("type") == typ.String() {
+					q.Type = typ
+				}
+			}
+			w.Header().Set("Content-Type", "application/dns-json")
+			json.NewEncoder(w).Encode(toJson(respond(q)))
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var query dnsmessage.Message
+		if r.Method != nethttp.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" || query.Unpack(body) != nil {
+			w.WriteHeader(nethttp.StatusBadRequest)
+			return
+		}
+		resp := respond(query.Questions[0])
+		packed, _ := resp.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}))
+	t.Cleanup(server.Close)
+
+	r, err := NewDohResolver(server.URL+"/dns-query", format, nil)
+	assert.NilError(t, err)
+
+	return r
+}
+
+// toJson converts a response to the JSON API format.
+func toJson(m dnsmessage.Message) map[string]any {
+	var answers []map[string]any
+	for _, rr := range m.Answers {
+		var data string
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			data = net.IP(body.A[:]).String()
+		case *dnsmessage.AAAAResource:
+			data = net.IP(body.AAAA[:]).String()
+		case *dnsmessage.NSResource:
+			data = body.NS.String()
+		case *dnsmessage.CNAMEResource:
+			data = body.CNAME.String()
+		}
+		answers = append(answers, map[string]any{"name": rr.Header.Name.String(), "type": int(rr.Header.Type), "data": data})
+	}
+	return map[string]any{"Status": int(m.RCode), "Answer": answers}
+}
@@ -0,0 +1,80 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+var ErrInvalidNameserver = errors.New("invalid nameserver")
+
+// Lookups looks up the IP addresses and nameservers of hosts. Its methods can be used as an IpLookupFunc and an
+// NsLookupFunc.
+type Lookups interface {
+	LookupIP(ctx context.Context, host string) ([]net.IP, error)
+	LookupNS(ctx context.Context, host string) ([]*net.NS, error)
+}
+
+// RecursiveResolver sends every query to the given recursive nameservers, instead of the system resolver, so the
+// answers don't depend on the machine's configuration or its cache. The nameservers are tried in turn.
+type RecursiveResolver struct {
+	r       *net.Resolver
+	servers []string
+	next    atomic.Uint32
+}
+
+// NewRecursiveResolver returns a RecursiveResolver for the nameservers, given as an ip and optional port such as
+// 1.1.1.1:53 or [2606:4700:4700::1111]:53. The port defaults to 53.
+func NewRecursiveResolver(servers []string) (*RecursiveResolver, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("%w: no nameservers given", ErrInvalidNameserver)
+	}
+
+	var addrs []string
+	for _, s := range servers {
+		addr, err := nameserverAddr(s)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+
+	r := &RecursiveResolver{servers: addrs}
+	r.r = &net.Resolver{
+		PreferGo: true,
+		// Ignore the nameserver from the system configuration, and dial one of ours instead
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			server := r.servers[int(r.next.Add(1)-1)%len(r.servers)]
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+
+	return r, nil
+}
+
+// LookupIP looks up the IPv4 and IPv6 addresses of the host.
+func (r *RecursiveResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	return r.r.LookupIP(ctx, "ip", host)
+}
+
+// LookupNS looks up the nameservers of the host.
+func (r *RecursiveResolver) LookupNS(ctx context.Context, host string) ([]*net.NS, error) {
+	return r.r.LookupNS(ctx, host)
+}
+
+// nameserverAddr returns the host:port of a nameserver given as an ip, with or without a port.
+func nameserverAddr(s string) (string, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		host, port = s, "53"
+	}
+
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("%w: %s (use an ip address, such as 1.1.1.1:53)", ErrInvalidNameserver, s)
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
@@ -0,0 +1,50 @@
+package dns
+
+import (
+	"context"
+	"gotest.tools/v3/assert"
+	"testing"
+)
+
+func TestRecursiveResolver(t *testing.T) {
+	t.Run("it should send queries to the given nameservers", func(t *testing.T) {
+		server := startNameserver(t, map[string]string{"example.com.": "1.2.3.4"})
+		r, err := NewRecursiveResolver([]string{server})
+		assert.NilError(t, err)
+
+		ips, err := r.LookupIP(context.Background(), "example.com")
+
+		assert.NilError(t, err)
+		assert.Equal(t, len(ips), 1)
+		assert.Equal(t, ips[0].String(), "1.2.3.4")
+	})
+
+	t.Run("it should default to port 53", func(t *testing.T) {
+		var tests = []struct {
+			server string
+			want   string
+		}{
+			{"1.1.1.1", "1.1.1.1:53"},
+			{"1.1.1.1:5353", "1.1.1.1:5353"},
+			{"2606:4700:4700::1111", "[2606:4700:4700::1111]:53"},
+			{"[2606:4700:4700::1111]:53", "[2606:4700:4700::1111]:53"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.server, func(t *testing.T) {
+				got, err := nameserverAddr(tt.server)
+
+				assert.NilError(t, err)
+				assert.Equal(t, got, tt.want)
+			})
+		}
+	})
+
+	t.Run("it should return an error for an invalid nameserver", func(t *testing.T) {
+		_, err := NewRecursiveResolver([]string{"dns.example.com"})
+		assert.ErrorIs(t, err, ErrInvalidNameserver)
+
+		_, err = NewRecursiveResolver(nil)
+		assert.ErrorIs(t, err, ErrInvalidNameserver)
+	})
+}
@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/jfortunato/serverpilot-tools/internal/http"
@@ -13,8 +14,8 @@ var (
 )
 
 type cloudflareChecker interface {
-	IsBehindCloudFlare(domain string) bool
-	GetNameserversForBaseDomain(domain string) ([]string, error)
+	IsBehindCloudFlare(ctx context.Context, domain string) bool
+	GetNameserversForBaseDomain(ctx context.Context, domain string) ([]string, error)
 }
 
 type Resolver struct {
@@ -24,13 +25,17 @@ type Resolver struct {
 	l          *log.Logger
 }
 
-type IpLookupFunc func(host string) ([]net.IP, error)
-type NsLookupFunc func(host string) ([]*net.NS, error)
+type IpLookupFunc func(ctx context.Context, host string) ([]net.IP, error)
+type NsLookupFunc func(ctx context.Context, host string) ([]*net.NS, error)
 
-func NewResolver(cfResolver IpResolver, cfChecker cloudflareChecker, ipLookup IpLookupFunc, l *log.Logger) *Resolver {
+func lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+func NewResolver(cfResolver IpResolver, cfChecker cloudflareChecker, ipLookup IpLookupFunc, l *log.Logger, settings http.ClientSettings) *Resolver {
 	// Default to net.LookupIP
 	if ipLookup == nil {
-		ipLookup = net.LookupIP
+		ipLookup = lookupIP
 	}
 
 	resolver := &Resolver{cfResolver: cfResolver, cfChecker: cfChecker, lookupIp: ipLookup, l: l}
@@ -39,26 +44,35 @@ func NewResolver(cfResolver IpResolver, cfChecker cloudflareChecker, ipLookup Ip
 		resolver.cfResolver = NewCloudflareResolver(
 			l,
 			resolver,
-			http.NewClient(l),
+			http.NewClient(l, settings),
+			settings.BaseUrl,
 		)
 	}
 
 	return resolver
 }
 
-func (r *Resolver) Resolve(domain UnresolvedDomain) ([]string, error) {
+func (r *Resolver) Resolve(ctx context.Context, domain UnresolvedDomain) ([]string, error) {
 	// If the domain is behind CloudFlare, we won't be able to resolve the real IP addresses unless
 	// we have CloudFlare API credentials for the domain.
 	if domain.CloudflareMetadata != nil {
-		resolved, err := r.cfResolver.Resolve(domain)
+		cfCtx := traceStep(ctx, "behind Cloudflare, resolving %s with the Cloudflare API", domain.Name)
+		resolved, err := r.cfResolver.Resolve(cfCtx, domain)
 		if err != nil {
-			return nil, fmt.Errorf("%w: cloudflare error", ErrorDomainBehindCloudFlare)
+			r.l.Println("Could not resolve", domain.Name, "with the Cloudflare API:", err)
+			traceStep(cfCtx, "failed: %s", err)
+			return nil, fmt.Errorf("%w: %w", ErrorDomainBehindCloudFlare, err)
 		}
+		traceStep(ctx, "resolved %s to %s", domain.Name, listOrNone(resolved))
 		return resolved, nil
 	}
 
 	r.l.Println("Looking up IP addresses for", domain, "...")
-	ips, _ := r.lookupIp(domain.Name)
+	ips, err := r.lookupIp(ctx, domain.Name)
+	// A lookup that was interrupted says nothing about where the domain points
+	if ctx.Err() != nil {
+		return nil, err
+	}
 	r.l.Println("IP addresses for", domain, "are", ips)
 
 	var ipStrings []string
@@ -67,5 +81,17 @@ func (r *Resolver) Resolve(domain UnresolvedDomain) ([]string, error) {
 		ipStrings = append(ipStrings, ip.String())
 	}
 
+	if err != nil {
+		traceStep(ctx, "looked up %s: %s", domain.Name, err)
+		// A domain that doesn't exist has no records, but a failed lookup (such as a SERVFAIL or a timeout) says
+		// nothing about where the domain points
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			return nil, err
+		}
+	} else {
+		traceStep(ctx, "looked up %s: %s", domain.Name, listOrNone(ipStrings))
+	}
+
 	return ipStrings, nil
 }
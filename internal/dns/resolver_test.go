@@ -1,7 +1,9 @@
 package dns
 
 import (
+	"context"
 	"errors"
+	"github.com/jfortunato/serverpilot-tools/internal/http"
 	"gotest.tools/v3/assert"
 	"io"
 	"log"
@@ -14,13 +16,28 @@ func TestResolver(t *testing.T) {
 	t.Run("it should resolve the ip addresses for the given domain name", func(t *testing.T) {
 		resolver := newResolverWithStubs()
 
-		got, _ := resolver.Resolve(UnresolvedDomain{Name: "example.com"})
+		got, _ := resolver.Resolve(context.Background(), UnresolvedDomain{Name: "example.com"})
 		want := []string{"127.0.0.1"}
 
 		assert.DeepEqual(t, got, want)
 	})
 
 	t.Run("it should return an error that occurs during the network request", func(t *testing.T) {
+		resolver := newResolverWithStubs()
+
+		got, err := resolver.Resolve(context.Background(), UnresolvedDomain{Name: "servfail.example.com"})
+
+		assert.Assert(t, got == nil)
+		assert.ErrorContains(t, err, "server misbehaving")
+	})
+
+	t.Run("it should not return an error for a domain that doesn't exist", func(t *testing.T) {
+		resolver := newResolverWithStubs()
+
+		got, err := resolver.Resolve(context.Background(), UnresolvedDomain{Name: "missing.example.com"})
+
+		assert.NilError(t, err)
+		assert.Assert(t, got == nil)
 	})
 
 	t.Run("it should defer to the cloudflare resolver when the domain is on cloudflare nameservers", func(t *testing.T) {
@@ -58,7 +75,7 @@ func TestResolver(t *testing.T) {
 				}
 				resolver.cfResolver = &IpResolverStub{ips: stub}
 
-				got, _ := resolver.Resolve(tt.domain)
+				got, _ := resolver.Resolve(context.Background(), tt.domain)
 
 				assert.DeepEqual(t, got, tt.expectedResult)
 			})
@@ -69,7 +86,7 @@ func TestResolver(t *testing.T) {
 		resolver := newResolverWithStubs()
 		resolver.cfResolver = &IpResolverStub{}
 
-		got, err := resolver.Resolve(UnresolvedDomain{
+		got, err := resolver.Resolve(context.Background(), UnresolvedDomain{
 			Name: "domain-behind-cloudflare.com",
 			CloudflareMetadata: &CloudflareDomainMetadata{
 				BaseDomainNameservers: []string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"},
@@ -88,20 +105,21 @@ func newResolverWithStubs() *Resolver {
 		&CloudflareCheckerStub{},
 		IpLookupStub,
 		log.New(io.Discard, "", 0),
+		http.ClientSettings{},
 	)
 }
 
 type CloudflareCheckerStub struct {
 }
 
-func (c *CloudflareCheckerStub) IsBehindCloudFlare(domain string) bool {
-	ns, _ := c.GetNameserversForBaseDomain(domain)
+func (c *CloudflareCheckerStub) IsBehindCloudFlare(ctx context.Context, domain string) bool {
+	ns, _ := c.GetNameserversForBaseDomain(ctx, domain)
 
 	return len(ns) > 0 && strings.HasSuffix(ns[0], "ns.cloudflare.com")
 }
 
-func (c *CloudflareCheckerStub) GetNameserversForBaseDomain(domain string) ([]string, error) {
-	ns, _ := NsLookupStub(getBaseDomain(domain))
+func (c *CloudflareCheckerStub) GetNameserversForBaseDomain(ctx context.Context, domain string) ([]string, error) {
+	ns, _ := NsLookupStub(ctx, getBaseDomain(domain))
 	var nameservers []string
 	for _, n := range ns {
 		// Remove trailing dot
@@ -113,7 +131,7 @@ func (c *CloudflareCheckerStub) GetNameserversForBaseDomain(domain string) ([]st
 	return nameservers, nil
 }
 
-func IpLookupStub(host string) ([]net.IP, error) {
+func IpLookupStub(ctx context.Context, host string) ([]net.IP, error) {
 	known := map[string][]net.IP{
 		"example.com":                          {net.ParseIP("127.0.0.1")},
 		"sub.example.com":                      {net.ParseIP("127.0.0.2")},
@@ -127,10 +145,17 @@ func IpLookupStub(host string) ([]net.IP, error) {
 		return ips, nil
 	}
 
+	switch host {
+	case "servfail.example.com":
+		return nil, &net.DNSError{Err: "server misbehaving", Name: host}
+	case "missing.example.com":
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
 	return nil, nil
 }
 
-func NsLookupStub(host string) ([]*net.NS, error) {
+func NsLookupStub(ctx context.Context, host string) ([]*net.NS, error) {
 	known := map[string][]*net.NS{
 		"example.com":                          {&net.NS{Host: "ns1.example.com."}},
 		"example.co.uk":                        {&net.NS{Host: "ns1.example.co.uk."}},
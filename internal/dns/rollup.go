@@ -0,0 +1,56 @@
+package dns
+
+import "github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+
+// AppStatus is the status of an app, rolled up from the statuses of its domains. The app is INACTIVE when all of
+// its domains are, PARTIAL when only some of them are (or are PARTIAL themselves), UNKNOWN when any of the others
+// couldn't be checked, and OK otherwise. An app is only OK when every one of its domains is known to be.
+type AppStatus struct {
+	serverpilot.AppServer
+	Status int
+	// DomainsByStatus are the app's checked domains, grouped by their status.
+	DomainsByStatus map[int][]string
+}
+
+// RollUpByApp groups the domain statuses by app, and determines the status of each app. The apps are returned in the
+// same order as the appservers, leaving out the apps without any checked domains.
+func RollUpByApp(statuses []AppDomainStatus, appservers []serverpilot.AppServer) []AppStatus {
+	byApp := make(map[string]map[int][]string)
+	for _, s := range statuses {
+		if byApp[s.AppId] == nil {
+			byApp[s.AppId] = make(map[int][]string)
+		}
+		byApp[s.AppId][s.Status] = append(byApp[s.AppId][s.Status], s.Domain)
+	}
+
+	var results []AppStatus
+	for _, appserver := range appservers {
+		domains, ok := byApp[appserver.Id]
+		if !ok {
+			continue
+		}
+
+		results = append(results, AppStatus{appserver, rollUpStatus(domains), domains})
+	}
+
+	return results
+}
+
+func rollUpStatus(domains map[int][]string) int {
+	total := 0
+	for _, d := range domains {
+		total += len(d)
+	}
+
+	inactive, partial, unknown := len(domains[INACTIVE]), len(domains[PARTIAL]), len(domains[UNKNOWN])
+	switch {
+	case inactive == total:
+		return INACTIVE
+	case inactive > 0 || partial > 0:
+		return PARTIAL
+	case unknown > 0:
+		return UNKNOWN
+	}
+
+	return OK
+}
@@ -0,0 +1,64 @@
+package dns
+
+import (
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"gotest.tools/v3/assert"
+	"testing"
+)
+
+func TestRollUpByApp(t *testing.T) {
+	t.Run("it should determine the status of each app from its domains", func(t *testing.T) {
+		var tests = []struct {
+			name     string
+			statuses []int
+			want     int
+		}{
+			{"all ok", []int{OK, OK}, OK},
+			{"all inactive", []int{INACTIVE, INACTIVE}, INACTIVE},
+			{"a dead www. alias of a live app", []int{OK, INACTIVE}, PARTIAL},
+			{"a partial domain", []int{OK, PARTIAL}, PARTIAL},
+			{"inactive and unknown", []int{INACTIVE, UNKNOWN}, PARTIAL},
+			{"partial and unknown", []int{PARTIAL, UNKNOWN}, PARTIAL},
+			{"all unknown", []int{UNKNOWN, UNKNOWN}, UNKNOWN},
+			{"ok and unknown", []int{OK, UNKNOWN}, UNKNOWN},
+			{"ok, inactive and unknown", []int{OK, INACTIVE, UNKNOWN}, PARTIAL},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var statuses []AppDomainStatus
+				for _, s := range tt.statuses {
+					statuses = append(statuses, AppDomainStatus{AppId: "1", Status: s})
+				}
+
+				got := RollUpByApp(statuses, []serverpilot.AppServer{{App: serverpilot.App{Id: "1"}}})
+
+				assert.Equal(t, len(got), 1)
+				assert.Equal(t, got[0].Status, tt.want)
+			})
+		}
+	})
+
+	t.Run("it should group the domains of each app by status", func(t *testing.T) {
+		appservers := []serverpilot.AppServer{
+			{App: serverpilot.App{Id: "1", Name: "blog"}},
+			{App: serverpilot.App{Id: "2", Name: "shop"}},
+			{App: serverpilot.App{Id: "3", Name: "unchecked"}},
+		}
+		statuses := []AppDomainStatus{
+			{AppId: "2", Domain: "shop.com", Status: INACTIVE},
+			{AppId: "1", Domain: "blog.com", Status: OK},
+			{AppId: "1", Domain: "www.blog.com", Status: INACTIVE},
+			{AppId: "1", Domain: "old.blog.com", Status: INACTIVE},
+		}
+
+		got := RollUpByApp(statuses, appservers)
+
+		assert.Equal(t, len(got), 2)
+		assert.Equal(t, got[0].Name, "blog")
+		assert.Equal(t, got[0].Status, PARTIAL)
+		assert.DeepEqual(t, got[0].DomainsByStatus, map[int][]string{OK: {"blog.com"}, INACTIVE: {"www.blog.com", "old.blog.com"}})
+		assert.Equal(t, got[1].Name, "shop")
+		assert.Equal(t, got[1].Status, INACTIVE)
+	})
+}
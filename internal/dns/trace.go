@@ -0,0 +1,95 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Trace is a step taken while checking a domain, along with the steps taken within it, such as the records matched
+// for a CNAME. It shows why a domain got its status.
+type Trace struct {
+	Step  string
+	Steps []*Trace
+}
+
+type tracingKey struct{}
+type traceKey struct{}
+
+// WithTracing makes the checks done with the context record a Trace for each domain.
+func WithTracing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tracingKey{}, true)
+}
+
+func isTracing(ctx context.Context) bool {
+	return ctx.Value(tracingKey{}) != nil
+}
+
+// startTrace returns a trace for the domain when tracing, along with a context that records the steps within it.
+func startTrace(ctx context.Context, domain string) (context.Context, *Trace) {
+	if !isTracing(ctx) {
+		return ctx, nil
+	}
+	t := &Trace{Step: domain}
+	return context.WithValue(ctx, traceKey{}, t), t
+}
+
+// traceStep adds a step to the context's trace, if it has one, and returns a context that records the steps taken
+// within it.
+func traceStep(ctx context.Context, format string, args ...any) context.Context {
+	parent, ok := ctx.Value(traceKey{}).(*Trace)
+	if !ok {
+		return ctx
+	}
+	t := &Trace{Step: fmt.Sprintf(format, args...)}
+	parent.Steps = append(parent.Steps, t)
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// String renders the trace as a tree.
+func (t *Trace) String() string {
+	var b strings.Builder
+	b.WriteString(t.Step + "\n")
+	t.writeSteps(&b, "")
+	return b.String()
+}
+
+func (t *Trace) writeSteps(b *strings.Builder, indent string) {
+	for i, step := range t.Steps {
+		branch, next := "├── ", "│   "
+		if i == len(t.Steps)-1 {
+			branch, next = "└── ", "    "
+		}
+		b.WriteString(indent + branch + step.Step + "\n")
+		step.writeSteps(b, indent+next)
+	}
+}
+
+// listOrNone joins the items, or returns "none" when there aren't any.
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+func statusText(status int) string {
+	switch status {
+	case OK:
+		return "OK"
+	case INACTIVE:
+		return "INACTIVE"
+	case UNKNOWN:
+		return "UNKNOWN"
+	case PARTIAL:
+		return "PARTIAL"
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package dns
+
+import (
+	"context"
+	"github.com/jfortunato/serverpilot-tools/internal/http"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"gotest.tools/v3/assert"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	t.Run("it should record the steps taken to check each domain when tracing", func(t *testing.T) {
+		resolver := NewResolver(nil, &CloudflareCheckerStub{}, IpLookupStub, log.New(io.Discard, "", 0), http.ClientSettings{})
+		resolver.cfResolver.(*CloudflareResolver).c = &ClientStub{responses: combineResponses(
+			makeStubbedZoneResponse("https://api.cloudflare.com/client/v4/zones?name=domain-behind-cloudflare.com", []Zone{{"1"}}),
+			makeStubbedDnsResponse("https://api.cloudflare.com/client/v4/zones/1/dns_records?page=1&per_page=50", []DnsRecord{
+				{"CNAME", "www.domain-behind-cloudflare.com", "domain-behind-cloudflare.com"},
+				{"CNAME", "domain-behind-cloudflare.com", "sub.example.com"},
+			}),
+		)}
+		checker := NewDnsChecker(resolver, nil)
+		domains := []UnresolvedDomain{
+			{Name: "example.com", Nameservers: []string{"ns1.example.com"}},
+			{Name: "www.domain-behind-cloudflare.com", Nameservers: []string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"}, CloudflareMetadata: &CloudflareDomainMetadata{
+				BaseDomainNameservers: []string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"},
+				CloudflareCredentials: &Credentials{"foo@example.com", "123456789"},
+			}},
+		}
+		appservers := []serverpilot.AppServer{
+			{App: serverpilot.App{Id: "1", Domains: []string{"example.com", "www.domain-behind-cloudflare.com"}}, Server: serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
+		}
+
+		got, _ := checker.GetAppDomainStatuses(WithTracing(context.Background()), &FakeTicker{}, domains, appservers)
+
+		assert.Equal(t, got[0].Trace.String(), `example.com
+├── nameservers for example.com: ns1.example.com
+├── looked up example.com: 127.0.0.1
+├── compared with server server1: IPv4 127.0.0.1, IPv6 none
+└── status OK: IPv4 match, IPv6 none, foreign IPs none
+`)
+		assert.Equal(t, got[1].Trace.String(), `www.domain-behind-cloudflare.com
+├── nameservers for domain-behind-cloudflare.com: bar.ns.cloudflare.com, foo.ns.cloudflare.com
+├── behind Cloudflare, resolving www.domain-behind-cloudflare.com with the Cloudflare API
+│   ├── matched Cloudflare zone domain-behind-cloudflare.com (1)
+│   ├── fetched 2 DNS records from the zone
+│   └── record CNAME www.domain-behind-cloudflare.com -> domain-behind-cloudflare.com, within the zone
+│       └── record CNAME domain-behind-cloudflare.com -> sub.example.com, outside the zone
+│           └── looked up sub.example.com: 127.0.0.2
+├── resolved www.domain-behind-cloudflare.com to 127.0.0.2
+├── compared with server server1: IPv4 127.0.0.1, IPv6 none
+└── status INACTIVE: IPv4 mismatch, IPv6 none, foreign IPs 127.0.0.2
+`)
+	})
+
+	t.Run("it should not record anything when not tracing", func(t *testing.T) {
+		checker := NewDnsChecker(&IpResolverStub{map[string]string{"example.com": "127.0.0.1"}}, nil)
+
+		got, _ := checker.GetAppDomainStatuses(context.Background(), &FakeTicker{}, []UnresolvedDomain{{Name: "example.com"}}, nil)
+
+		assert.Assert(t, got[0].Trace == nil)
+	})
+}
@@ -0,0 +1,62 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"strings"
+)
+
+var (
+	ErrInvalidColumn = errors.New("invalid column")
+)
+
+// Columns are the columns that can be displayed for an app, in their default order.
+var Columns = []string{"id", "name", "server", "server-name", "sysuser", "domains", "runtime", "created", "account"}
+
+// DefaultColumns are displayed when no columns are specified.
+var DefaultColumns = []string{"id", "name", "server", "domains", "runtime", "created"}
+
+// ValidateColumns returns an error if any of the columns are unknown.
+func ValidateColumns(columns []string) error {
+	for _, column := range columns {
+		if !contains(Columns, column) {
+			return fmt.Errorf("%w: %s (must be one of %s)", ErrInvalidColumn, column, strings.Join(Columns, ", "))
+		}
+	}
+	return nil
+}
+
+// ColumnValue returns the text displayed for the app in the given column.
+func ColumnValue(app serverpilot.AppServer, column string) string {
+	switch column {
+	case "id":
+		return app.Id
+	case "name":
+		return app.Name
+	case "server":
+		return app.Serverid
+	case "server-name":
+		return app.Server.Name
+	case "sysuser":
+		return app.Sysuserid
+	case "domains":
+		return strings.Join(app.Domains, ", ")
+	case "runtime":
+		return string(app.Runtime)
+	case "created":
+		return app.Datecreated.String()
+	case "account":
+		return app.Account
+	}
+	return ""
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,472 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidExpression = errors.New("invalid filter expression")
+	ErrEvaluation        = errors.New("error while evaluating filter expression")
+)
+
+// Expression is a compiled filter expression, such as:
+//
+//	runtime < 8.1 && server.name =~ "^web" && any(domains, endswith(".example.com"))
+//
+// It is compiled once, and can then be matched against any number of apps.
+type Expression struct {
+	source string
+	root   node
+}
+
+// Compile parses the expression, and validates the fields, functions and regular expressions it uses.
+func Compile(expr string) (*Expression, error) {
+	root, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Expression{source: expr, root: root}, nil
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Match evaluates the expression against the app. The expression must evaluate to true or false.
+func (e *Expression) Match(app serverpilot.AppServer) (bool, error) {
+	v, err := e.root.eval(&env{app: app})
+	if err != nil {
+		return false, fmt.Errorf("%w: %s", ErrEvaluation, err)
+	}
+
+	if v.kind != boolValue {
+		return false, fmt.Errorf("%w: expression must be a condition, got a %s", ErrEvaluation, v.kindName())
+	}
+
+	return v.b, nil
+}
+
+// FilterAppServers only returns the apps that match the expression. A nil expression matches everything.
+func FilterAppServers(apps []serverpilot.AppServer, e *Expression) ([]serverpilot.AppServer, error) {
+	if e == nil {
+		return apps, nil
+	}
+
+	var filtered []serverpilot.AppServer
+
+	for _, app := range apps {
+		ok, err := e.Match(app)
+		if err != nil {
+			return nil, fmt.Errorf("app %s (%s): %w", app.Name, app.Id, err)
+		}
+		if ok {
+			filtered = append(filtered, app)
+		}
+	}
+
+	return filtered, nil
+}
+
+// fields are the properties of an AppServer that can be used in an expression.
+var fields = map[string]func(a serverpilot.AppServer) value{
+	"id":             func(a serverpilot.AppServer) value { return stringOf(a.Id) },
+	"name":           func(a serverpilot.AppServer) value { return stringOf(a.Name) },
+	"sysuserid":      func(a serverpilot.AppServer) value { return stringOf(a.Sysuserid) },
+	"runtime":        func(a serverpilot.AppServer) value { return value{kind: runtimeValue, s: string(a.Runtime)} },
+	"created":        func(a serverpilot.AppServer) value { return value{kind: dateValue, d: a.Datecreated} },
+	"domains":        func(a serverpilot.AppServer) value { return value{kind: listValue, list: a.Domains} },
+	"server.id":      func(a serverpilot.AppServer) value { return stringOf(a.Server.Id) },
+	"server.name":    func(a serverpilot.AppServer) value { return stringOf(a.Server.Name) },
+	"server.ip":      func(a serverpilot.AppServer) value { return stringOf(a.Server.Ipaddress) },
+	"server.ipv6":    func(a serverpilot.AppServer) value { return stringOf(a.Server.IPv6()) },
+	"server.created": func(a serverpilot.AppServer) value { return value{kind: dateValue, d: a.Server.Datecreated} },
+	"account":        func(a serverpilot.AppServer) value { return stringOf(a.Account) },
+}
+
+func fieldNames() string {
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+type function struct {
+	minArgs, maxArgs int
+	call             func(env *env, args []node) (value, error)
+}
+
+func (f function) arity() string {
+	if f.minArgs == f.maxArgs {
+		return fmt.Sprintf("%d argument(s)", f.maxArgs)
+	}
+	return fmt.Sprintf("%d to %d arguments", f.minArgs, f.maxArgs)
+}
+
+var functions map[string]function
+
+func init() {
+	// Assigned in init, since the any/all functions refer back to the map while evaluating
+	functions = map[string]function{
+		"any":        {2, 2, func(e *env, args []node) (value, error) { return quantify(e, args, true) }},
+		"all":        {2, 2, func(e *env, args []node) (value, error) { return quantify(e, args, false) }},
+		"len":        {1, 1, length},
+		"lower":      {1, 1, lower},
+		"startswith": {1, 2, stringPredicate(strings.HasPrefix)},
+		"endswith":   {1, 2, stringPredicate(strings.HasSuffix)},
+		"contains":   {1, 2, stringPredicate(strings.Contains)},
+	}
+}
+
+// quantify implements any() and all(). The predicate is evaluated once for each element of the list.
+func quantify(e *env, args []node, isAny bool) (value, error) {
+	list, err := args[0].eval(e)
+	if err != nil {
+		return value{}, err
+	}
+	if list.kind != listValue {
+		return value{}, fmt.Errorf("first argument must be a list, got a %s", list.kindName())
+	}
+
+	for _, item := range list.list {
+		it := stringOf(item)
+		result, err := args[1].eval(&env{app: e.app, it: &it})
+		if err != nil {
+			return value{}, err
+		}
+		if result.kind != boolValue {
+			return value{}, fmt.Errorf("second argument must be a condition, got a %s", result.kindName())
+		}
+		if result.b == isAny {
+			return boolOf(isAny), nil
+		}
+	}
+
+	return boolOf(!isAny), nil
+}
+
+func length(e *env, args []node) (value, error) {
+	v, err := args[0].eval(e)
+	if err != nil {
+		return value{}, err
+	}
+
+	switch v.kind {
+	case listValue:
+		return numberOf(float64(len(v.list))), nil
+	case stringValue, runtimeValue:
+		return numberOf(float64(len(v.s))), nil
+	}
+
+	return value{}, fmt.Errorf("len() requires a list or string, got a %s", v.kindName())
+}
+
+func lower(e *env, args []node) (value, error) {
+	v, err := args[0].eval(e)
+	if err != nil {
+		return value{}, err
+	}
+	if v.kind != stringValue {
+		return value{}, fmt.Errorf("lower() requires a string, got a %s", v.kindName())
+	}
+	return stringOf(strings.ToLower(v.s)), nil
+}
+
+// stringPredicate creates a function that takes (subject, arg), or just (arg) to use the current element as the subject.
+func stringPredicate(f func(s, substr string) bool) func(e *env, args []node) (value, error) {
+	return func(e *env, args []node) (value, error) {
+		var subject value
+		if len(args) == 1 {
+			if e.it == nil {
+				return value{}, errNoElement
+			}
+			subject = *e.it
+		} else {
+			var err error
+			subject, err = args[0].eval(e)
+			if err != nil {
+				return value{}, err
+			}
+		}
+
+		arg, err := args[len(args)-1].eval(e)
+		if err != nil {
+			return value{}, err
+		}
+		if subject.kind != stringValue || arg.kind != stringValue {
+			return value{}, fmt.Errorf("expected strings, got a %s and a %s", subject.kindName(), arg.kindName())
+		}
+
+		return boolOf(f(subject.s, arg.s)), nil
+	}
+}
+
+// errNoElement is returned when "it" is evaluated outside the predicate of any() or all(). The parser doesn't allow
+// it, so this only guards against a crash.
+var errNoElement = errors.New(`"it" can only be used inside the condition of any() or all()`)
+
+type env struct {
+	app serverpilot.AppServer
+	// it is the current element while evaluating the predicate of any() or all().
+	it *value
+}
+
+const (
+	stringValue int = iota
+	numberValue
+	boolValue
+	runtimeValue
+	dateValue
+	listValue
+)
+
+type value struct {
+	kind int
+	// s is the text of strings and runtimes, and the literal text of numbers (so "8.1" can also be a version).
+	s    string
+	n    float64
+	b    bool
+	d    serverpilot.DateCreated
+	list []string
+}
+
+func stringOf(s string) value {
+	return value{kind: stringValue, s: s}
+}
+
+func numberOf(n float64) value {
+	return value{kind: numberValue, n: n, s: strconv.FormatFloat(n, 'f', -1, 64)}
+}
+
+func boolOf(b bool) value {
+	return value{kind: boolValue, b: b}
+}
+
+// numberLiteral keeps the literal text, since a number like 7.4.3 is only meaningful as a version.
+func numberLiteral(s string) value {
+	n, _ := strconv.ParseFloat(s, 64)
+	return value{kind: numberValue, n: n, s: s}
+}
+
+func (v value) kindName() string {
+	return [...]string{"string", "number", "boolean", "runtime", "date", "list"}[v.kind]
+}
+
+// version converts a runtime, number (8.1) or string ("8.1" or "php8.1") into a comparable version.
+func (v value) version() (serverpilot.Version, error) {
+	switch v.kind {
+	case runtimeValue:
+		return serverpilot.Runtime(v.s).Version()
+	case numberValue, stringValue:
+		return serverpilot.ParseVersion(strings.TrimPrefix(v.s, "php"))
+	}
+	return serverpilot.Version{}, fmt.Errorf("cannot compare a %s to a runtime", v.kindName())
+}
+
+// date converts a date or a "YYYY-MM-DD" string into a comparable date.
+func (v value) date() (serverpilot.DateCreated, error) {
+	switch v.kind {
+	case dateValue:
+		return v.d, nil
+	case stringValue:
+		return serverpilot.DateCreatedFromDate(v.s)
+	}
+	return 0, fmt.Errorf("cannot compare a %s to a date", v.kindName())
+}
+
+type node interface {
+	eval(e *env) (value, error)
+}
+
+type literalNode struct {
+	v value
+}
+
+func (n *literalNode) eval(e *env) (value, error) {
+	return n.v, nil
+}
+
+type fieldNode struct {
+	name string
+}
+
+func (n *fieldNode) eval(e *env) (value, error) {
+	return fields[n.name](e.app), nil
+}
+
+type itNode struct{}
+
+func (n *itNode) eval(e *env) (value, error) {
+	if e.it == nil {
+		return value{}, errNoElement
+	}
+	return *e.it, nil
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n *callNode) eval(e *env) (value, error) {
+	v, err := functions[n.name].call(e, n.args)
+	if err != nil {
+		return value{}, fmt.Errorf("%s(): %w", n.name, err)
+	}
+	return v, nil
+}
+
+type notNode struct {
+	x node
+}
+
+func (n *notNode) eval(e *env) (value, error) {
+	v, err := n.x.eval(e)
+	if err != nil {
+		return value{}, err
+	}
+	if v.kind != boolValue {
+		return value{}, fmt.Errorf("! requires a condition, got a %s", v.kindName())
+	}
+	return boolOf(!v.b), nil
+}
+
+type binaryNode struct {
+	op   string
+	l, r node
+	// re is the pre-compiled regular expression when the right side of =~ or !~ is a literal.
+	re *regexp.Regexp
+}
+
+func (n *binaryNode) eval(e *env) (value, error) {
+	l, err := n.l.eval(e)
+	if err != nil {
+		return value{}, err
+	}
+
+	// Short circuit the logical operators
+	if n.op == "&&" || n.op == "||" {
+		if l.kind != boolValue {
+			return value{}, fmt.Errorf("%s requires conditions, got a %s", n.op, l.kindName())
+		}
+		if (n.op == "&&" && !l.b) || (n.op == "||" && l.b) {
+			return l, nil
+		}
+		r, err := n.r.eval(e)
+		if err != nil {
+			return value{}, err
+		}
+		if r.kind != boolValue {
+			return value{}, fmt.Errorf("%s requires conditions, got a %s", n.op, r.kindName())
+		}
+		return r, nil
+	}
+
+	r, err := n.r.eval(e)
+	if err != nil {
+		return value{}, err
+	}
+
+	if n.op == "=~" || n.op == "!~" {
+		return n.match(l, r)
+	}
+
+	cmp, err := compare(l, r)
+	if err != nil {
+		return value{}, err
+	}
+
+	switch n.op {
+	case "==":
+		return boolOf(cmp == 0), nil
+	case "!=":
+		return boolOf(cmp != 0), nil
+	case "<":
+		return boolOf(cmp < 0), nil
+	case "<=":
+		return boolOf(cmp <= 0), nil
+	case ">":
+		return boolOf(cmp > 0), nil
+	case ">=":
+		return boolOf(cmp >= 0), nil
+	}
+
+	return value{}, fmt.Errorf("unknown operator %s", n.op)
+}
+
+func (n *binaryNode) match(l, r value) (value, error) {
+	if l.kind != stringValue && l.kind != runtimeValue {
+		return value{}, fmt.Errorf("%s requires a string on the left, got a %s", n.op, l.kindName())
+	}
+
+	re := n.re
+	if re == nil {
+		if r.kind != stringValue {
+			return value{}, fmt.Errorf("%s requires a regular expression on the right, got a %s", n.op, r.kindName())
+		}
+		var err error
+		re, err = regexp.Compile(r.s)
+		if err != nil {
+			return value{}, err
+		}
+	}
+
+	return boolOf(re.MatchString(l.s) == (n.op == "=~")), nil
+}
+
+// compare returns -1, 0 or 1. Runtimes and dates are compared by converting the other side to a version or date.
+func compare(l, r value) (int, error) {
+	switch {
+	case l.kind == runtimeValue || r.kind == runtimeValue:
+		lv, err := l.version()
+		if err != nil {
+			return 0, err
+		}
+		rv, err := r.version()
+		if err != nil {
+			return 0, err
+		}
+		return lv.Compare(rv), nil
+	case l.kind == dateValue || r.kind == dateValue:
+		ld, err := l.date()
+		if err != nil {
+			return 0, err
+		}
+		rd, err := r.date()
+		if err != nil {
+			return 0, err
+		}
+		return compareOrdered(ld, rd), nil
+	case l.kind != r.kind:
+		return 0, fmt.Errorf("cannot compare a %s to a %s", l.kindName(), r.kindName())
+	case l.kind == numberValue:
+		return compareOrdered(l.n, r.n), nil
+	case l.kind == stringValue:
+		return strings.Compare(l.s, r.s), nil
+	case l.kind == boolValue:
+		if l.b == r.b {
+			return 0, nil
+		}
+		return 1, nil
+	}
+
+	return 0, fmt.Errorf("cannot compare a %s", l.kindName())
+}
+
+func compareOrdered[T int64 | float64 | serverpilot.DateCreated](a, b T) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,349 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	tokenEOF int = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+// operators are ordered so that the longer operators are matched first.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!"}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(input); {
+		c := rune(input[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokenComma, ",", i})
+			i++
+		case c == '"' || c == '\'':
+			// Find the closing quote, skipping over escaped characters
+			end := i + 1
+			for end < len(input) && rune(input[end]) != c {
+				if input[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(input) {
+				return nil, expressionError(input, i, "unterminated string")
+			}
+			s, err := unquote(input[i+1:end], c)
+			if err != nil {
+				return nil, expressionError(input, i, "invalid string")
+			}
+			tokens = append(tokens, token{tokenString, s, i})
+			i = end + 1
+		case unicode.IsDigit(c):
+			end := i
+			for end < len(input) && (unicode.IsDigit(rune(input[end])) || input[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{tokenNumber, input[i:end], i})
+			i = end
+		case unicode.IsLetter(c) || c == '_':
+			end := i
+			for end < len(input) && (unicode.IsLetter(rune(input[end])) || unicode.IsDigit(rune(input[end])) || input[end] == '_' || input[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{tokenIdent, input[i:end], i})
+			i = end
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(input[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, expressionError(input, i, fmt.Sprintf("unexpected character %q", c))
+			}
+			tokens = append(tokens, token{tokenOperator, op, i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", len(input)}), nil
+}
+
+func unquote(s string, quote rune) (string, error) {
+	if quote == '\'' {
+		// Single quoted strings only support escaping the quote itself and backslashes
+		s = strings.ReplaceAll(s, `\'`, `'`)
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return strconv.Unquote(`"` + s + `"`)
+}
+
+// parser is a recursive descent parser for filter expressions. From lowest to highest precedence:
+//
+//	or         = and { "||" and }
+//	and        = not { "&&" not }
+//	not        = "!" not | comparison
+//	comparison = primary [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "=~" | "!~" ) primary ]
+//	primary    = string | number | "true" | "false" | field | call | "(" or ")"
+//	call       = ident "(" [ or { "," or } ] ")"
+type parser struct {
+	input  string
+	tokens []token
+	pos    int
+	// predicates is greater than 0 while parsing the arguments to any() or all(), where "it" is the current element.
+	predicates int
+}
+
+func parse(input string) (node, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{input: input, tokens: tokens}
+
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, expressionError(input, t.pos, fmt.Sprintf("unexpected %q", t.text))
+	}
+
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOperator(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "||", l: left, r: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "&&", l: left, r: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isOperator("!") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isOperator("==", "!=", "<", "<=", ">", ">=", "=~", "!~") {
+		return left, nil
+	}
+
+	op := p.next()
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &binaryNode{op: op.text, l: left, r: right}
+
+	// Compile regular expressions up front when we can, so they aren't compiled for every app
+	if op.text == "=~" || op.text == "!~" {
+		if lit, ok := right.(*literalNode); ok {
+			n.re, err = regexp.Compile(lit.v.s)
+			if err != nil {
+				return nil, expressionError(p.input, op.pos, fmt.Sprintf("invalid regular expression: %s", err))
+			}
+		}
+	}
+
+	return n, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenString:
+		return &literalNode{value{kind: stringValue, s: t.text}}, nil
+	case tokenNumber:
+		return &literalNode{numberLiteral(t.text)}, nil
+	case tokenLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenRParen {
+			return nil, expressionError(p.input, t.pos, "expected )")
+		}
+		return n, nil
+	case tokenIdent:
+		if p.peek().kind == tokenLParen {
+			return p.parseCall(t)
+		}
+		return p.parseIdent(t)
+	case tokenEOF:
+		return nil, expressionError(p.input, t.pos, "unexpected end of expression")
+	}
+
+	return nil, expressionError(p.input, t.pos, fmt.Sprintf("unexpected %q", t.text))
+}
+
+func (p *parser) parseIdent(t token) (node, error) {
+	switch t.text {
+	case "true":
+		return &literalNode{value{kind: boolValue, b: true}}, nil
+	case "false":
+		return &literalNode{value{kind: boolValue, b: false}}, nil
+	case "it":
+		if p.predicates == 0 {
+			return nil, expressionError(p.input, t.pos, `"it" can only be used inside any() or all()`)
+		}
+		return &itNode{}, nil
+	}
+
+	if _, ok := fields[t.text]; !ok {
+		return nil, expressionError(p.input, t.pos, fmt.Sprintf("unknown field %q (valid fields are %s)", t.text, fieldNames()))
+	}
+
+	return &fieldNode{t.text}, nil
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	f, ok := functions[name.text]
+	if !ok {
+		return nil, expressionError(p.input, name.pos, fmt.Sprintf("unknown function %q", name.text))
+	}
+
+	// Skip the opening parenthesis
+	p.next()
+
+	isPredicate := name.text == "any" || name.text == "all"
+
+	var args []node
+	for p.peek().kind != tokenRParen {
+		if len(args) > 0 {
+			if t := p.next(); t.kind != tokenComma {
+				return nil, expressionError(p.input, t.pos, "expected , or )")
+			}
+		}
+		arg, err := p.parseArg(isPredicate && len(args) == 1)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+
+	if len(args) < f.minArgs || len(args) > f.maxArgs {
+		return nil, expressionError(p.input, name.pos, fmt.Sprintf("%s() takes %s", name.text, f.arity()))
+	}
+
+	// The short form of the string functions (e.g. endswith(".com")) applies to the current element
+	if len(args) < f.maxArgs && p.predicates == 0 {
+		return nil, expressionError(p.input, name.pos, fmt.Sprintf("%s() with %d argument(s) can only be used inside any() or all()", name.text, len(args)))
+	}
+
+	return &callNode{name: name.text, args: args}, nil
+}
+
+// parseArg parses an argument to a call. Only the predicate (the second argument to any() or all()) is evaluated for
+// each element, so "it" can only be used within it.
+func (p *parser) parseArg(isPredicate bool) (node, error) {
+	if isPredicate {
+		p.predicates++
+		defer func() { p.predicates-- }()
+	}
+	return p.parseOr()
+}
+
+func expressionError(input string, pos int, msg string) error {
+	return fmt.Errorf("%w: %s at position %d in %q", ErrInvalidExpression, msg, pos+1, input)
+}
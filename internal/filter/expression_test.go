@@ -0,0 +1,142 @@
+package filter
+
+import (
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"gotest.tools/v3/assert"
+	"testing"
+)
+
+func TestExpression(t *testing.T) {
+	app := serverpilot.AppServer{
+		App: serverpilot.App{
+			Id:          "1",
+			Name:        "blog",
+			Sysuserid:   "u1",
+			Runtime:     "php8.0",
+			Domains:     []string{"blog.example.com", "www.blog.example.com"},
+			Datecreated: stringToDateCreated("2023-01-01"),
+		},
+		Server:  serverpilot.Server{Id: "s1", Name: "web-01", Ipaddress: "127.0.0.1"},
+		Account: "agency",
+	}
+
+	t.Run("it should match an app against an expression", func(t *testing.T) {
+		var tests = []struct {
+			expr string
+			want bool
+		}{
+			{`runtime < 8.1 && server.name =~ "^web" && any(domains, endswith(".example.com"))`, true},
+			{`runtime < 8.1`, true},
+			{`runtime >= 8.1`, false},
+			{`runtime < 8.10`, true},
+			{`runtime == "php8.0"`, true},
+			{`runtime == 8.2`, false},
+			{`runtime =~ "^php8"`, true},
+			{`name == "blog"`, true},
+			{`name != 'blog'`, false},
+			{`server.name !~ "^web"`, false},
+			{`server.ip == "127.0.0.1"`, true},
+			{`sysuserid == "u1"`, true},
+			{`account == "agency"`, true},
+			{`created > "2022-12-31" && created < "2023-01-02"`, true},
+			{`created >= "2023-06-01"`, false},
+			{`len(domains) == 2`, true},
+			{`len(domains) > 2`, false},
+			{`all(domains, endswith(".example.com"))`, true},
+			{`all(domains, startswith("www."))`, false},
+			{`any(domains, startswith("www."))`, true},
+			{`any(domains, it == "blog.example.com")`, true},
+			{`any(domains, contains(it, "nope"))`, false},
+			{`contains(name, "lo")`, true},
+			{`lower(server.name) == "web-01"`, true},
+			{`!(runtime < 8.1)`, false},
+			{`runtime >= 8.1 || name == "blog"`, true},
+			{`true`, true},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.expr, func(t *testing.T) {
+				e, err := Compile(tt.expr)
+				assert.NilError(t, err)
+
+				got, err := e.Match(app)
+
+				assert.NilError(t, err)
+				assert.Equal(t, got, tt.want)
+			})
+		}
+	})
+
+	t.Run("it should return an error for invalid expressions", func(t *testing.T) {
+		var tests = []struct {
+			name string
+			expr string
+		}{
+			{"unknown field", `foo == "bar"`},
+			{"unknown function", `startswiht(name, "b")`},
+			{"wrong number of arguments", `any(domains)`},
+			{"short form outside any/all", `endswith(".com")`},
+			{"it outside any/all", `it == "blog"`},
+			{"it as the list of any", `any(it, true)`},
+			{"it within the list of any", `any(lower(it), true)`},
+			{"short form as the list of all", `all(startswith("a"), true)`},
+			{"invalid regex", `name =~ "("`},
+			{"unterminated string", `name == "blog`},
+			{"unbalanced parens", `(name == "blog"`},
+			{"trailing tokens", `name == "blog" "extra"`},
+			{"unexpected character", `name = "blog"`},
+			{"empty", ``},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := Compile(tt.expr)
+
+				assert.ErrorIs(t, err, ErrInvalidExpression)
+			})
+		}
+	})
+
+	t.Run("it should return an error when the expression can't be evaluated", func(t *testing.T) {
+		var tests = []struct {
+			name string
+			expr string
+		}{
+			{"not a condition", `name`},
+			{"comparing incompatible types", `name == 1`},
+			{"invalid date", `created > "yesterday"`},
+			{"invalid version", `runtime > "latest"`},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				e, err := Compile(tt.expr)
+				assert.NilError(t, err)
+
+				_, err = e.Match(app)
+
+				assert.ErrorIs(t, err, ErrEvaluation)
+			})
+		}
+	})
+
+	t.Run("it should filter a list of apps", func(t *testing.T) {
+		other := app
+		other.Id = "2"
+		other.Runtime = "php8.2"
+
+		e, _ := Compile(`runtime >= 8.1`)
+
+		got, err := FilterAppServers([]serverpilot.AppServer{app, other}, e)
+
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, []serverpilot.AppServer{other})
+	})
+
+	t.Run("it should not filter when there is no expression", func(t *testing.T) {
+		got, err := FilterAppServers([]serverpilot.AppServer{app}, nil)
+
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, []serverpilot.AppServer{app})
+	})
+}
@@ -1,6 +1,7 @@
 package filter
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,33 +16,19 @@ var (
 )
 
 type HttpClient interface {
-	Get(url string) (string, error)
+	Get(ctx context.Context, url string) (string, error)
 }
 
-func FilterApps(c HttpClient, minRuntime, maxRuntime serverpilot.Runtime, createdAfter, createdBefore serverpilot.DateCreated) ([]serverpilot.App, error) {
-	if minRuntime == "" {
-		minRuntime = "php0.0.0"
-	}
-
-	if maxRuntime == "" {
-		maxRuntime = "php999999.9.9"
-	}
-	minR, err := minRuntime.Version()
-	if err != nil {
-		return nil, err
-	}
-	maxR, err := maxRuntime.Version()
-	if err != nil {
-		return nil, err
-	}
-
+// FilterApps fetches all apps, and only returns the ones with a runtime in the given range that were created within
+// the given dates. A nil range or a zero date leaves that filter unbounded.
+func FilterApps(ctx context.Context, c HttpClient, runtimes serverpilot.RuntimeRange, createdAfter, createdBefore serverpilot.DateCreated) ([]serverpilot.App, error) {
 	if createdBefore == 0 {
 		createdBefore = serverpilot.DateCreated(time.Now().Unix())
 	}
 
-	resp, err := c.Get("https://api.serverpilot.io/v1/apps")
+	resp, err := c.Get(ctx, "/apps")
 	if err != nil {
-		return nil, fmt.Errorf("%w: %s", ErrInvalidRequest, err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
 	}
 
 	// Transform the JSON response into a slice of App structs.
@@ -54,27 +41,35 @@ func FilterApps(c HttpClient, minRuntime, maxRuntime serverpilot.Runtime, create
 	}
 
 	// Filter the apps by runtime.
-	apps := filterByRuntime(appResponse.Data, minR, maxR)
+	apps, err := filterByRuntime(appResponse.Data, runtimes)
+	if err != nil {
+		return nil, err
+	}
 	// Filter the apps by creation date.
 	apps = filterByDate(apps, createdAfter, createdBefore)
 
 	return apps, nil
 }
 
-func filterByRuntime(apps []serverpilot.App, minR, maxR string) []serverpilot.App {
+func filterByRuntime(apps []serverpilot.App, runtimes serverpilot.RuntimeRange) ([]serverpilot.App, error) {
+	// Don't require every app to have a parsable runtime when we aren't filtering on it.
+	if len(runtimes) == 0 {
+		return apps, nil
+	}
+
 	var filteredApps []serverpilot.App
 
 	for _, app := range apps {
 		appVersion, err := app.Runtime.Version()
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("could not filter app %s (%s) by runtime: %w", app.Name, app.Id, err)
 		}
-		if appVersion >= minR && appVersion <= maxR {
+		if runtimes.Contains(appVersion) {
 			filteredApps = append(filteredApps, app)
 		}
 	}
 
-	return filteredApps
+	return filteredApps, nil
 }
 
 func filterByDate(apps []serverpilot.App, createdAfter, createdBefore serverpilot.DateCreated) []serverpilot.App {
@@ -1,6 +1,7 @@
 package filter
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
@@ -14,10 +15,10 @@ func TestFilterApps(t *testing.T) {
 		app2 := genApp(serverpilot.App{Name: "app2", Runtime: "php8.2"})
 
 		client := &HttpClientStub{responses: map[string]string{
-			"https://api.serverpilot.io/v1/apps": responseWithApps([]serverpilot.App{app1, app2}),
+			"/apps": responseWithApps([]serverpilot.App{app1, app2}),
 		}}
 
-		got, err := FilterApps(client, "", "", 0, 0)
+		got, err := FilterApps(context.Background(), client, nil, 0, 0)
 		want := []serverpilot.App{app1, app2}
 
 		assert.DeepEqual(t, got, want)
@@ -28,7 +29,7 @@ func TestFilterApps(t *testing.T) {
 		// No stubbed response results in an error.
 		client := &HttpClientStub{}
 
-		_, err := FilterApps(client, "", "", 0, 0)
+		_, err := FilterApps(context.Background(), client, nil, 0, 0)
 
 		assert.ErrorIs(t, err, ErrInvalidRequest)
 	})
@@ -36,10 +37,10 @@ func TestFilterApps(t *testing.T) {
 	t.Run("it handles an error while decoding the json response", func(t *testing.T) {
 		// The response is valid, but the json is nonsense.
 		client := &HttpClientStub{responses: map[string]string{
-			"https://api.serverpilot.io/v1/apps": `{nonsense}`,
+			"/apps": `{nonsense}`,
 		}}
 
-		_, err := FilterApps(client, "", "", 0, 0)
+		_, err := FilterApps(context.Background(), client, nil, 0, 0)
 
 		assert.ErrorIs(t, err, ErrInvalidJson)
 	})
@@ -94,10 +95,13 @@ func TestFilterApps(t *testing.T) {
 				app2 := genApp(serverpilot.App{Name: "app2", Runtime: "php8.2"})
 
 				client := &HttpClientStub{responses: map[string]string{
-					"https://api.serverpilot.io/v1/apps": responseWithApps([]serverpilot.App{app1, app2}),
+					"/apps": responseWithApps([]serverpilot.App{app1, app2}),
 				}}
 
-				got, err := FilterApps(client, tt.minRuntime, tt.maxRuntime, 0, 0)
+				runtimes, err := serverpilot.RuntimeRangeFromBounds(tt.minRuntime, tt.maxRuntime)
+				assert.NilError(t, err)
+
+				got, err := FilterApps(context.Background(), client, runtimes, 0, 0)
 
 				assert.DeepEqual(t, got, tt.want)
 				assert.NilError(t, err)
@@ -152,13 +156,13 @@ func TestFilterApps(t *testing.T) {
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
 				client := &HttpClientStub{responses: map[string]string{
-					"https://api.serverpilot.io/v1/apps": responseWithApps([]serverpilot.App{
+					"/apps": responseWithApps([]serverpilot.App{
 						genApp(serverpilot.App{Name: "app1", Datecreated: stringToDateCreated("2023-01-01")}),
 						genApp(serverpilot.App{Name: "app2", Datecreated: stringToDateCreated("2023-02-01")}),
 					}),
 				}}
 
-				got, err := FilterApps(client, "", "", tt.minCreated, tt.maxCreated)
+				got, err := FilterApps(context.Background(), client, nil, tt.minCreated, tt.maxCreated)
 
 				assert.DeepEqual(t, got, tt.want)
 				assert.NilError(t, err)
@@ -166,24 +170,47 @@ func TestFilterApps(t *testing.T) {
 		}
 	})
 
-	t.Run("it returns an error when supplied an invalid runtime", func(t *testing.T) {
-		var tests = []struct {
-			name                   string
-			minRuntime, maxRuntime serverpilot.Runtime
-		}{
-			{"invalid min runtime", "7.4", "php8.2"},
-			{"invalid min runtime", "php7.4", "8.2"},
-		}
+	t.Run("it compares runtimes numerically", func(t *testing.T) {
+		app1 := genApp(serverpilot.App{Name: "app1", Runtime: "php8.2"})
+		app2 := genApp(serverpilot.App{Name: "app2", Runtime: "php8.10"})
 
-		for _, tt := range tests {
-			t.Run(tt.name, func(t *testing.T) {
-				client := &HttpClientStub{}
+		client := &HttpClientStub{responses: map[string]string{
+			"/apps": responseWithApps([]serverpilot.App{app1, app2}),
+		}}
 
-				_, err := FilterApps(client, tt.minRuntime, tt.maxRuntime, 0, 0)
+		runtimes, _ := serverpilot.ParseRuntimeRange(">=8.3")
 
-				assert.ErrorIs(t, err, serverpilot.ErrInvalidRuntime)
-			})
-		}
+		got, err := FilterApps(context.Background(), client, runtimes, 0, 0)
+
+		assert.DeepEqual(t, got, []serverpilot.App{app2})
+		assert.NilError(t, err)
+	})
+
+	t.Run("it returns an error when an app has an invalid runtime", func(t *testing.T) {
+		client := &HttpClientStub{responses: map[string]string{
+			"/apps": responseWithApps([]serverpilot.App{
+				genApp(serverpilot.App{Name: "app1", Runtime: "nodejs18"}),
+			}),
+		}}
+
+		runtimes, _ := serverpilot.ParseRuntimeRange("8.x")
+
+		_, err := FilterApps(context.Background(), client, runtimes, 0, 0)
+
+		assert.ErrorIs(t, err, serverpilot.ErrInvalidRuntime)
+	})
+
+	t.Run("it does not require a valid runtime when not filtering by runtime", func(t *testing.T) {
+		app1 := genApp(serverpilot.App{Name: "app1", Runtime: "nodejs18"})
+
+		client := &HttpClientStub{responses: map[string]string{
+			"/apps": responseWithApps([]serverpilot.App{app1}),
+		}}
+
+		got, err := FilterApps(context.Background(), client, nil, 0, 0)
+
+		assert.DeepEqual(t, got, []serverpilot.App{app1})
+		assert.NilError(t, err)
 	})
 }
 
@@ -216,7 +243,7 @@ type HttpClientStub struct {
 	responses map[string]string
 }
 
-func (c *HttpClientStub) Get(url string) (string, error) {
+func (c *HttpClientStub) Get(ctx context.Context, url string) (string, error) {
 	response, ok := c.responses[url]
 	if !ok {
 		return "", errors.New("stubbed response not found")
@@ -0,0 +1,117 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidSort  = errors.New("invalid sort field")
+	ErrInvalidGroup = errors.New("invalid group field")
+)
+
+// SortFields are the fields apps can be sorted by.
+var SortFields = []string{"name", "created", "runtime", "server", "account"}
+
+// GroupFields are the fields apps can be grouped by.
+var GroupFields = []string{"server", "runtime", "sysuser", "account"}
+
+// AppGroup is a set of apps that share the same value for the grouped field.
+type AppGroup struct {
+	Key  string
+	Apps []serverpilot.AppServer
+}
+
+// SortAppServers sorts the apps in place. Runtimes are sorted by version, with any unparsable runtimes last.
+func SortAppServers(apps []serverpilot.AppServer, by string) error {
+	var less func(a, b serverpilot.AppServer) bool
+
+	switch by {
+	case "":
+		return nil
+	case "name":
+		less = func(a, b serverpilot.AppServer) bool { return a.Name < b.Name }
+	case "created":
+		less = func(a, b serverpilot.AppServer) bool { return a.Datecreated < b.Datecreated }
+	case "runtime":
+		less = func(a, b serverpilot.AppServer) bool { return compareRuntimes(a.Runtime, b.Runtime) < 0 }
+	case "server":
+		less = func(a, b serverpilot.AppServer) bool { return a.Server.Name < b.Server.Name }
+	case "account":
+		less = func(a, b serverpilot.AppServer) bool { return a.Account < b.Account }
+	default:
+		return fmt.Errorf("%w: %s (must be one of %s)", ErrInvalidSort, by, strings.Join(SortFields, ", "))
+	}
+
+	sort.SliceStable(apps, func(i, j int) bool {
+		return less(apps[i], apps[j])
+	})
+
+	return nil
+}
+
+// GroupAppServers splits the apps into groups, keeping the order of the apps within each group. Sysusers are
+// grouped by name when it is found in sysuserNames, otherwise by id.
+func GroupAppServers(apps []serverpilot.AppServer, by string, sysuserNames map[string]string) ([]AppGroup, error) {
+	var key func(a serverpilot.AppServer) string
+
+	switch by {
+	case "server":
+		key = func(a serverpilot.AppServer) string { return a.Server.Name }
+	case "runtime":
+		key = func(a serverpilot.AppServer) string { return string(a.Runtime) }
+	case "sysuser":
+		key = func(a serverpilot.AppServer) string {
+			if name, ok := sysuserNames[a.Sysuserid]; ok {
+				return name
+			}
+			return a.Sysuserid
+		}
+	case "account":
+		key = func(a serverpilot.AppServer) string { return a.Account }
+	default:
+		return nil, fmt.Errorf("%w: %s (must be one of %s)", ErrInvalidGroup, by, strings.Join(GroupFields, ", "))
+	}
+
+	var groups []AppGroup
+	index := make(map[string]int)
+
+	for _, app := range apps {
+		k := key(app)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, AppGroup{Key: k})
+		}
+		groups[i].Apps = append(groups[i].Apps, app)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if by == "runtime" {
+			return compareRuntimes(serverpilot.Runtime(groups[i].Key), serverpilot.Runtime(groups[j].Key)) < 0
+		}
+		return groups[i].Key < groups[j].Key
+	})
+
+	return groups, nil
+}
+
+func compareRuntimes(a, b serverpilot.Runtime) int {
+	av, aErr := a.Version()
+	bv, bErr := b.Version()
+
+	switch {
+	case aErr == nil && bErr == nil:
+		return av.Compare(bv)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+
+	return strings.Compare(string(a), string(b))
+}
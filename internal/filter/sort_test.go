@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"gotest.tools/v3/assert"
+	"testing"
+)
+
+func TestSortAndGroup(t *testing.T) {
+	apps := func() []serverpilot.AppServer {
+		return []serverpilot.AppServer{
+			{App: serverpilot.App{Name: "charlie", Runtime: "php8.10", Sysuserid: "u1", Datecreated: 300}, Server: serverpilot.Server{Name: "web-02"}, Account: "legacy"},
+			{App: serverpilot.App{Name: "alpha", Runtime: "php8.2", Sysuserid: "u2", Datecreated: 100}, Server: serverpilot.Server{Name: "web-01"}, Account: "agency"},
+			{App: serverpilot.App{Name: "bravo", Runtime: "php7.4", Sysuserid: "u1", Datecreated: 200}, Server: serverpilot.Server{Name: "web-02"}, Account: "legacy"},
+		}
+	}
+
+	names := func(apps []serverpilot.AppServer) []string {
+		var n []string
+		for _, app := range apps {
+			n = append(n, app.Name)
+		}
+		return n
+	}
+
+	t.Run("it should sort apps", func(t *testing.T) {
+		var tests = []struct {
+			by   string
+			want []string
+		}{
+			{"", []string{"charlie", "alpha", "bravo"}},
+			{"name", []string{"alpha", "bravo", "charlie"}},
+			{"created", []string{"alpha", "bravo", "charlie"}},
+			{"runtime", []string{"bravo", "alpha", "charlie"}},
+			{"server", []string{"alpha", "charlie", "bravo"}},
+			{"account", []string{"alpha", "charlie", "bravo"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.by, func(t *testing.T) {
+				got := apps()
+
+				err := SortAppServers(got, tt.by)
+
+				assert.NilError(t, err)
+				assert.DeepEqual(t, names(got), tt.want)
+			})
+		}
+	})
+
+	t.Run("it should return an error for an unknown sort field", func(t *testing.T) {
+		err := SortAppServers(apps(), "domains")
+
+		assert.ErrorIs(t, err, ErrInvalidSort)
+	})
+
+	t.Run("it should group apps", func(t *testing.T) {
+		var tests = []struct {
+			by       string
+			wantKeys []string
+			wantApps [][]string
+		}{
+			{"server", []string{"web-01", "web-02"}, [][]string{{"alpha"}, {"charlie", "bravo"}}},
+			{"runtime", []string{"php7.4", "php8.2", "php8.10"}, [][]string{{"bravo"}, {"alpha"}, {"charlie"}}},
+			{"sysuser", []string{"u2", "user1"}, [][]string{{"alpha"}, {"charlie", "bravo"}}},
+			{"account", []string{"agency", "legacy"}, [][]string{{"alpha"}, {"charlie", "bravo"}}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.by, func(t *testing.T) {
+				groups, err := GroupAppServers(apps(), tt.by, map[string]string{"u1": "user1"})
+
+				assert.NilError(t, err)
+
+				var gotKeys []string
+				var gotApps [][]string
+				for _, group := range groups {
+					gotKeys = append(gotKeys, group.Key)
+					gotApps = append(gotApps, names(group.Apps))
+				}
+
+				assert.DeepEqual(t, gotKeys, tt.wantKeys)
+				assert.DeepEqual(t, gotApps, tt.wantApps)
+			})
+		}
+	})
+
+	t.Run("it should return an error for an unknown group field", func(t *testing.T) {
+		_, err := GroupAppServers(apps(), "name", nil)
+
+		assert.ErrorIs(t, err, ErrInvalidGroup)
+	})
+}
@@ -1,98 +1,393 @@
 package http
 
 import (
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
-// CacheFilename is the name of the cache file, under the os temp directory.
-const CacheFilename = "serverpilot-tools.cache"
+var (
+	ErrCacheMiss         = errors.New("cache miss")
+	ErrCouldNotLockCache = errors.New("could not lock cache")
+)
+
+// CacheDirname is the name of the cache directory, under the user's cache directory.
+const CacheDirname = "serverpilot-tools"
 
-// CacheLifetime is the amount of time that a cache file is valid for. It is based on the file mtime.
+// CacheLifetime is the default amount of time that a cached response is valid for.
 const CacheLifetime = 24 * time.Hour // 1 day
 
-// tmpFileCacher implements the cacher interface. It caches serverpilot API
-// responses in a temporary file.
-type tmpFileCacher struct{}
+// CacheMaxSize is the default size, in bytes, the cache directory is allowed to grow to before the least recently
+// used entries are evicted.
+const CacheMaxSize = 50 * 1024 * 1024 // 50MB
+
+const (
+	entryExt     = ".json"
+	lockFilename = ".lock"
+	// lockTimeout is how long to wait for another process to release the lock, after which it is assumed to be stale.
+	lockTimeout = 10 * time.Second
+)
+
+// FileStore implements the cacher interface. Each entry is stored in its own file, named after the hash of its key,
+// along with the time it expires. Expired entries with an ETag or Last-Modified validator are kept, so they can be
+// revalidated instead of fetched again. Entries are written atomically, so concurrent runs never see a partial entry,
+// and writes are serialized with a lock file. The modification time of each entry is updated when it is read, so
+// the least recently used entries can be evicted when the cache grows past its maximum size.
+type FileStore struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+	now     func() time.Time
+}
+
+// cacheEntry is the contents of a single cache file.
+type cacheEntry struct {
+	Key          string    `json:"key"`
+	Created      time.Time `json:"created"`
+	Expires      time.Time `json:"expires"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Value        string    `json:"value"`
+}
 
-func (c *tmpFileCacher) Has(key string) bool {
-	b, err := os.ReadFile(c.filename())
+// NewFileStore returns a FileStore that keeps its entries in dir. New entries expire after ttl, and the least
+// recently used entries are evicted once the entries take up more than maxSize bytes.
+func NewFileStore(dir string, ttl time.Duration, maxSize int64) *FileStore {
+	return &FileStore{dir: dir, ttl: ttl, maxSize: maxSize, now: time.Now}
+}
+
+// DefaultCacheDir returns the directory responses are cached in, under the user's cache directory when it is
+// available, or the os temp directory otherwise.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
 	if err != nil {
-		return false
+		dir = os.TempDir()
 	}
+	return filepath.Join(dir, CacheDirname)
+}
 
-	return bytes.Contains(b, []byte(key))
+// Has returns true when there is an entry for the key that hasn't expired.
+func (s *FileStore) Has(key string) bool {
+	e, err := s.read(key)
+	return err == nil && !s.isExpired(e)
 }
 
-func (c *tmpFileCacher) Get(key string) (string, error) {
-	// If the cache file is older than the cache lifetime, delete it.
-	if c.isExpired() {
-		err := c.delete()
-		if err != nil {
-			return "", fmt.Errorf("could not delete expired cache file: %s", err)
-		}
-		return "", fmt.Errorf("cache file expired")
+func (s *FileStore) Get(key string) (CachedResponse, error) {
+	e, err := s.read(key)
+	if err != nil {
+		return CachedResponse{}, err
 	}
 
-	// Read the contents of the file.
-	b, err := os.ReadFile(c.filename())
+	// Mark the entry as recently used. It doesn't matter if this fails, it will just be evicted sooner.
+	now := s.now()
+	_ = os.Chtimes(s.filename(key), now, now)
+
+	return CachedResponse{Body: e.Value, ETag: e.ETag, LastModified: e.LastModified, Stale: s.isExpired(e)}, nil
+}
+
+func (s *FileStore) Set(key string, r CachedResponse) error {
+	now := s.now()
+	b, err := json.Marshal(cacheEntry{Key: key, Created: now, Expires: now.Add(s.ttl), ETag: r.ETag, LastModified: r.LastModified, Value: r.Body})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("could not create cache directory: %s", err)
+	}
+
+	unlock, err := s.lock()
 	if err != nil {
-		return "", fmt.Errorf("could not read cache file: %s", err)
+		return err
 	}
+	defer unlock()
 
-	// Find the line that starts with the key.
-	i := bytes.Index(b, []byte(key))
-	// Find the end of the line.
-	n := bytes.Index(b[i:], []byte("\n"))
-	// Extract the value.
-	value := string(b[i+len(key)+2 : i+n])
+	// Write to a temporary file first, and rename it into place so the entry is replaced atomically
+	tmp, err := os.CreateTemp(s.dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("could not create cache file: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write to cache file: %s", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write to cache file: %s", err)
+	}
 
-	return value, nil
+	if err := os.Rename(tmp.Name(), s.filename(key)); err != nil {
+		return fmt.Errorf("could not write to cache file: %s", err)
+	}
+	_ = os.Chtimes(s.filename(key), now, now)
+
+	return s.evict()
 }
 
-func (c *tmpFileCacher) Set(key string, value string) error {
-	// Create the file if it doesn't exist.
-	f, err := os.OpenFile(c.filename(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+// Delete removes the entry for the key, if there is one.
+func (s *FileStore) Delete(key string) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("could not create cache directory: %s", err)
+	}
+
+	unlock, err := s.lock()
 	if err != nil {
-		return fmt.Errorf("could not open cache file: %s", err)
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(s.filename(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not remove cache file: %s", err)
 	}
-	defer f.Close()
 
-	// Append the key/value pair to the file.
-	_, err = f.WriteString(fmt.Sprintf("%s: %s\n", key, value))
+	return nil
+}
+
+// read returns the entry for the key. Expired entries are removed, unless they can be revalidated.
+func (s *FileStore) read(key string) (cacheEntry, error) {
+	var e cacheEntry
+
+	b, err := os.ReadFile(s.filename(key))
 	if err != nil {
-		return fmt.Errorf("could not write to cache file: %s", err)
+		return e, ErrCacheMiss
+	}
+
+	// A corrupt entry, or (very unlikely) a different key with the same hash, is treated as a miss
+	if err := json.Unmarshal(b, &e); err != nil || e.Key != key {
+		return e, ErrCacheMiss
+	}
+
+	if s.isExpired(e) && e.ETag == "" && e.LastModified == "" {
+		_ = os.Remove(s.filename(key))
+		return e, ErrCacheMiss
+	}
+
+	return e, nil
+}
+
+// isExpired returns true when the entry has expired. An entry is also treated as expired when it is older than the
+// store's ttl, so a shorter ttl applies to entries that were cached with a longer one.
+func (s *FileStore) isExpired(e cacheEntry) bool {
+	now := s.now()
+	return !now.Before(e.Expires) || now.Sub(e.Created) >= s.ttl
+}
+
+// CacheStats describe the contents of the cache.
+type CacheStats struct {
+	Dir     string
+	Entries int
+	Expired int
+	// Size is the total size of the entries, in bytes.
+	Size int64
+	// Hosts is the number of entries for each host.
+	Hosts  map[string]int
+	Oldest time.Time
+	Newest time.Time
+}
+
+// Stats reads every entry in the cache and summarizes them.
+func (s *FileStore) Stats() (CacheStats, error) {
+	stats := CacheStats{Dir: s.dir, Hosts: make(map[string]int)}
+
+	err := s.walk(func(name string, info os.FileInfo, e cacheEntry) error {
+		stats.Entries++
+		stats.Size += info.Size()
+		stats.Hosts[entryHost(e)]++
+		if s.isExpired(e) {
+			stats.Expired++
+		}
+		if stats.Oldest.IsZero() || e.Created.Before(stats.Oldest) {
+			stats.Oldest = e.Created
+		}
+		if e.Created.After(stats.Newest) {
+			stats.Newest = e.Created
+		}
+		return nil
+	})
+
+	return stats, err
+}
+
+// Clear removes every entry for the given host, or all entries when host is empty. It returns the number of
+// entries removed.
+func (s *FileStore) Clear(host string) (int, error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return 0, fmt.Errorf("could not create cache directory: %s", err)
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	removed := 0
+	err = s.walk(func(name string, info os.FileInfo, e cacheEntry) error {
+		if host != "" && !strings.EqualFold(entryHost(e), host) {
+			return nil
+		}
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("could not remove cache file: %s", err)
+		}
+		removed++
+		return nil
+	})
+
+	return removed, err
+}
+
+// walk calls f for every entry in the cache. Entries that can't be read are passed to f with an empty cacheEntry.
+func (s *FileStore) walk(f func(name string, info os.FileInfo, e cacheEntry) error) error {
+	files, err := s.entries()
+	if err != nil {
+		return err
+	}
+
+	for _, info := range files {
+		name := filepath.Join(s.dir, info.Name())
+
+		if err := f(name, info, s.readFile(name)); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (c *tmpFileCacher) filename() string {
-	return filepath.Join(os.TempDir(), CacheFilename)
+// readFile returns the entry in the file, or an empty cacheEntry when it can't be read.
+func (s *FileStore) readFile(name string) cacheEntry {
+	var e cacheEntry
+	if b, err := os.ReadFile(name); err == nil {
+		_ = json.Unmarshal(b, &e)
+	}
+	return e
+}
+
+// entryHost returns the host of the url an entry was cached for.
+func entryHost(e cacheEntry) string {
+	return hostOf(e.Key)
+}
+
+// evict removes the least recently used entries until the cache fits within its maximum size. Only the file info
+// of the entries is needed while the cache fits, so they are only read once it has grown too big, to remove the
+// expired entries that can't be revalidated before any others. It must be called while holding the lock.
+func (s *FileStore) evict() error {
+	files, err := s.entries()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, f := range files {
+		size += f.Size()
+	}
+	if size <= s.maxSize {
+		return nil
+	}
+
+	var kept []os.FileInfo
+	for _, f := range files {
+		e := s.readFile(filepath.Join(s.dir, f.Name()))
+		if s.isExpired(e) && e.ETag == "" && e.LastModified == "" {
+			if err := s.remove(f); err != nil {
+				return err
+			}
+			size -= f.Size()
+			continue
+		}
+		kept = append(kept, f)
+	}
+
+	// Oldest first
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].ModTime().Before(kept[j].ModTime())
+	})
+
+	for _, f := range kept {
+		if size <= s.maxSize {
+			break
+		}
+		if err := s.remove(f); err != nil {
+			return err
+		}
+		size -= f.Size()
+	}
+
+	return nil
 }
 
-func (c *tmpFileCacher) isExpired() bool {
-	// If the file doesn't exist, it's not expired.
-	if _, err := os.Stat(c.filename()); os.IsNotExist(err) {
-		return false
+// remove removes an entry that is being evicted.
+func (s *FileStore) remove(f os.FileInfo) error {
+	if err := os.Remove(filepath.Join(s.dir, f.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not evict cache file: %s", err)
 	}
+	return nil
+}
 
-	// If the file is older than the cache lifetime, it's expired.
-	fi, err := os.Stat(c.filename())
+// entries returns the file info of every entry in the cache.
+func (s *FileStore) entries() ([]os.FileInfo, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
 	if err != nil {
-		return true
+		return nil, fmt.Errorf("could not read cache directory: %s", err)
 	}
-	if time.Since(fi.ModTime()) > CacheLifetime {
-		return true
+
+	var files []os.FileInfo
+	for _, d := range dirEntries {
+		if d.IsDir() || !strings.HasSuffix(d.Name(), entryExt) {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			// The entry was removed by another process
+			continue
+		}
+		files = append(files, info)
 	}
 
-	// Otherwise, it's not expired.
-	return false
+	return files, nil
+}
+
+// lock creates the lock file, waiting for any other process holding it. A lock file older than lockTimeout is
+// assumed to have been left behind by a process that exited without removing it, and is taken over.
+func (s *FileStore) lock() (func(), error) {
+	name := filepath.Join(s.dir, lockFilename)
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("%w: %s", ErrCouldNotLockCache, err)
+		}
+
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > lockTimeout {
+			os.Remove(name)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s is held by another process", ErrCouldNotLockCache, name)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
 }
 
-func (c *tmpFileCacher) delete() error {
-	return os.Remove(c.filename())
+func (s *FileStore) filename(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+entryExt)
 }
@@ -0,0 +1,230 @@
+package http
+
+import (
+	"fmt"
+	"gotest.tools/v3/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Run("it should get values that were set", func(t *testing.T) {
+		s := NewFileStore(t.TempDir(), time.Hour, CacheMaxSize)
+
+		assert.NilError(t, s.Set("https://example.com/v1/apps", CachedResponse{Body: "apps"}))
+		assert.NilError(t, s.Set("https://example.com/v1/servers", CachedResponse{Body: "servers\nwith a newline"}))
+
+		got, err := s.Get("https://example.com/v1/servers")
+		assert.NilError(t, err)
+		assert.Equal(t, got.Body, "servers\nwith a newline")
+		assert.Equal(t, s.Has("https://example.com/v1/apps"), true)
+	})
+
+	t.Run("it should not match a key that is only part of another key or value", func(t *testing.T) {
+		s := NewFileStore(t.TempDir(), time.Hour, CacheMaxSize)
+
+		assert.NilError(t, s.Set("https://example.com/v1/apps/1", CachedResponse{Body: "https://example.com/v1/servers"}))
+
+		assert.Equal(t, s.Has("https://example.com/v1/apps"), false)
+		assert.Equal(t, s.Has("https://example.com/v1/servers"), false)
+		_, err := s.Get("https://example.com/v1/apps")
+		assert.ErrorIs(t, err, ErrCacheMiss)
+	})
+
+	t.Run("it should expire each entry separately", func(t *testing.T) {
+		now := time.Now()
+		s := NewFileStore(t.TempDir(), time.Hour, CacheMaxSize)
+		s.now = func() time.Time { return now }
+
+		assert.NilError(t, s.Set("old", CachedResponse{Body: "value"}))
+		now = now.Add(30 * time.Minute)
+		assert.NilError(t, s.Set("new", CachedResponse{Body: "value"}))
+		now = now.Add(45 * time.Minute)
+
+		assert.Equal(t, s.Has("old"), false)
+		assert.Equal(t, s.Has("new"), true)
+		// Expired entries are removed
+		_, err := os.Stat(s.filename("old"))
+		assert.Assert(t, os.IsNotExist(err))
+	})
+
+	t.Run("it should evict the least recently used entries when it is too big", func(t *testing.T) {
+		now := time.Now()
+		dir := t.TempDir()
+		s := NewFileStore(dir, time.Hour, CacheMaxSize)
+		s.now = func() time.Time { return now }
+		value := strings.Repeat("x", 1000)
+
+		for _, key := range []string{"a", "b", "c"} {
+			assert.NilError(t, s.Set(key, CachedResponse{Body: value}))
+			now = now.Add(time.Minute)
+		}
+
+		// Reading "a" makes "b" the least recently used
+		_, err := s.Get("a")
+		assert.NilError(t, err)
+
+		info, _ := os.Stat(s.filename("a"))
+		s.maxSize = 3 * info.Size()
+		assert.NilError(t, s.Set("d", CachedResponse{Body: value}))
+
+		assert.Equal(t, s.Has("a"), true)
+		assert.Equal(t, s.Has("b"), false)
+		assert.Equal(t, s.Has("c"), true)
+		assert.Equal(t, s.Has("d"), true)
+	})
+
+	t.Run("it should remove expired entries that can't be revalidated first when it is too big", func(t *testing.T) {
+		now := time.Now()
+		s := NewFileStore(t.TempDir(), time.Hour, CacheMaxSize)
+		s.now = func() time.Time { return now }
+		value := strings.Repeat("x", 1000)
+		assert.NilError(t, s.Set("revalidatable", CachedResponse{Body: value, ETag: `"v1"`}))
+		now = now.Add(time.Minute)
+		assert.NilError(t, s.Set("expired", CachedResponse{Body: value}))
+
+		// The revalidatable entry is the least recently used, so it would be evicted if the expired one weren't removed
+		expired, _ := os.Stat(s.filename("expired"))
+		revalidatable, _ := os.Stat(s.filename("revalidatable"))
+		s.maxSize = expired.Size() + revalidatable.Size()
+		now = now.Add(2 * time.Hour)
+		assert.NilError(t, s.Set("new", CachedResponse{Body: value}))
+
+		_, err := os.Stat(s.filename("expired"))
+		assert.Assert(t, os.IsNotExist(err))
+		_, err = os.Stat(s.filename("revalidatable"))
+		assert.NilError(t, err)
+		_, err = os.Stat(s.filename("new"))
+		assert.NilError(t, err)
+	})
+
+	t.Run("it should not remove expired entries while it fits within its maximum size", func(t *testing.T) {
+		now := time.Now()
+		s := NewFileStore(t.TempDir(), time.Hour, CacheMaxSize)
+		s.now = func() time.Time { return now }
+		assert.NilError(t, s.Set("expired", CachedResponse{Body: "value"}))
+
+		now = now.Add(2 * time.Hour)
+		assert.NilError(t, s.Set("new", CachedResponse{Body: "value"}))
+
+		_, err := os.Stat(s.filename("expired"))
+		assert.NilError(t, err)
+	})
+
+	t.Run("it should allow concurrent writes", func(t *testing.T) {
+		dir := t.TempDir()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				// Each goroutine uses its own store, like separate runs of the tool would
+				s := NewFileStore(dir, time.Hour, CacheMaxSize)
+				assert.Check(t, s.Set(fmt.Sprintf("key-%d", i), CachedResponse{Body: "value"}))
+			}(i)
+		}
+		wg.Wait()
+
+		s := NewFileStore(dir, time.Hour, CacheMaxSize)
+		for i := 0; i < 20; i++ {
+			assert.Equal(t, s.Has(fmt.Sprintf("key-%d", i)), true)
+		}
+		_, err := os.Stat(filepath.Join(dir, lockFilename))
+		assert.Assert(t, os.IsNotExist(err))
+	})
+
+	t.Run("it should take over a stale lock", func(t *testing.T) {
+		dir := t.TempDir()
+		lock := filepath.Join(dir, lockFilename)
+		os.WriteFile(lock, nil, 0600)
+		old := time.Now().Add(-2 * lockTimeout)
+		os.Chtimes(lock, old, old)
+
+		s := NewFileStore(dir, time.Hour, CacheMaxSize)
+
+		assert.NilError(t, s.Set("key", CachedResponse{Body: "value"}))
+	})
+
+	t.Run("it should treat entries older than the ttl as expired", func(t *testing.T) {
+		now := time.Now()
+		dir := t.TempDir()
+		s := NewFileStore(dir, 24*time.Hour, CacheMaxSize)
+		s.now = func() time.Time { return now }
+		assert.NilError(t, s.Set("key", CachedResponse{Body: "value"}))
+
+		now = now.Add(2 * time.Hour)
+		shorter := NewFileStore(dir, time.Hour, CacheMaxSize)
+		shorter.now = s.now
+
+		assert.Equal(t, shorter.Has("key"), false)
+	})
+
+	t.Run("it should summarize the entries", func(t *testing.T) {
+		now := time.Now()
+		s := NewFileStore(t.TempDir(), time.Hour, CacheMaxSize)
+		s.now = func() time.Time { return now }
+
+		assert.NilError(t, s.Set("https://api.serverpilot.io/v1/apps#abc", CachedResponse{Body: "apps"}))
+		assert.NilError(t, s.Set("https://api.serverpilot.io/v1/servers#abc", CachedResponse{Body: "servers"}))
+		now = now.Add(45 * time.Minute)
+		assert.NilError(t, s.Set("https://api.cloudflare.com/client/v4/zones", CachedResponse{Body: "zones"}))
+		now = now.Add(30 * time.Minute)
+
+		stats, err := s.Stats()
+
+		assert.NilError(t, err)
+		assert.Equal(t, stats.Entries, 3)
+		assert.Equal(t, stats.Expired, 2)
+		assert.DeepEqual(t, stats.Hosts, map[string]int{"api.serverpilot.io": 2, "api.cloudflare.com": 1})
+		assert.Assert(t, stats.Size > 0)
+		assert.Assert(t, stats.Oldest.Before(stats.Newest))
+	})
+
+	t.Run("it should clear the entries for a host", func(t *testing.T) {
+		s := NewFileStore(t.TempDir(), time.Hour, CacheMaxSize)
+		assert.NilError(t, s.Set("https://api.serverpilot.io/v1/apps", CachedResponse{Body: "apps"}))
+		assert.NilError(t, s.Set("https://api.cloudflare.com/client/v4/zones", CachedResponse{Body: "zones"}))
+
+		removed, err := s.Clear("api.cloudflare.com")
+
+		assert.NilError(t, err)
+		assert.Equal(t, removed, 1)
+		assert.Equal(t, s.Has("https://api.serverpilot.io/v1/apps"), true)
+		assert.Equal(t, s.Has("https://api.cloudflare.com/client/v4/zones"), false)
+
+		removed, err = s.Clear("")
+
+		assert.NilError(t, err)
+		assert.Equal(t, removed, 1)
+		assert.Equal(t, s.Has("https://api.serverpilot.io/v1/apps"), false)
+	})
+
+	t.Run("it should delete an entry", func(t *testing.T) {
+		s := NewFileStore(t.TempDir(), time.Hour, CacheMaxSize)
+		assert.NilError(t, s.Set("https://example.com/v1/apps", CachedResponse{Body: "apps"}))
+
+		assert.NilError(t, s.Delete("https://example.com/v1/apps"))
+		assert.NilError(t, s.Delete("https://example.com/v1/servers"))
+
+		assert.Equal(t, s.Has("https://example.com/v1/apps"), false)
+	})
+
+	t.Run("it should keep expired entries that can be revalidated", func(t *testing.T) {
+		now := time.Now()
+		s := NewFileStore(t.TempDir(), time.Hour, CacheMaxSize)
+		s.now = func() time.Time { return now }
+		assert.NilError(t, s.Set("key", CachedResponse{Body: "value", ETag: `"v1"`}))
+
+		now = now.Add(2 * time.Hour)
+		got, err := s.Get("key")
+
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, CachedResponse{Body: "value", ETag: `"v1"`, Stale: true})
+		assert.Equal(t, s.Has("key"), false)
+	})
+}
@@ -1,10 +1,14 @@
 package http
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -14,91 +18,339 @@ var (
 	ErrCouldNotCache       = fmt.Errorf("could not cache response")
 )
 
+// StatusError is returned when a request completes with a status other than 2xx or 304. The Body is kept so callers
+// can parse the error message sent by the API.
+type StatusError struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Url, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
 // CachingRateLimitedClient is an interface for making HTTP requests, caching the response, and rate limiting the requests.
 type CachingRateLimitedClient interface {
-	GetFromCacheOrFetchWithRateLimit(req Request) (string, error)
+	GetFromCacheOrFetchWithRateLimit(ctx context.Context, req Request) (string, error)
+}
+
+// RateLimitedClient is an interface for making HTTP requests that should never be cached, such as requests that modify data.
+type RateLimitedClient interface {
+	FetchWithRateLimit(ctx context.Context, req Request) (string, error)
+}
+
+// CacheInvalidator is an interface for removing cached responses that are known to be out of date, such as after
+// a request that modified data.
+type CacheInvalidator interface {
+	Invalidate(req Request)
 }
 
 // Request is a struct that represents an HTTP request. It contains the URL and any headers that should be added to the request.
+// The Method defaults to GET when empty.
 type Request struct {
 	Url     string
 	Headers map[string]string
+	Method  string
 }
 
 // Client is a struct that implements the CachingRateLimitedClient interface. It will use the net.Http package to make HTTP requests.
 type Client struct {
 	*log.Logger
-	s              sleeper
-	c              cacher
-	f              FetchForString
-	hasMadeRequest bool
+	l      limiter
+	c      cacher
+	f      Fetcher
+	mode   CacheMode
+	retry  RetrySettings
+	s      sleeper
+	random func() float64
+}
+
+// CacheMode controls whether responses are read from and written to the cache.
+type CacheMode int
+
+const (
+	// CacheOn reads responses from the cache, and caches new responses.
+	CacheOn CacheMode = iota
+	// CacheRefresh always makes the request, and caches the new response.
+	CacheRefresh
+	// CacheOff always makes the request, and never caches the response.
+	CacheOff
+)
+
+// CacheSettings configure how responses are cached. The zero value uses the default cache directory, lifetime and size.
+type CacheSettings struct {
+	Mode    CacheMode
+	Dir     string
+	TTL     time.Duration
+	MaxSize int64
+}
+
+// Store returns the FileStore described by the settings.
+func (s CacheSettings) Store() *FileStore {
+	dir, ttl, maxSize := s.Dir, s.TTL, s.MaxSize
+	if dir == "" {
+		dir = DefaultCacheDir()
+	}
+	if ttl == 0 {
+		ttl = CacheLifetime
+	}
+	if maxSize == 0 {
+		maxSize = CacheMaxSize
+	}
+	return NewFileStore(dir, ttl, maxSize)
 }
 
-// NewClient returns a new Client, configured with objects to make HTTP requests, cache responses, and rate limit requests.
-func NewClient(l *log.Logger) *Client {
+// NewClient returns a new Client, configured with objects to make HTTP requests, cache responses, rate limit requests
+// and retry failed requests.
+func NewClient(l *log.Logger, settings ClientSettings) *Client {
 	return &Client{
 		Logger: l,
+		l:      defaultLimiter,
+		c:      settings.Cache.Store(),
+		f:      makeHttpFetcher(settings.Transport),
+		mode:   settings.Cache.Mode,
+		retry:  settings.Retry,
 		s:      &defaultSleeper{},
-		c:      &tmpFileCacher{},
-		f:      makeHttpFetcher(),
+		random: defaultRandom,
 	}
 }
 
 // GetFromCacheOrFetchWithRateLimit will check if we have a cached response for the given url. If we do, it will return the cached response.
 // If we don't, it will make an HTTP request to the given url, cache the response, and return the response. When making additional requests,
 // it will sleep for the configured duration to rate limit the requests.
-func (c *Client) GetFromCacheOrFetchWithRateLimit(req Request) (string, error) {
-	// Check if we have a cached response for this url.
-	if c.c.Has(req.Url) {
+func (c *Client) GetFromCacheOrFetchWithRateLimit(ctx context.Context, req Request) (string, error) {
+	if c.mode == CacheOff {
+		return c.FetchWithRateLimit(ctx, req)
+	}
+
+	key := CacheKey(req)
+
+	// Check if we have a cached response for this url and account.
+	cached, err := c.c.Get(key)
+	hasCached := err == nil
+	if c.mode == CacheOn && hasCached && !cached.Stale {
 		c.Println("cache hit")
-		return c.c.Get(req.Url)
+		return cached.Body, nil
 	}
 
-	// If this is not the first request, sleep for the configured duration.
-	if c.hasMadeRequest {
-		c.s.Sleep()
+	// When we have a cached response, only ask for the body again if it has changed
+	if hasCached {
+		req = withValidators(req, cached)
 	}
 
-	c.Println("Making http request to", req.Url)
-	resp, err := c.f(req)
+	resp, err := c.fetch(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("%w: %s", ErrCouldNotMakeRequest, err)
+		return "", err
+	}
+
+	entry := CachedResponse{
+		Body:         resp.Body,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
 	}
 
-	c.hasMadeRequest = true
+	// The cached response hasn't changed, so keep using it (and its validators, unless the server sent new ones)
+	if resp.StatusCode == http.StatusNotModified && hasCached {
+		c.Println("cache revalidated")
+		entry.Body = cached.Body
+		if entry.ETag == "" {
+			entry.ETag = cached.ETag
+		}
+		if entry.LastModified == "" {
+			entry.LastModified = cached.LastModified
+		}
+	}
 
-	// Cache the response.
-	err = c.c.Set(req.Url, resp)
+	// Cache the response, or refresh the cached one.
+	err = c.c.Set(key, entry)
 	if err != nil {
 		return "", fmt.Errorf("%w: %s", ErrCouldNotCache, err)
 	}
 
-	return resp, nil
+	return entry.Body, nil
+}
+
+// withValidators returns a copy of the request that is conditional on the cached response having changed.
+func withValidators(req Request, cached CachedResponse) Request {
+	if cached.ETag == "" && cached.LastModified == "" {
+		return req
+	}
+
+	headers := make(map[string]string, len(req.Headers)+2)
+	for k, v := range req.Headers {
+		headers[k] = v
+	}
+	if cached.ETag != "" {
+		headers["If-None-Match"] = cached.ETag
+	}
+	if cached.LastModified != "" {
+		headers["If-Modified-Since"] = cached.LastModified
+	}
+	req.Headers = headers
+
+	return req
+}
+
+// Invalidate removes the cached response to the request, so the next request for it is fetched again. A response
+// that can't be removed is logged, since it will only be out of date until it expires.
+func (c *Client) Invalidate(req Request) {
+	if err := c.c.Delete(CacheKey(req)); err != nil {
+		c.Printf("Could not remove the cached response for %s: %s\n", req.Url, err)
+	}
+}
+
+// FetchWithRateLimit will make an HTTP request to the given url without checking or updating the cache. Requests to
+// each host are rate limited, waiting for the rate limit when needed.
+func (c *Client) FetchWithRateLimit(ctx context.Context, req Request) (string, error) {
+	resp, err := c.fetch(ctx, req)
+	return resp.Body, err
+}
+
+func (c *Client) fetch(ctx context.Context, req Request) (Response, error) {
+	host := hostOf(req.Url)
+	// Whether an earlier attempt may have been carried out by the host even though it failed, such as when the
+	// connection dropped before the response arrived
+	mayHaveSucceeded := false
+
+	for retry := 0; ; retry++ {
+		remaining, err := c.l.Wait(ctx, host)
+		if err != nil {
+			return Response{}, fmt.Errorf("%w: %w", ErrCouldNotMakeRequest, err)
+		}
+
+		// This is our own token bucket for the host, not a quota reported by the API
+		c.Printf("Making http request to %s (%d requests left in the local rate limiter for %s)\n", req.Url, remaining, host)
+		resp, err := c.f(ctx, req)
+
+		// A DELETE that is retried after it may have succeeded can find the resource already gone
+		if err == nil && resp.StatusCode == http.StatusNotFound && mayHaveSucceeded && req.Method == http.MethodDelete {
+			c.Printf("%s was already deleted by an earlier attempt\n", req.Url)
+			return Response{StatusCode: http.StatusNoContent}, nil
+		}
+
+		// There is no point retrying once the context is cancelled
+		if retry >= c.retry.MaxRetries || ctx.Err() != nil || (err == nil && !isRetryable(resp.StatusCode)) {
+			if err != nil {
+				return Response{}, fmt.Errorf("%w: %w", ErrCouldNotMakeRequest, err)
+			}
+			// Never return (or cache) an error response as if it were the result
+			if !isSuccessful(resp.StatusCode) {
+				return Response{}, newStatusError(req, resp)
+			}
+			return resp, nil
+		}
+
+		// When we've been rate limited, hold off all requests to the host for as long as it asks
+		if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+			if wait, ok := retryAfter(resp.Header, time.Now()); ok {
+				c.Printf("Rate limited by %s, waiting %s\n", host, wait)
+				c.l.Pause(host, wait)
+				continue
+			}
+		}
+
+		if err != nil || resp.StatusCode >= 500 {
+			mayHaveSucceeded = true
+		}
+
+		wait := c.retry.backoff(retry, c.random)
+		if err != nil {
+			c.Printf("Request to %s failed (%s), retrying in %s\n", req.Url, err, wait)
+		} else {
+			c.Printf("Request to %s failed (%d), retrying in %s\n", req.Url, resp.StatusCode, wait)
+		}
+		if err := c.s.Sleep(ctx, wait); err != nil {
+			return Response{}, fmt.Errorf("%w: %w", ErrCouldNotMakeRequest, err)
+		}
+	}
+}
+
+func isSuccessful(status int) bool {
+	return (status >= 200 && status < 300) || status == http.StatusNotModified
+}
+
+func newStatusError(req Request, resp Response) *StatusError {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	return &StatusError{Method: method, Url: req.Url, StatusCode: resp.StatusCode, Body: resp.Body}
+}
+
+// authHeaders are the headers that identify the account a request is made for.
+var authHeaders = []string{"Authorization", "X-Auth-Email", "X-Auth-Key"}
+
+// CacheKey returns the key the response to a request is cached under. It is the url along with a hash of the auth
+// headers, so a response fetched for one account is never served to another.
+func CacheKey(req Request) string {
+	h := sha256.New()
+	found := false
+
+	for _, name := range authHeaders {
+		for k, v := range req.Headers {
+			if http.CanonicalHeaderKey(k) == name {
+				fmt.Fprintf(h, "%s: %s\n", name, v)
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		return req.Url
+	}
+
+	return req.Url + "#" + hex.EncodeToString(h.Sum(nil))
 }
 
-type sleeper interface {
-	Sleep()
+type limiter interface {
+	Wait(ctx context.Context, host string) (int, error)
+	Pause(host string, d time.Duration)
 }
 
-type defaultSleeper struct{}
+func hostOf(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
 
-func (s *defaultSleeper) Sleep() {
-	// Sleep for 200 milliseconds.
-	time.Sleep(200 * time.Millisecond)
+// CachedResponse is a response body stored in the cache, along with the validators used to check if it has changed.
+type CachedResponse struct {
+	Body         string
+	ETag         string
+	LastModified string
+	// Stale is set when the response has expired. It can still be used if the server says it hasn't changed.
+	Stale bool
 }
 
 type cacher interface {
-	Has(key string) bool
-	Get(key string) (string, error)
-	Set(key string, value string) error
+	// Get returns ErrCacheMiss when there is no cached response for the key.
+	Get(key string) (CachedResponse, error)
+	Set(key string, r CachedResponse) error
+	Delete(key string) error
+}
+
+// Response is the result of an HTTP request.
+type Response struct {
+	StatusCode int
+	Body       string
+	Header     http.Header
 }
 
-// FetchForString will use the net.Http package to make an HTTP request.
-type FetchForString func(req Request) (string, error)
+// Fetcher will use the net.Http package to make an HTTP request.
+type Fetcher func(ctx context.Context, req Request) (Response, error)
+
+func convertRequestToHttpRequest(ctx context.Context, req Request) (*http.Request, error) {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
 
-func convertRequestToHttpRequest(req Request) (*http.Request, error) {
 	// Convert our request into an http.Request.
-	r, err := http.NewRequest("GET", req.Url, nil)
+	r, err := http.NewRequestWithContext(ctx, method, req.Url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -111,18 +363,18 @@ func convertRequestToHttpRequest(req Request) (*http.Request, error) {
 	return r, nil
 }
 
-func makeHttpFetcher() FetchForString {
-	return func(req Request) (string, error) {
-		r, err := convertRequestToHttpRequest(req)
+func makeHttpFetcher(transport http.RoundTripper) Fetcher {
+	return func(ctx context.Context, req Request) (Response, error) {
+		r, err := convertRequestToHttpRequest(ctx, req)
 		if err != nil {
-			return "", err
+			return Response{}, err
 		}
 
 		// Make the request.
-		client := &http.Client{Timeout: 10 * time.Second}
+		client := &http.Client{Timeout: 10 * time.Second, Transport: transport}
 		resp, err := client.Do(r)
 		if err != nil {
-			return "", err
+			return Response{}, err
 		}
 		defer resp.Body.Close()
 
@@ -130,6 +382,6 @@ func makeHttpFetcher() FetchForString {
 		buf := new(strings.Builder)
 		_, err = io.Copy(buf, resp.Body)
 
-		return buf.String(), err
+		return Response{StatusCode: resp.StatusCode, Body: buf.String(), Header: resp.Header}, err
 	}
 }
@@ -1,11 +1,16 @@
 package http
 
 import (
+	"context"
 	"errors"
 	"gotest.tools/v3/assert"
 	"io"
 	"log"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestClient(t *testing.T) {
@@ -20,14 +25,12 @@ func TestClient(t *testing.T) {
 		}
 
 		for _, tt := range tests {
-			sleeper := &SpySleeper{}
-
 			client := newClientWithStubs()
-			client.s = sleeper
+			sleeper := client.l.(*Limiter).sleep.(*SpySleeper)
 
 			// Make the desired number of Get calls.
 			for i := 0; i < tt.makeGetCalls; i++ {
-				client.GetFromCacheOrFetchWithRateLimit(Request{Url: "https://example.com"})
+				client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://example.com"})
 			}
 
 			// Assert that we got the expected number of sleep calls.
@@ -36,13 +39,41 @@ func TestClient(t *testing.T) {
 	})
 
 	t.Run("it should not sleep when making 2 requests to 2 different APIs", func(t *testing.T) {
+		client := newClientWithStubs()
+		sleeper := client.l.(*Limiter).sleep.(*SpySleeper)
+
+		client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://api.serverpilot.io/v1/apps"})
+		client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://api.cloudflare.com/client/v4/zones"})
+
+		assert.Equal(t, sleeper.calls, 0)
+	})
+
+	t.Run("it should wait for the Retry-After when rate limited", func(t *testing.T) {
+		client := newClientWithStubs()
+		client.retry = DefaultRetrySettings
+		sleeper := client.l.(*Limiter).sleep.(*SpySleeper)
+		calls := 0
+		client.f = func(ctx context.Context, req Request) (Response, error) {
+			calls++
+			if calls == 1 {
+				return Response{StatusCode: 429, Header: http.Header{"Retry-After": []string{"3"}}}, nil
+			}
+			return Response{StatusCode: 200, Body: "response"}, nil
+		}
+
+		got, err := client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://api.cloudflare.com/client/v4/zones"})
+
+		assert.NilError(t, err)
+		assert.Equal(t, got, "response")
+		assert.Equal(t, calls, 2)
+		assert.Assert(t, sleeper.slept >= 3*time.Second)
 	})
 
 	t.Run("it should return an error that occurs while fetching", func(t *testing.T) {
 		client := newClientWithStubs()
 		client.f = stubFetcher(errors.New("some http error"))
 
-		_, err := client.GetFromCacheOrFetchWithRateLimit(Request{Url: "https://example.com"})
+		_, err := client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://example.com"})
 
 		assert.ErrorIs(t, err, ErrCouldNotMakeRequest)
 	})
@@ -62,14 +93,14 @@ func TestClient(t *testing.T) {
 
 			client := newClientWithStubs()
 			client.c = &InMemoryCacher{}
-			client.f = func(req Request) (string, error) {
+			client.f = func(ctx context.Context, req Request) (Response, error) {
 				spyCalls++
-				return "response", nil
+				return Response{StatusCode: 200, Body: "response"}, nil
 			}
 
 			// Make the desired number of Get calls.
 			for i := 0; i < tt.makeGetCalls; i++ {
-				resp, _ := client.GetFromCacheOrFetchWithRateLimit(Request{Url: "https://example.com"})
+				resp, _ := client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://example.com"})
 
 				// Assert that we got the expected response each time
 				assert.Equal(t, resp, "response", tt.name)
@@ -80,74 +111,341 @@ func TestClient(t *testing.T) {
 		}
 	})
 
+	t.Run("it should cache responses separately for each account", func(t *testing.T) {
+		spyCalls := 0
+		client := newClientWithStubs()
+		client.c = &InMemoryCacher{}
+		client.f = func(ctx context.Context, req Request) (Response, error) {
+			spyCalls++
+			return Response{StatusCode: 200, Body: req.Headers["Authorization"]}, nil
+		}
+
+		first, _ := client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://example.com", Headers: map[string]string{"Authorization": "account1"}})
+		second, _ := client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://example.com", Headers: map[string]string{"Authorization": "account2"}})
+		again, _ := client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://example.com", Headers: map[string]string{"Authorization": "account1"}})
+
+		assert.Equal(t, first, "account1")
+		assert.Equal(t, second, "account2")
+		assert.Equal(t, again, "account1")
+		assert.Equal(t, spyCalls, 2)
+	})
+
+	t.Run("it should key the cache on the url and auth headers", func(t *testing.T) {
+		var tests = []struct {
+			name     string
+			a, b     Request
+			wantSame bool
+		}{
+			{
+				"same credentials",
+				Request{Url: "https://example.com", Headers: map[string]string{"X-Auth-Email": "a@example.com", "X-Auth-Key": "key"}},
+				Request{Url: "https://example.com", Headers: map[string]string{"x-auth-email": "a@example.com", "x-auth-key": "key"}},
+				true,
+			},
+			{
+				"different credentials",
+				Request{Url: "https://example.com", Headers: map[string]string{"X-Auth-Email": "a@example.com", "X-Auth-Key": "key"}},
+				Request{Url: "https://example.com", Headers: map[string]string{"X-Auth-Email": "b@example.com", "X-Auth-Key": "key"}},
+				false,
+			},
+			{
+				"other headers are ignored",
+				Request{Url: "https://example.com", Headers: map[string]string{"Authorization": "Basic abc", "Content-Type": "application/json"}},
+				Request{Url: "https://example.com", Headers: map[string]string{"Authorization": "Basic abc"}},
+				true,
+			},
+			{
+				"different urls",
+				Request{Url: "https://example.com/a", Headers: map[string]string{"Authorization": "Basic abc"}},
+				Request{Url: "https://example.com/b", Headers: map[string]string{"Authorization": "Basic abc"}},
+				false,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, CacheKey(tt.a) == CacheKey(tt.b), tt.wantSame)
+			})
+		}
+	})
+
+	t.Run("it should not include the credentials in the cache key", func(t *testing.T) {
+		key := CacheKey(Request{Url: "https://example.com", Headers: map[string]string{"Authorization": "Basic secret"}})
+
+		assert.Assert(t, !strings.Contains(key, "secret"))
+	})
+
+	t.Run("it should respect the cache mode", func(t *testing.T) {
+		var tests = []struct {
+			name         string
+			mode         CacheMode
+			want         string
+			wantApiCalls int
+			wantCached   string
+		}{
+			{"on", CacheOn, "cached", 0, "cached"},
+			{"refresh", CacheRefresh, "fresh", 1, "fresh"},
+			{"off", CacheOff, "fresh", 1, "cached"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				spyCalls := 0
+				cacher := &InMemoryCacher{}
+				cacher.Set("https://example.com", CachedResponse{Body: "cached"})
+				client := newClientWithStubs()
+				client.c = cacher
+				client.mode = tt.mode
+				client.f = func(ctx context.Context, req Request) (Response, error) {
+					spyCalls++
+					return Response{StatusCode: 200, Body: "fresh"}, nil
+				}
+
+				got, err := client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://example.com"})
+
+				assert.NilError(t, err)
+				assert.Equal(t, got, tt.want)
+				assert.Equal(t, spyCalls, tt.wantApiCalls)
+				cached, _ := cacher.Get("https://example.com")
+				assert.Equal(t, cached.Body, tt.wantCached)
+			})
+		}
+	})
+
+	t.Run("it should revalidate stale responses", func(t *testing.T) {
+		var tests = []struct {
+			name       string
+			cached     CachedResponse
+			resp       Response
+			wantHeader map[string]string
+			want       CachedResponse
+		}{
+			{
+				"not modified",
+				CachedResponse{Body: "cached", ETag: `"v1"`, Stale: true},
+				Response{StatusCode: 304, Header: http.Header{}},
+				map[string]string{"If-None-Match": `"v1"`},
+				CachedResponse{Body: "cached", ETag: `"v1"`},
+			},
+			{
+				"modified",
+				CachedResponse{Body: "cached", LastModified: "Mon, 02 Jan 2023 15:04:05 GMT", Stale: true},
+				Response{StatusCode: 200, Body: "fresh", Header: http.Header{"Etag": []string{`"v2"`}}},
+				map[string]string{"If-Modified-Since": "Mon, 02 Jan 2023 15:04:05 GMT"},
+				CachedResponse{Body: "fresh", ETag: `"v2"`},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var gotHeaders map[string]string
+				cacher := &InMemoryCacher{}
+				cacher.Set("https://example.com", tt.cached)
+				client := newClientWithStubs()
+				client.c = cacher
+				client.f = func(ctx context.Context, req Request) (Response, error) {
+					gotHeaders = req.Headers
+					return tt.resp, nil
+				}
+
+				got, err := client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://example.com"})
+
+				assert.NilError(t, err)
+				assert.Equal(t, got, tt.want.Body)
+				assert.DeepEqual(t, gotHeaders, tt.wantHeader)
+				cached, _ := cacher.Get("https://example.com")
+				assert.DeepEqual(t, cached, tt.want)
+			})
+		}
+	})
+
 	t.Run("it should return an error if setting a cache value returns an error", func(t *testing.T) {
 		client := newClientWithStubs()
 		client.c = &InMemoryCacher{setErrStub: errors.New("some cache error")}
 
-		_, err := client.GetFromCacheOrFetchWithRateLimit(Request{Url: "https://example.com"})
+		_, err := client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://example.com"})
 
 		assert.ErrorIs(t, err, ErrCouldNotCache)
 	})
 
+	t.Run("it should not cache error responses", func(t *testing.T) {
+		cacher := &InMemoryCacher{}
+		client := newClientWithStubs()
+		client.c = cacher
+		client.f = func(ctx context.Context, req Request) (Response, error) {
+			return Response{StatusCode: 401, Body: `{"error": {"message": "invalid key"}}`}, nil
+		}
+
+		_, err := client.GetFromCacheOrFetchWithRateLimit(context.Background(), Request{Url: "https://example.com"})
+
+		var statusErr *StatusError
+		assert.Assert(t, errors.As(err, &statusErr))
+		assert.DeepEqual(t, statusErr, &StatusError{Method: "GET", Url: "https://example.com", StatusCode: 401, Body: `{"error": {"message": "invalid key"}}`})
+		assert.Equal(t, cacher.Has("https://example.com"), false)
+	})
+
+	t.Run("it should not cache requests that are fetched directly", func(t *testing.T) {
+		spyCalls := 0
+
+		cacher := &InMemoryCacher{}
+		client := newClientWithStubs()
+		client.c = cacher
+		client.f = func(ctx context.Context, req Request) (Response, error) {
+			spyCalls++
+			return Response{StatusCode: 200, Body: "response"}, nil
+		}
+
+		client.FetchWithRateLimit(context.Background(), Request{Url: "https://example.com", Method: "DELETE"})
+		client.FetchWithRateLimit(context.Background(), Request{Url: "https://example.com", Method: "DELETE"})
+
+		assert.Equal(t, spyCalls, 2)
+		assert.Equal(t, cacher.Has("https://example.com"), false)
+	})
+
+	t.Run("it should convert the request method", func(t *testing.T) {
+		var tests = []struct {
+			name   string
+			method string
+			want   string
+		}{
+			{"default", "", "GET"},
+			{"delete", "DELETE", "DELETE"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				r, err := convertRequestToHttpRequest(context.Background(), Request{Url: "https://example.com", Method: tt.method})
+
+				assert.NilError(t, err)
+				assert.Equal(t, r.Method, tt.want)
+			})
+		}
+	})
+
+	t.Run("it should not retry once the context is cancelled", func(t *testing.T) {
+		calls := 0
+		ctx, cancel := context.WithCancel(context.Background())
+		client := newClientWithStubs()
+		client.retry = DefaultRetrySettings
+		client.f = func(ctx context.Context, req Request) (Response, error) {
+			calls++
+			cancel()
+			return Response{}, ctx.Err()
+		}
+
+		_, err := client.FetchWithRateLimit(ctx, Request{Url: "https://example.com"})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, calls, 1)
+	})
+
+	t.Run("it should remove the cached response to an invalidated request", func(t *testing.T) {
+		cacher := &InMemoryCacher{}
+		client := newClientWithStubs()
+		client.c = cacher
+		req := Request{Url: "https://example.com/apps", Headers: map[string]string{"Authorization": "Basic abc"}}
+		client.GetFromCacheOrFetchWithRateLimit(context.Background(), req)
+
+		client.Invalidate(req)
+
+		assert.Equal(t, cacher.Has(CacheKey(req)), false)
+	})
+
 	t.Run("it should only cache 200 responses", func(t *testing.T) {
 	})
 }
 
 func newClientWithStubs() *Client {
-	client := NewClient(log.New(io.Discard, "", 0))
-	client.s = &SpySleeper{}
+	client := NewClient(log.New(io.Discard, "", 0), ClientSettings{})
+	limiter := newLimiterWithSpySleeper(DefaultRateLimits)
+	client.l = limiter
+	client.s = limiter.sleep
+	client.random = func() float64 { return 0.5 }
 	client.c = &NeverCacher{}
 	client.f = stubFetcher(nil)
 	return client
 }
 
+// newLimiterWithSpySleeper returns a Limiter whose clock only moves forward when it sleeps.
+func newLimiterWithSpySleeper(limits map[string]RateLimit) *Limiter {
+	sleeper := &SpySleeper{now: time.Now()}
+	l := NewLimiter(limits)
+	l.now = sleeper.Now
+	l.sleep = sleeper
+	return l
+}
+
+// SpySleeper is a fake clock that moves forward by however long it is asked to sleep. It is safe for concurrent use.
 type SpySleeper struct {
+	mu    sync.Mutex
 	calls int
+	slept time.Duration
+	now   time.Time
 }
 
-func (s *SpySleeper) Sleep() {
+func (s *SpySleeper) Sleep(ctx context.Context, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.calls++
+	s.slept += d
+	s.now = s.now.Add(d)
+	return ctx.Err()
+}
+
+func (s *SpySleeper) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.now
 }
 
 type InMemoryCacher struct {
-	cache      map[string]string
+	cache      map[string]CachedResponse
 	setErrStub error
 }
 
 func (c *InMemoryCacher) Has(key string) bool {
-	if _, ok := c.cache[key]; !ok {
-		return false
-	}
-	return true
+	_, ok := c.cache[key]
+	return ok
 }
 
-func (c *InMemoryCacher) Get(key string) (string, error) {
+func (c *InMemoryCacher) Get(key string) (CachedResponse, error) {
 	if _, ok := c.cache[key]; !ok {
-		return "", errors.New("key not found")
+		return CachedResponse{}, ErrCacheMiss
 	}
+
 	return c.cache[key], nil
 }
 
-func (c *InMemoryCacher) Set(key string, value string) error {
+func (c *InMemoryCacher) Set(key string, r CachedResponse) error {
 	// Initialize the cache if it's nil.
 	if c.cache == nil {
-		c.cache = make(map[string]string)
+		c.cache = make(map[string]CachedResponse)
 	}
+
 	if c.setErrStub != nil {
 		return c.setErrStub
 	}
-	c.cache[key] = value
+
+	c.cache[key] = r
+
+	return nil
+}
+
+func (c *InMemoryCacher) Delete(key string) error {
+	delete(c.cache, key)
 	return nil
 }
 
 type NeverCacher struct{}
 
-func (c *NeverCacher) Has(key string) bool                { return false }
-func (c *NeverCacher) Get(key string) (string, error)     { return "", nil }
-func (c *NeverCacher) Set(key string, value string) error { return nil }
+func (c *NeverCacher) Get(key string) (CachedResponse, error) { return CachedResponse{}, ErrCacheMiss }
+func (c *NeverCacher) Set(key string, r CachedResponse) error { return nil }
+func (c *NeverCacher) Delete(key string) error                { return nil }
 
-func stubFetcher(errStub error) FetchForString {
-	return func(req Request) (string, error) {
-		return "response", errStub
+func stubFetcher(errStub error) Fetcher {
+	return func(ctx context.Context, req Request) (Response, error) {
+		return Response{StatusCode: 200, Body: "response"}, errStub
 	}
 }
@@ -0,0 +1,183 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Hosts of the APIs we make requests to.
+const (
+	ServerPilotHost = "api.serverpilot.io"
+	CloudflareHost  = "api.cloudflare.com"
+)
+
+// RateLimit is the number of requests per second that can be made to a host. Up to Burst requests can be made at once
+// before they are spread out at the rate.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// DefaultRateLimit applies to any host without its own rate limit.
+var DefaultRateLimit = RateLimit{Rate: 5, Burst: 1}
+
+// DefaultRateLimits are the rate limits used for each API. ServerPilot doesn't document a limit, so we stay with one
+// request every 200ms. Cloudflare allows 1200 requests per 5 minutes.
+var DefaultRateLimits = map[string]RateLimit{
+	ServerPilotHost: {Rate: 5, Burst: 1},
+	CloudflareHost:  {Rate: 4, Burst: 10},
+}
+
+// Limiter is a token bucket rate limiter, with a bucket for each host. It is safe for concurrent use.
+type Limiter struct {
+	mu      sync.Mutex
+	limits  map[string]RateLimit
+	buckets map[string]*bucket
+	now     func() time.Time
+	sleep   sleeper
+}
+
+type sleeper interface {
+	// Sleep returns early with the context's error when it is cancelled.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type defaultSleeper struct{}
+
+func (s *defaultSleeper) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type bucket struct {
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// NewLimiter returns a Limiter using the given rate limits, and DefaultRateLimit for any other host.
+func NewLimiter(limits map[string]RateLimit) *Limiter {
+	l := &Limiter{
+		limits:  make(map[string]RateLimit),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+		sleep:   &defaultSleeper{},
+	}
+	for host, limit := range limits {
+		l.limits[host] = limit
+	}
+	return l
+}
+
+// defaultLimiter is shared by every Client, so concurrent clients for the same host share its rate limit.
+var defaultLimiter = NewLimiter(DefaultRateLimits)
+
+// SetRateLimit changes the rate limit of a host for every Client.
+func SetRateLimit(host string, limit RateLimit) {
+	defaultLimiter.SetRateLimit(host, limit)
+}
+
+// SetRateLimit changes the rate limit of a host.
+func (l *Limiter) SetRateLimit(host string, limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limits[host] = limit
+	delete(l.buckets, host)
+}
+
+// Wait blocks until a request can be made to the host, and returns the number of requests that can still be made
+// right away. It returns the context's error if the context is cancelled while waiting.
+func (l *Limiter) Wait(ctx context.Context, host string) (int, error) {
+	for {
+		l.mu.Lock()
+		limit := l.limit(host)
+		b := l.bucket(host, limit)
+		now := l.now()
+
+		// Refill the bucket for the time that has passed
+		b.tokens += now.Sub(b.last).Seconds() * limit.Rate
+		if b.tokens > float64(limit.Burst) {
+			b.tokens = float64(limit.Burst)
+		}
+		b.last = now
+
+		var wait time.Duration
+		switch {
+		case now.Before(b.pausedUntil):
+			wait = b.pausedUntil.Sub(now)
+		case b.tokens >= 1:
+			b.tokens--
+			remaining := int(b.tokens)
+			l.mu.Unlock()
+			return remaining, nil
+		default:
+			wait = time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+		}
+		l.mu.Unlock()
+
+		if err := l.sleep.Sleep(ctx, wait); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Pause stops any requests to the host for the given duration, such as when the host responds with a Retry-After.
+func (l *Limiter) Pause(host string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(host, l.limit(host))
+	until := l.now().Add(d)
+	if until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+	// Start again slowly once the pause is over
+	b.tokens = 0
+}
+
+func (l *Limiter) limit(host string) RateLimit {
+	limit, ok := l.limits[host]
+	if !ok || limit.Rate <= 0 {
+		limit = DefaultRateLimit
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return limit
+}
+
+func (l *Limiter) bucket(host string, limit RateLimit) *bucket {
+	b, ok := l.buckets[host]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: l.now()}
+		l.buckets[host] = b
+	}
+	return b
+}
+
+// retryAfter returns how long the Retry-After header says to wait, which is either a number of seconds or a date.
+// It returns false when the header is missing or invalid.
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if t.Before(now) {
+			return 0, true
+		}
+		return t.Sub(now), true
+	}
+	return 0, false
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"context"
+	"gotest.tools/v3/assert"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("it should allow a burst of requests before waiting", func(t *testing.T) {
+		l := newLimiterWithSpySleeper(map[string]RateLimit{"example.com": {Rate: 2, Burst: 3}})
+		sleeper := l.sleep.(*SpySleeper)
+
+		var remaining []int
+		for i := 0; i < 4; i++ {
+			r, err := l.Wait(context.Background(), "example.com")
+			assert.NilError(t, err)
+			remaining = append(remaining, r)
+		}
+
+		assert.DeepEqual(t, remaining, []int{2, 1, 0, 0})
+		assert.Equal(t, sleeper.calls, 1)
+		assert.Equal(t, sleeper.slept, 500*time.Millisecond)
+	})
+
+	t.Run("it should use the default rate limit for other hosts", func(t *testing.T) {
+		l := newLimiterWithSpySleeper(nil)
+		sleeper := l.sleep.(*SpySleeper)
+
+		l.Wait(context.Background(), "example.com")
+		l.Wait(context.Background(), "example.com")
+
+		assert.Equal(t, sleeper.slept, time.Duration(float64(time.Second)/DefaultRateLimit.Rate))
+	})
+
+	t.Run("it should wait until a pause is over", func(t *testing.T) {
+		l := newLimiterWithSpySleeper(map[string]RateLimit{"example.com": {Rate: 10, Burst: 10}})
+		sleeper := l.sleep.(*SpySleeper)
+
+		l.Pause("example.com", 5*time.Second)
+		l.Wait(context.Background(), "example.com")
+
+		assert.Assert(t, sleeper.slept >= 5*time.Second)
+	})
+
+	t.Run("it should be safe for concurrent use", func(t *testing.T) {
+		l := newLimiterWithSpySleeper(map[string]RateLimit{"example.com": {Rate: 1000, Burst: 5}})
+		sleeper := l.sleep.(*SpySleeper)
+
+		start := sleeper.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < 25; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				l.Wait(context.Background(), "example.com")
+			}()
+		}
+		wg.Wait()
+
+		// 5 requests are allowed at once, the other 20 need to wait 1ms each, so no matter how the waits overlap the
+		// clock must have moved forward by at least 20ms
+		assert.Assert(t, sleeper.Now().Sub(start).Round(time.Millisecond) >= 20*time.Millisecond)
+	})
+
+	t.Run("it should stop waiting when the context is cancelled", func(t *testing.T) {
+		l := NewLimiter(map[string]RateLimit{"example.com": {Rate: 1, Burst: 1}})
+		ctx, cancel := context.WithCancel(context.Background())
+		l.Wait(ctx, "example.com")
+		cancel()
+
+		_, err := l.Wait(ctx, "example.com")
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("it should parse the Retry-After header", func(t *testing.T) {
+		now := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+		var tests = []struct {
+			name   string
+			value  string
+			want   time.Duration
+			wantOk bool
+		}{
+			{"seconds", "120", 2 * time.Minute, true},
+			{"date", "Mon, 02 Jan 2023 15:04:35 GMT", 30 * time.Second, true},
+			{"past date", "Mon, 02 Jan 2023 15:00:00 GMT", 0, true},
+			{"missing", "", 0, false},
+			{"invalid", "soon", 0, false},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, ok := retryAfter(http.Header{"Retry-After": []string{tt.value}}, now)
+
+				assert.Equal(t, got, tt.want)
+				assert.Equal(t, ok, tt.wantOk)
+			})
+		}
+	})
+}
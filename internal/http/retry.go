@@ -0,0 +1,63 @@
+package http
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetrySettings configure how failed requests are retried. Network errors, 5xx responses and 429 Too Many Requests
+// are retried, waiting longer after each attempt. Other 4xx responses, such as an invalid API key, are never
+// retried. A DELETE that finds the resource gone when it is retried after a network error or 5xx response is treated
+// as a success, since the earlier attempt may have deleted it. The zero value never retries.
+type RetrySettings struct {
+	MaxRetries int
+	// BaseDelay is the delay before the first retry, which doubles for each retry after that, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetrySettings are used by the CLI and the public packages.
+var DefaultRetrySettings = RetrySettings{MaxRetries: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 10 * time.Second}
+
+// ClientSettings configure a Client. The zero value uses the default cache and transport, and never retries.
+type ClientSettings struct {
+	Cache CacheSettings
+	Retry RetrySettings
+	// Transport makes the requests, such as one that records or replays them. Nil uses http.DefaultTransport.
+	Transport http.RoundTripper
+	// BaseUrl replaces the default base url of the API the client is for, such as a local stand-in API.
+	BaseUrl string
+}
+
+// backoff returns how long to wait before the given retry (starting from 0). It is an exponential backoff with
+// jitter, so that concurrent requests that failed together don't all retry at the same time.
+func (s RetrySettings) backoff(retry int, random func() float64) time.Duration {
+	base, max := s.BaseDelay, s.MaxDelay
+	if base <= 0 {
+		base = DefaultRetrySettings.BaseDelay
+	}
+	if max <= 0 {
+		max = DefaultRetrySettings.MaxDelay
+	}
+
+	d := base
+	for i := 0; i < retry && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	// Wait somewhere between half and all of the delay
+	return d/2 + time.Duration(random()*float64(d/2))
+}
+
+// isRetryable returns true for responses that might succeed if the request is made again.
+func isRetryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+func defaultRandom() float64 {
+	return rand.Float64()
+}
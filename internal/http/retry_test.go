@@ -0,0 +1,171 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"gotest.tools/v3/assert"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	t.Run("it should retry requests that might succeed", func(t *testing.T) {
+		var tests = []struct {
+			name      string
+			responses []Response
+			errs      []error
+			wantCalls int
+			wantErr   error
+			// wantStatus is the status of the StatusError that should be returned
+			wantStatus int
+			want       string
+		}{
+			{"network error", []Response{{}, {StatusCode: 200, Body: "ok"}}, []error{errors.New("connection reset"), nil}, 2, nil, 0, "ok"},
+			{"server error", []Response{{StatusCode: 502}, {StatusCode: 200, Body: "ok"}}, []error{nil, nil}, 2, nil, 0, "ok"},
+			{"rate limited", []Response{{StatusCode: 429}, {StatusCode: 200, Body: "ok"}}, []error{nil, nil}, 2, nil, 0, "ok"},
+			{"unauthorized", []Response{{StatusCode: 401, Body: "invalid key"}}, []error{nil}, 1, nil, 401, ""},
+			{"not found", []Response{{StatusCode: 404, Body: "not found"}}, []error{nil}, 1, nil, 404, ""},
+			{
+				"too many failures",
+				[]Response{{}, {}, {}, {}},
+				[]error{errors.New("timeout"), errors.New("timeout"), errors.New("timeout"), errors.New("timeout")},
+				4,
+				ErrCouldNotMakeRequest,
+				0,
+				"",
+			},
+			{
+				"too many server errors",
+				[]Response{{StatusCode: 500}, {StatusCode: 500}, {StatusCode: 500}, {StatusCode: 500}},
+				[]error{nil, nil, nil, nil},
+				4,
+				nil,
+				500,
+				"",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				calls := 0
+				client := newClientWithStubs()
+				client.retry = DefaultRetrySettings
+				client.f = func(ctx context.Context, req Request) (Response, error) {
+					i := calls
+					calls++
+					return tt.responses[i], tt.errs[i]
+				}
+
+				got, err := client.FetchWithRateLimit(context.Background(), Request{Url: "https://example.com"})
+
+				assert.Equal(t, calls, tt.wantCalls)
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+					return
+				}
+				if tt.wantStatus != 0 {
+					var statusErr *StatusError
+					assert.Assert(t, errors.As(err, &statusErr))
+					assert.Equal(t, statusErr.StatusCode, tt.wantStatus)
+					return
+				}
+				assert.NilError(t, err)
+				assert.Equal(t, got, tt.want)
+			})
+		}
+	})
+
+	t.Run("it should treat a retried DELETE that finds the resource gone as deleted", func(t *testing.T) {
+		var tests = []struct {
+			name       string
+			first      Response
+			firstErr   error
+			wantStatus int
+		}{
+			{"after a network error", Response{}, errors.New("connection reset"), 0},
+			{"after a server error", Response{StatusCode: 502}, nil, 0},
+			// A rate limited request was never carried out, so the resource really is missing
+			{"after being rate limited", Response{StatusCode: 429}, nil, 404},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				calls := 0
+				client := newClientWithStubs()
+				client.retry = DefaultRetrySettings
+				client.f = func(ctx context.Context, req Request) (Response, error) {
+					calls++
+					if calls == 1 {
+						return tt.first, tt.firstErr
+					}
+					return Response{StatusCode: 404, Body: "not found"}, nil
+				}
+
+				_, err := client.FetchWithRateLimit(context.Background(), Request{Url: "https://example.com/apps/1", Method: "DELETE"})
+
+				assert.Equal(t, calls, 2)
+				if tt.wantStatus == 0 {
+					assert.NilError(t, err)
+					return
+				}
+				var statusErr *StatusError
+				assert.Assert(t, errors.As(err, &statusErr))
+				assert.Equal(t, statusErr.StatusCode, tt.wantStatus)
+			})
+		}
+	})
+
+	t.Run("it should not treat a GET that finds nothing when retried as a success", func(t *testing.T) {
+		calls := 0
+		client := newClientWithStubs()
+		client.retry = DefaultRetrySettings
+		client.f = func(ctx context.Context, req Request) (Response, error) {
+			calls++
+			if calls == 1 {
+				return Response{StatusCode: 502}, nil
+			}
+			return Response{StatusCode: 404}, nil
+		}
+
+		_, err := client.FetchWithRateLimit(context.Background(), Request{Url: "https://example.com/apps/1"})
+
+		var statusErr *StatusError
+		assert.Assert(t, errors.As(err, &statusErr))
+		assert.Equal(t, statusErr.StatusCode, 404)
+	})
+
+	t.Run("it should not retry when retries are disabled", func(t *testing.T) {
+		calls := 0
+		client := newClientWithStubs()
+		client.f = func(ctx context.Context, req Request) (Response, error) {
+			calls++
+			return Response{StatusCode: 503}, nil
+		}
+
+		client.FetchWithRateLimit(context.Background(), Request{Url: "https://example.com"})
+
+		assert.Equal(t, calls, 1)
+	})
+
+	t.Run("it should back off exponentially with jitter", func(t *testing.T) {
+		s := RetrySettings{MaxRetries: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+		var tests = []struct {
+			retry    int
+			random   float64
+			min, max time.Duration
+		}{
+			{0, 0, 500 * time.Millisecond, 500 * time.Millisecond},
+			{0, 1, time.Second, time.Second},
+			{1, 0.5, 1500 * time.Millisecond, 1500 * time.Millisecond},
+			{2, 0, 2 * time.Second, 2 * time.Second},
+			{5, 1, 5 * time.Second, 5 * time.Second},
+		}
+
+		for _, tt := range tests {
+			got := s.backoff(tt.retry, func() float64 { return tt.random })
+
+			assert.Assert(t, got >= tt.min && got <= tt.max, "retry %d: %s", tt.retry, got)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+var (
+	ErrInvalidProxy    = errors.New("invalid proxy url")
+	ErrInvalidCABundle = errors.New("invalid CA bundle")
+)
+
+// TransportSettings configure how requests reach the APIs. The zero value behaves like http.DefaultTransport.
+type TransportSettings struct {
+	// Proxy is the url of an HTTP(S) proxy. When empty, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+	// variables are used.
+	Proxy string
+	// CABundle is the path of a PEM file of certificates to trust, in addition to the system's, such as the
+	// certificate of an inspecting proxy.
+	CABundle string
+}
+
+// NewTransport returns a transport that uses the proxy and trusts the CA bundle in the settings.
+func NewTransport(s TransportSettings) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if s.Proxy != "" {
+		u, err := url.Parse(s.Proxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidProxy, s.Proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	if s.CABundle != "" {
+		pool, err := loadCABundle(s.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return t, nil
+}
+
+// loadCABundle returns the system's certificates, along with the ones in the PEM file.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCABundle, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%w: no certificates found in %s", ErrInvalidCABundle, path)
+	}
+
+	return pool, nil
+}
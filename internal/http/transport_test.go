@@ -0,0 +1,58 @@
+package http
+
+import (
+	"encoding/pem"
+	"gotest.tools/v3/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewTransport(t *testing.T) {
+	t.Run("it should trust the certificates in the CA bundle", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+		bundle := filepath.Join(t.TempDir(), "ca.pem")
+		os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+
+		untrusted, err := NewTransport(TransportSettings{})
+		assert.NilError(t, err)
+		_, err = (&http.Client{Transport: untrusted}).Get(server.URL)
+		assert.ErrorContains(t, err, "certificate")
+
+		trusted, err := NewTransport(TransportSettings{CABundle: bundle})
+		assert.NilError(t, err)
+		resp, err := (&http.Client{Transport: trusted}).Get(server.URL)
+		assert.NilError(t, err)
+		assert.Equal(t, resp.StatusCode, 200)
+	})
+
+	t.Run("it should make requests through the proxy", func(t *testing.T) {
+		var proxied string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = r.URL.String()
+		}))
+		defer proxy.Close()
+
+		transport, err := NewTransport(TransportSettings{Proxy: proxy.URL})
+		assert.NilError(t, err)
+		_, err = (&http.Client{Transport: transport}).Get("http://api.serverpilot.io/v1/apps")
+
+		assert.NilError(t, err)
+		assert.Equal(t, proxied, "http://api.serverpilot.io/v1/apps")
+	})
+
+	t.Run("it should return an error for an invalid proxy or CA bundle", func(t *testing.T) {
+		bundle := filepath.Join(t.TempDir(), "ca.pem")
+		os.WriteFile(bundle, []byte("not a certificate"), 0600)
+
+		_, err := NewTransport(TransportSettings{Proxy: "localhost"})
+		assert.ErrorIs(t, err, ErrInvalidProxy)
+		_, err = NewTransport(TransportSettings{CABundle: bundle})
+		assert.ErrorIs(t, err, ErrInvalidCABundle)
+		_, err = NewTransport(TransportSettings{CABundle: bundle + ".missing"})
+		assert.ErrorIs(t, err, ErrInvalidCABundle)
+	})
+}
@@ -0,0 +1,147 @@
+package orphans
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+)
+
+var (
+	ErrCouldNotDelete = errors.New("could not delete resource")
+)
+
+// The type of resource that was found to be orphaned.
+const (
+	SERVER int = iota
+	SYSUSER
+	DATABASE
+	APP
+)
+
+// DefaultSysuser is the sysuser ServerPilot creates on every server. It can't be deleted, so it is never
+// considered orphaned.
+const DefaultSysuser = "serverpilot"
+
+// Finding is a single orphaned resource, along with what we suggest doing about it.
+type Finding struct {
+	Type       int
+	Id         string
+	Name       string
+	ServerName string
+	Action     string
+}
+
+// Deleter removes a resource from the ServerPilot API.
+type Deleter interface {
+	Delete(ctx context.Context, url string) (string, error)
+}
+
+// Prompter asks the user a question, and won't return until they enter one of the valid responses.
+type Prompter interface {
+	Prompt(msg, defaultResponse string, validResponse []string) string
+}
+
+// Find looks for servers with no apps, sysusers with no apps, databases whose app is gone, and apps with
+// no domains.
+func Find(servers []serverpilot.Server, apps []serverpilot.App, sysusers []serverpilot.Sysuser, databases []serverpilot.Database) []Finding {
+	var findings []Finding
+
+	appsByServer := make(map[string]int)
+	appsBySysuser := make(map[string]int)
+	appIds := make(map[string]bool)
+	serverNames := make(map[string]string)
+
+	for _, server := range servers {
+		serverNames[server.Id] = server.Name
+	}
+
+	for _, app := range apps {
+		appsByServer[app.Serverid]++
+		appsBySysuser[app.Sysuserid]++
+		appIds[app.Id] = true
+	}
+
+	for _, server := range servers {
+		if appsByServer[server.Id] == 0 {
+			findings = append(findings, Finding{SERVER, server.Id, server.Name, server.Name, "Delete the server from ServerPilot and decommission the machine"})
+		}
+	}
+
+	for _, sysuser := range sysusers {
+		if sysuser.Name != DefaultSysuser && appsBySysuser[sysuser.Id] == 0 {
+			findings = append(findings, Finding{SYSUSER, sysuser.Id, sysuser.Name, serverNames[sysuser.Serverid], "Delete the sysuser"})
+		}
+	}
+
+	for _, database := range databases {
+		if !appIds[database.Appid] {
+			findings = append(findings, Finding{DATABASE, database.Id, database.Name, serverNames[database.Serverid], "Back up and delete the database"})
+		}
+	}
+
+	for _, app := range apps {
+		if len(app.Domains) == 0 {
+			findings = append(findings, Finding{APP, app.Id, app.Name, serverNames[app.Serverid], "Add a domain, or delete the app"})
+		}
+	}
+
+	return findings
+}
+
+// Fix deletes each finding through the API, after the user has confirmed it. Apps are removed first, then
+// databases, sysusers and finally servers. It returns the findings that were deleted.
+func Fix(ctx context.Context, c Deleter, p Prompter, findings []Finding) ([]Finding, error) {
+	var deleted []Finding
+
+	validYesNoResponses := []string{"y", "Y", "n", "N"}
+
+	for _, t := range []int{APP, DATABASE, SYSUSER, SERVER} {
+		for _, finding := range findings {
+			if finding.Type != t {
+				continue
+			}
+
+			response := p.Prompt(fmt.Sprintf("Delete %s %s (%s)? [y/N]", TypeName(finding.Type), finding.Name, finding.Id), "N", validYesNoResponses)
+			if response == "n" || response == "N" {
+				continue
+			}
+
+			if _, err := c.Delete(ctx, finding.Endpoint()); err != nil {
+				return deleted, fmt.Errorf("%w: %s %s: %s", ErrCouldNotDelete, TypeName(finding.Type), finding.Id, err)
+			}
+
+			deleted = append(deleted, finding)
+		}
+	}
+
+	return deleted, nil
+}
+
+// Endpoint is the ServerPilot API path of the orphaned resource, relative to the API's base url.
+func (f Finding) Endpoint() string {
+	paths := map[int]string{
+		SERVER:   "servers",
+		SYSUSER:  "sysusers",
+		DATABASE: "dbs",
+		APP:      "apps",
+	}
+
+	return fmt.Sprintf("/%s/%s", paths[f.Type], f.Id)
+}
+
+// TypeName is the human-readable name of the resource type.
+func TypeName(t int) string {
+	switch t {
+	case SERVER:
+		return "server"
+	case SYSUSER:
+		return "sysuser"
+	case DATABASE:
+		return "database"
+	case APP:
+		return "app"
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,106 @@
+package orphans
+
+import (
+	"context"
+	"errors"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"gotest.tools/v3/assert"
+	"strings"
+	"testing"
+)
+
+func TestOrphans(t *testing.T) {
+	t.Run("it should find orphaned resources", func(t *testing.T) {
+		servers := []serverpilot.Server{
+			{Id: "s1", Name: "server1"},
+			{Id: "s2", Name: "empty-server"},
+		}
+		apps := []serverpilot.App{
+			{Id: "a1", Name: "app1", Serverid: "s1", Sysuserid: "u1", Domains: []string{"example.com"}},
+			{Id: "a2", Name: "no-domains", Serverid: "s1", Sysuserid: "u1"},
+		}
+		sysusers := []serverpilot.Sysuser{
+			{Id: "u1", Name: "user1", Serverid: "s1"},
+			{Id: "u2", Name: "unused", Serverid: "s1"},
+			{Id: "u3", Name: DefaultSysuser, Serverid: "s2"},
+		}
+		databases := []serverpilot.Database{
+			{Id: "d1", Name: "db1", Appid: "a1", Serverid: "s1"},
+			{Id: "d2", Name: "detached", Appid: "deleted", Serverid: "s1"},
+		}
+
+		got := Find(servers, apps, sysusers, databases)
+
+		var gotIds []string
+		for _, finding := range got {
+			gotIds = append(gotIds, finding.Id)
+		}
+
+		assert.DeepEqual(t, gotIds, []string{"s2", "u2", "d2", "a2"})
+		assert.Equal(t, got[1].ServerName, "server1")
+	})
+
+	t.Run("it should only delete the findings that were confirmed", func(t *testing.T) {
+		findings := []Finding{
+			{Type: SERVER, Id: "s2", Name: "empty-server"},
+			{Type: APP, Id: "a2", Name: "no-domains"},
+			{Type: DATABASE, Id: "d2", Name: "detached"},
+		}
+
+		deleter := &SpyDeleter{}
+		prompter := &PrompterStub{responses: map[string]string{
+			"no-domains":   "y",
+			"detached":     "n",
+			"empty-server": "y",
+		}}
+
+		got, err := Fix(context.Background(), deleter, prompter, findings)
+
+		assert.NilError(t, err)
+		assert.Equal(t, len(got), 2)
+		assert.DeepEqual(t, deleter.urls, []string{
+			"/apps/a2",
+			"/servers/s2",
+		})
+	})
+
+	t.Run("it should stop when a deletion fails", func(t *testing.T) {
+		findings := []Finding{
+			{Type: APP, Id: "a2", Name: "no-domains"},
+			{Type: SERVER, Id: "s2", Name: "empty-server"},
+		}
+
+		deleter := &SpyDeleter{errStub: errors.New("http error")}
+		prompter := &PrompterStub{responses: map[string]string{"no-domains": "y", "empty-server": "y"}}
+
+		got, err := Fix(context.Background(), deleter, prompter, findings)
+
+		assert.ErrorIs(t, err, ErrCouldNotDelete)
+		assert.Equal(t, len(got), 0)
+		assert.Equal(t, len(deleter.urls), 1)
+	})
+}
+
+type SpyDeleter struct {
+	urls    []string
+	errStub error
+}
+
+func (d *SpyDeleter) Delete(ctx context.Context, url string) (string, error) {
+	d.urls = append(d.urls, url)
+	return "", d.errStub
+}
+
+// PrompterStub answers each prompt based on the name of the resource in the message.
+type PrompterStub struct {
+	responses map[string]string
+}
+
+func (p *PrompterStub) Prompt(msg, defaultResponse string, validResponse []string) string {
+	for name, response := range p.responses {
+		if strings.Contains(msg, " "+name+" ") {
+			return response
+		}
+	}
+	return defaultResponse
+}
@@ -0,0 +1,374 @@
+package replay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/dns"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrNoFixture      = errors.New("no recorded fixture")
+	ErrInvalidFixture = errors.New("invalid fixture")
+)
+
+// Redacted replaces the value of any header that holds a secret.
+const Redacted = "REDACTED"
+
+const (
+	httpDirname = "http"
+	dnsDirname  = "dns"
+	fixtureExt  = ".json"
+)
+
+// credentialHeaders are the headers that identify the account a request is made for.
+var credentialHeaders = []string{"Authorization", "X-Auth-Email", "X-Auth-Key"}
+
+// secretHeaders are the request and response headers that are never written to a fixture.
+var secretHeaders = append([]string{"Cookie", "Set-Cookie"}, credentialHeaders...)
+
+// Network is where the HTTP requests and DNS lookups of a command go. Both the Recorder and the Replayer implement it.
+type Network interface {
+	http.RoundTripper
+	dns.Lookups
+}
+
+// systemLookups makes the lookups with the system resolver.
+type systemLookups struct{}
+
+func (systemLookups) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+func (systemLookups) LookupNS(ctx context.Context, host string) ([]*net.NS, error) {
+	return net.DefaultResolver.LookupNS(ctx, host)
+}
+
+// httpFixture is a recorded request, along with the response to it.
+type httpFixture struct {
+	Method string      `json:"method"`
+	Url    string      `json:"url"`
+	Header http.Header `json:"header"`
+	// Account is a hash of the credentials the request was made with, so the responses for different accounts can
+	// be told apart without storing the credentials.
+	Account        string      `json:"account,omitempty"`
+	StatusCode     int         `json:"status_code"`
+	ResponseHeader http.Header `json:"response_header"`
+	Body           string      `json:"body"`
+}
+
+// dnsFixture is a recorded DNS lookup. The Records are IP addresses, or nameserver hosts.
+type dnsFixture struct {
+	Type    string   `json:"type"`
+	Host    string   `json:"host"`
+	Records []string `json:"records"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Recorder passes every request and lookup on to the real network, and writes each one to a fixture file in dir.
+type Recorder struct {
+	dir       string
+	transport http.RoundTripper
+	lookups   dns.Lookups
+}
+
+// NewRecorder returns a Recorder that writes fixtures to dir, making requests with the transport and lookups with the
+// lookups. A nil transport uses http.DefaultTransport, and nil lookups use the system resolver.
+func NewRecorder(dir string, transport http.RoundTripper, lookups dns.Lookups) (*Recorder, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if lookups == nil {
+		lookups = systemLookups{}
+	}
+
+	for _, d := range []string{httpDirname, dnsDirname} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Recorder{dir: dir, transport: transport, lookups: lookups}, nil
+}
+
+// RoundTrip makes the request, and records it along with the response. It implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	f := httpFixture{
+		Method:         req.Method,
+		Url:            req.URL.String(),
+		Header:         redact(req.Header),
+		Account:        account(req.Header),
+		StatusCode:     resp.StatusCode,
+		ResponseHeader: redact(resp.Header),
+		Body:           string(body),
+	}
+	if err := writeFixture(filepath.Join(r.dir, httpDirname, httpFilename(f)), f); err != nil {
+		return nil, err
+	}
+
+	return newResponse(req, f), nil
+}
+
+// LookupIP looks up the IP addresses of the host, and records them.
+func (r *Recorder) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	ips, err := r.lookups.LookupIP(ctx, host)
+	// Don't record a lookup that was interrupted, it would replay as a failure
+	if ctx.Err() != nil {
+		return ips, err
+	}
+
+	f := dnsFixture{Type: "ip", Host: host, Error: errorString(err)}
+	for _, ip := range ips {
+		f.Records = append(f.Records, ip.String())
+	}
+
+	return ips, r.writeDns(f, err)
+}
+
+// LookupNS looks up the nameservers of the host, and records them.
+func (r *Recorder) LookupNS(ctx context.Context, host string) ([]*net.NS, error) {
+	ns, err := r.lookups.LookupNS(ctx, host)
+	if ctx.Err() != nil {
+		return ns, err
+	}
+
+	f := dnsFixture{Type: "ns", Host: host, Error: errorString(err)}
+	for _, n := range ns {
+		f.Records = append(f.Records, n.Host)
+	}
+
+	return ns, r.writeDns(f, err)
+}
+
+// writeDns records the lookup, and returns the error of the lookup itself unless recording it failed.
+func (r *Recorder) writeDns(f dnsFixture, lookupErr error) error {
+	if err := writeFixture(filepath.Join(r.dir, dnsDirname, dnsFilename(f.Type, f.Host)), f); err != nil {
+		return err
+	}
+	return lookupErr
+}
+
+// Replayer answers every request and lookup from the fixtures written by a Recorder, without using the network.
+type Replayer struct {
+	// requests holds the fixtures for each method and url, as there may be one for each account.
+	requests map[string][]httpFixture
+	dir      string
+}
+
+// NewReplayer loads the HTTP fixtures in dir. DNS fixtures are read when they are looked up.
+func NewReplayer(dir string) (*Replayer, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, httpDirname))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoFixture, err)
+	}
+
+	r := &Replayer{requests: make(map[string][]httpFixture), dir: dir}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != fixtureExt {
+			continue
+		}
+
+		var f httpFixture
+		if err := readFixture(filepath.Join(dir, httpDirname, e.Name()), &f); err != nil {
+			return nil, err
+		}
+		key := requestKey(f.Method, f.Url)
+		r.requests[key] = append(r.requests[key], f)
+	}
+
+	return r, nil
+}
+
+// RoundTrip returns the recorded response to the request. When the request was recorded for several accounts, the
+// one made with the same credentials is used. It implements http.RoundTripper.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	fixtures := r.requests[requestKey(req.Method, req.URL.String())]
+
+	switch len(fixtures) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s %s", ErrNoFixture, req.Method, req.URL)
+	case 1:
+		// Let a fixture from someone else's run be replayed with any credentials
+		return newResponse(req, fixtures[0]), nil
+	}
+
+	acct := account(req.Header)
+	for _, f := range fixtures {
+		if f.Account == acct {
+			return newResponse(req, f), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s %s was recorded for %d accounts, and none match these credentials", ErrNoFixture, req.Method, req.URL, len(fixtures))
+}
+
+// LookupIP returns the recorded IP addresses of the host.
+func (r *Replayer) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	f, err := r.readDns("ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, record := range f.Records {
+		ips = append(ips, net.ParseIP(record))
+	}
+
+	return ips, f.err()
+}
+
+// LookupNS returns the recorded nameservers of the host.
+func (r *Replayer) LookupNS(ctx context.Context, host string) ([]*net.NS, error) {
+	f, err := r.readDns("ns", host)
+	if err != nil {
+		return nil, err
+	}
+
+	var ns []*net.NS
+	for _, record := range f.Records {
+		ns = append(ns, &net.NS{Host: record})
+	}
+
+	return ns, f.err()
+}
+
+func (r *Replayer) readDns(t, host string) (dnsFixture, error) {
+	var f dnsFixture
+	err := readFixture(filepath.Join(r.dir, dnsDirname, dnsFilename(t, host)), &f)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, fmt.Errorf("%w: %s lookup for %s", ErrNoFixture, t, host)
+	}
+	return f, err
+}
+
+// err returns the error the lookup failed with.
+func (f dnsFixture) err() error {
+	if f.Error == "" {
+		return nil
+	}
+	return &net.DNSError{Err: f.Error, Name: f.Host}
+}
+
+func newResponse(req *http.Request, f httpFixture) *http.Response {
+	header := f.ResponseHeader
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.StatusCode, http.StatusText(f.StatusCode)),
+		StatusCode:    f.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}
+}
+
+// redact returns a copy of the header without any secrets.
+func redact(h http.Header) http.Header {
+	redacted := h.Clone()
+	for _, name := range secretHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, Redacted)
+		}
+	}
+	return redacted
+}
+
+// account returns a hash of the credentials in the header, or an empty string if there are none.
+func account(h http.Header) string {
+	s := sha256.New()
+	found := false
+	for _, name := range credentialHeaders {
+		if v := h.Get(name); v != "" {
+			fmt.Fprintf(s, "%s: %s\n", name, v)
+			found = true
+		}
+	}
+	if !found {
+		return ""
+	}
+	return hex.EncodeToString(s.Sum(nil))[:16]
+}
+
+func requestKey(method, url string) string {
+	return method + " " + url
+}
+
+func httpFilename(f httpFixture) string {
+	h := sha256.Sum256([]byte(requestKey(f.Method, f.Url) + " " + f.Account))
+	return hex.EncodeToString(h[:])[:32] + fixtureExt
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9.-]`)
+
+func dnsFilename(t, host string) string {
+	return t + "-" + unsafeFilenameChars.ReplaceAllString(strings.ToLower(host), "_") + fixtureExt
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// writeFixture writes the fixture atomically, so concurrent requests never leave a partial file.
+func writeFixture(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".fixture-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func readFixture(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidFixture, filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"gotest.tools/v3/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	t.Run("it should replay recorded requests", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte(`{"data": "` + r.URL.Path + `"}`))
+		}))
+		defer server.Close()
+		dir := t.TempDir()
+
+		recorder, err := NewRecorder(dir, nil, nil)
+		assert.NilError(t, err)
+		recorded := get(t, recorder, server.URL+"/v1/apps", "Basic secret")
+
+		replayer, err := NewReplayer(dir)
+		assert.NilError(t, err)
+		server.Close()
+		replayed := get(t, replayer, server.URL+"/v1/apps", "Basic other")
+
+		assert.Equal(t, replayed.StatusCode, 200)
+		assert.Equal(t, replayed.Header.Get("ETag"), `"v1"`)
+		assert.Equal(t, body(t, replayed), body(t, recorded))
+	})
+
+	t.Run("it should redact credentials", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-secret"})
+		}))
+		defer server.Close()
+		dir := t.TempDir()
+
+		recorder, _ := NewRecorder(dir, nil, nil)
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set("X-Auth-Email", "someone@example.com")
+		req.Header.Set("X-Auth-Key", "key-secret")
+		resp, err := recorder.RoundTrip(req)
+		assert.NilError(t, err)
+		resp.Body.Close()
+
+		files, _ := filepath.Glob(filepath.Join(dir, httpDirname, "*"+fixtureExt))
+		assert.Equal(t, len(files), 1)
+		contents, _ := os.ReadFile(files[0])
+		for _, secret := range []string{"someone@example.com", "key-secret", "cookie-secret"} {
+			assert.Assert(t, !strings.Contains(string(contents), secret), secret)
+		}
+	})
+
+	t.Run("it should replay the response for the matching account", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Header.Get("Authorization")))
+		}))
+		defer server.Close()
+		dir := t.TempDir()
+
+		recorder, _ := NewRecorder(dir, nil, nil)
+		get(t, recorder, server.URL, "Basic one")
+		get(t, recorder, server.URL, "Basic two")
+
+		replayer, _ := NewReplayer(dir)
+
+		assert.Equal(t, body(t, get(t, replayer, server.URL, "Basic two")), "Basic two")
+		_, err := replayer.RoundTrip(newRequest(server.URL, "Basic three"))
+		assert.ErrorIs(t, err, ErrNoFixture)
+	})
+
+	t.Run("it should return an error for requests that weren't recorded", func(t *testing.T) {
+		dir := t.TempDir()
+		NewRecorder(dir, nil, nil)
+		replayer, _ := NewReplayer(dir)
+
+		_, err := replayer.RoundTrip(newRequest("https://api.serverpilot.io/v1/apps", ""))
+
+		assert.ErrorIs(t, err, ErrNoFixture)
+	})
+
+	t.Run("it should replay recorded dns lookups", func(t *testing.T) {
+		dir := t.TempDir()
+		NewRecorder(dir, nil, nil)
+		writeFixture(filepath.Join(dir, dnsDirname, dnsFilename("ip", "example.com")), dnsFixture{Type: "ip", Host: "example.com", Records: []string{"127.0.0.1"}})
+		writeFixture(filepath.Join(dir, dnsDirname, dnsFilename("ns", "example.com")), dnsFixture{Type: "ns", Host: "example.com", Records: []string{"foo.ns.cloudflare.com."}})
+		writeFixture(filepath.Join(dir, dnsDirname, dnsFilename("ip", "gone.example.com")), dnsFixture{Type: "ip", Host: "gone.example.com", Error: "no such host"})
+		replayer, _ := NewReplayer(dir)
+
+		ips, err := replayer.LookupIP(context.Background(), "example.com")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, ips, []net.IP{net.ParseIP("127.0.0.1")})
+
+		ns, err := replayer.LookupNS(context.Background(), "example.com")
+		assert.NilError(t, err)
+		assert.Equal(t, ns[0].Host, "foo.ns.cloudflare.com.")
+
+		_, err = replayer.LookupIP(context.Background(), "gone.example.com")
+		var dnsErr *net.DNSError
+		assert.Assert(t, errors.As(err, &dnsErr))
+
+		_, err = replayer.LookupNS(context.Background(), "other.com")
+		assert.ErrorIs(t, err, ErrNoFixture)
+	})
+}
+
+func newRequest(url, auth string) *http.Request {
+	req, _ := http.NewRequest("GET", url, nil)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	return req
+}
+
+func get(t *testing.T, rt http.RoundTripper, url, auth string) *http.Response {
+	t.Helper()
+	resp, err := rt.RoundTrip(newRequest(url, auth))
+	assert.NilError(t, err)
+	return resp
+}
+
+func body(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	return string(b)
+}
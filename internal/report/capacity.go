@@ -0,0 +1,131 @@
+package report
+
+import (
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"math"
+	"sort"
+)
+
+// ServerCapacity is the resource usage of a single server (or of the whole fleet, for the totals row).
+type ServerCapacity struct {
+	Server    serverpilot.Server
+	Apps      int
+	Sysusers  int
+	Databases int
+	Domains   int
+	// Runtimes is the number of apps using each runtime.
+	Runtimes map[serverpilot.Runtime]int
+	// Oldest and Newest are nil when there are no apps.
+	Oldest *serverpilot.App
+	Newest *serverpilot.App
+}
+
+// CapacityReport is the per-server breakdown of the fleet, along with the fleet-wide totals.
+type CapacityReport struct {
+	Servers []ServerCapacity
+	Totals  ServerCapacity
+	// Imbalance is the coefficient of variation (standard deviation / mean) of the number of apps
+	// per server. A score of 0 means every server has the same number of apps, and the higher the
+	// score the more unevenly apps are distributed.
+	Imbalance float64
+}
+
+// Capacity builds a CapacityReport from the list of servers and everything that lives on them. Apps,
+// sysusers and databases that don't belong to any of the given servers are only counted in the totals.
+func Capacity(servers []serverpilot.Server, appservers []serverpilot.AppServer, sysusers []serverpilot.Sysuser, databases []serverpilot.Database) CapacityReport {
+	report := CapacityReport{Totals: newServerCapacity(serverpilot.Server{Name: "TOTAL"})}
+
+	// Keep track of each server's position so we can add to it as we go
+	index := make(map[string]int)
+	for i, server := range servers {
+		index[server.Id] = i
+		report.Servers = append(report.Servers, newServerCapacity(server))
+	}
+
+	for _, appserver := range appservers {
+		if i, ok := index[appserver.Server.Id]; ok {
+			report.Servers[i].addApp(appserver.App)
+		}
+		report.Totals.addApp(appserver.App)
+	}
+
+	for _, sysuser := range sysusers {
+		if i, ok := index[sysuser.Serverid]; ok {
+			report.Servers[i].Sysusers++
+		}
+		report.Totals.Sysusers++
+	}
+
+	for _, database := range databases {
+		if i, ok := index[database.Serverid]; ok {
+			report.Servers[i].Databases++
+		}
+		report.Totals.Databases++
+	}
+
+	// Show the busiest servers first
+	sort.SliceStable(report.Servers, func(i, j int) bool {
+		return report.Servers[i].Apps > report.Servers[j].Apps
+	})
+
+	report.Imbalance = imbalance(report.Servers)
+
+	return report
+}
+
+// SortedRuntimes returns the runtimes in use, ordered by name.
+func (c ServerCapacity) SortedRuntimes() []serverpilot.Runtime {
+	var runtimes []serverpilot.Runtime
+	for runtime := range c.Runtimes {
+		runtimes = append(runtimes, runtime)
+	}
+
+	sort.Slice(runtimes, func(i, j int) bool {
+		return runtimes[i] < runtimes[j]
+	})
+
+	return runtimes
+}
+
+func newServerCapacity(server serverpilot.Server) ServerCapacity {
+	return ServerCapacity{Server: server, Runtimes: make(map[serverpilot.Runtime]int)}
+}
+
+func (c *ServerCapacity) addApp(app serverpilot.App) {
+	c.Apps++
+	c.Domains += len(app.Domains)
+	c.Runtimes[app.Runtime]++
+
+	if c.Oldest == nil || app.Datecreated < c.Oldest.Datecreated {
+		oldest := app
+		c.Oldest = &oldest
+	}
+	if c.Newest == nil || app.Datecreated > c.Newest.Datecreated {
+		newest := app
+		c.Newest = &newest
+	}
+}
+
+func imbalance(servers []ServerCapacity) float64 {
+	if len(servers) == 0 {
+		return 0
+	}
+
+	total := 0
+	for _, server := range servers {
+		total += server.Apps
+	}
+
+	mean := float64(total) / float64(len(servers))
+	if mean == 0 {
+		return 0
+	}
+
+	variance := 0.0
+	for _, server := range servers {
+		variance += math.Pow(float64(server.Apps)-mean, 2)
+	}
+	variance /= float64(len(servers))
+
+	return math.Sqrt(variance) / mean
+}
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"gotest.tools/v3/assert"
+	"testing"
+)
+
+func TestCapacity(t *testing.T) {
+	server1 := serverpilot.Server{Id: "s1", Name: "server1"}
+	server2 := serverpilot.Server{Id: "s2", Name: "server2"}
+
+	t.Run("it should count the resources on each server", func(t *testing.T) {
+		appservers := []serverpilot.AppServer{
+			{App: serverpilot.App{Id: "1", Runtime: "php7.4", Domains: []string{"a.com", "www.a.com"}, Datecreated: 100}, Server: server1},
+			{App: serverpilot.App{Id: "2", Runtime: "php8.2", Domains: []string{"b.com"}, Datecreated: 300}, Server: server1},
+			{App: serverpilot.App{Id: "3", Runtime: "php8.2", Domains: []string{"c.com"}, Datecreated: 200}, Server: server2},
+		}
+		sysusers := []serverpilot.Sysuser{{Id: "u1", Serverid: "s1"}, {Id: "u2", Serverid: "s2"}, {Id: "u3", Serverid: "s2"}}
+		databases := []serverpilot.Database{{Id: "d1", Serverid: "s1"}}
+
+		got := Capacity([]serverpilot.Server{server1, server2}, appservers, sysusers, databases)
+
+		assert.Equal(t, len(got.Servers), 2)
+
+		s1 := got.Servers[0]
+		assert.Equal(t, s1.Server.Name, "server1")
+		assert.Equal(t, s1.Apps, 2)
+		assert.Equal(t, s1.Sysusers, 1)
+		assert.Equal(t, s1.Databases, 1)
+		assert.Equal(t, s1.Domains, 3)
+		assert.DeepEqual(t, s1.Runtimes, map[serverpilot.Runtime]int{"php7.4": 1, "php8.2": 1})
+		assert.Equal(t, s1.Oldest.Id, "1")
+		assert.Equal(t, s1.Newest.Id, "2")
+
+		s2 := got.Servers[1]
+		assert.Equal(t, s2.Apps, 1)
+		assert.Equal(t, s2.Sysusers, 2)
+		assert.Equal(t, s2.Databases, 0)
+
+		assert.Equal(t, got.Totals.Apps, 3)
+		assert.Equal(t, got.Totals.Sysusers, 3)
+		assert.Equal(t, got.Totals.Databases, 1)
+		assert.Equal(t, got.Totals.Domains, 4)
+		assert.Equal(t, got.Totals.Oldest.Id, "1")
+		assert.Equal(t, got.Totals.Newest.Id, "2")
+	})
+
+	t.Run("it should leave oldest and newest empty for servers without apps", func(t *testing.T) {
+		got := Capacity([]serverpilot.Server{server1}, nil, nil, nil)
+
+		assert.Assert(t, got.Servers[0].Oldest == nil)
+		assert.Assert(t, got.Servers[0].Newest == nil)
+	})
+
+	t.Run("it should score how evenly apps are distributed", func(t *testing.T) {
+		var tests = []struct {
+			name       string
+			appservers []serverpilot.AppServer
+			want       float64
+		}{
+			{"no apps", nil, 0},
+			{
+				"balanced",
+				[]serverpilot.AppServer{
+					{App: serverpilot.App{Id: "1"}, Server: server1},
+					{App: serverpilot.App{Id: "2"}, Server: server2},
+				},
+				0,
+			},
+			{
+				"all on one server",
+				[]serverpilot.AppServer{
+					{App: serverpilot.App{Id: "1"}, Server: server1},
+					{App: serverpilot.App{Id: "2"}, Server: server1},
+				},
+				1,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got := Capacity([]serverpilot.Server{server1, server2}, tt.appservers, nil, nil)
+
+				assert.Equal(t, got.Imbalance, tt.want)
+			})
+		}
+	})
+}
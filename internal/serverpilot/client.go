@@ -1,37 +1,157 @@
 package serverpilot
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/jfortunato/serverpilot-tools/internal/http"
 	"log"
+	nethttp "net/http"
+	"strings"
 )
 
+// DefaultBaseUrl is the base url of the ServerPilot API, which the paths given to the client are relative to.
+const DefaultBaseUrl = "https://api.serverpilot.io/v1"
+
+// APIError is an error response from the ServerPilot API. The Message is the one sent in the error envelope, if any.
+type APIError struct {
+	StatusCode int
+	Message    string
+	err        *http.StatusError
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("serverpilot api error (%d)", e.StatusCode)
+	if e.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Message)
+	}
+	if hint := e.hint(); hint != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, hint)
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// hint suggests what to do about the error.
+func (e *APIError) hint() string {
+	switch {
+	case e.StatusCode == nethttp.StatusUnauthorized:
+		return "invalid client id or API key, check the credentials or profile you are using"
+	case e.StatusCode == nethttp.StatusForbidden:
+		return "the API key is not allowed to do this"
+	case e.StatusCode == nethttp.StatusNotFound:
+		return "the resource does not exist, it may have already been deleted"
+	case e.StatusCode == nethttp.StatusTooManyRequests:
+		return "rate limited, try again later or lower --serverpilot-rate-limit"
+	case e.StatusCode >= 500:
+		return "ServerPilot is having problems, try again later"
+	}
+	return ""
+}
+
+// errorEnvelope is the body ServerPilot sends with error responses.
+type errorEnvelope struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// convertError converts an unsuccessful response into an APIError. Other errors are returned unchanged.
+func convertError(err error) error {
+	var statusErr *http.StatusError
+	if !errors.As(err, &statusErr) {
+		return err
+	}
+
+	var envelope errorEnvelope
+	// The body isn't always JSON (e.g. from a proxy), in which case there is just no message
+	_ = json.Unmarshal([]byte(statusErr.Body), &envelope)
+
+	return &APIError{StatusCode: statusErr.StatusCode, Message: envelope.Error.Message, err: statusErr}
+}
+
 // Makes all the requests to the ServerPilot API. Since we don't want to hammer
 // the API, we'll rate limit requests by default.
 type serverPilotClient struct {
 	credentials Credentials
-	c           http.CachingRateLimitedClient
+	c           httpClient
+	baseUrl     string
 }
 
-func (c *serverPilotClient) Get(url string) (string, error) {
-	basicAuth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", c.credentials.ClientId, c.credentials.ApiKey)))
+type httpClient interface {
+	http.CachingRateLimitedClient
+	http.RateLimitedClient
+	http.CacheInvalidator
+}
 
-	return c.c.GetFromCacheOrFetchWithRateLimit(http.Request{
-		Url: url,
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Basic %s", basicAuth),
-		},
+// collections are the paths of the lists that a deleted resource may be cached in. Deleting a server also deletes
+// its sysusers, apps and databases, so every list is invalidated after any delete.
+var collections = []string{"/apps", "/servers", "/sysusers", "/dbs"}
+
+// Get fetches the given url, or the path (such as /apps) relative to the base url.
+func (c *serverPilotClient) Get(ctx context.Context, url string) (string, error) {
+	body, err := c.c.GetFromCacheOrFetchWithRateLimit(ctx, http.Request{
+		Url:     c.url(url),
+		Headers: c.headers(),
+	})
+	return body, convertError(err)
+}
+
+// Delete removes the resource at the given url. These requests are never cached, and once one succeeds the cached
+// lists are removed, so the deleted resource isn't shown again until the cache expires.
+func (c *serverPilotClient) Delete(ctx context.Context, url string) (string, error) {
+	body, err := c.c.FetchWithRateLimit(ctx, http.Request{
+		Url:     c.url(url),
+		Headers: c.headers(),
+		Method:  "DELETE",
 	})
+	if err != nil {
+		return body, convertError(err)
+	}
+
+	for _, path := range append(collections, url) {
+		c.c.Invalidate(http.Request{Url: c.url(path), Headers: c.headers()})
+	}
+
+	return body, nil
 }
 
-// Constructor for creating our serverPilotClient. User/key are used to authenticate with the ServerPilot API.
-func NewClient(l *log.Logger, user, key string) *serverPilotClient {
+// url resolves a path against the base url. Full urls are used as they are.
+func (c *serverPilotClient) url(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return path
+	}
+	return c.baseUrl + path
+}
+
+func (c *serverPilotClient) headers() map[string]string {
+	basicAuth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", c.credentials.ClientId, c.credentials.ApiKey)))
+
+	return map[string]string{
+		"Authorization": fmt.Sprintf("Basic %s", basicAuth),
+	}
+}
+
+// Constructor for creating our serverPilotClient. User/key are used to authenticate with the ServerPilot API, and
+// responses are cached and retried according to the settings. Paths are relative to the settings' BaseUrl, or the
+// DefaultBaseUrl.
+func NewClient(l *log.Logger, user, key string, settings http.ClientSettings) *serverPilotClient {
+	baseUrl := DefaultBaseUrl
+	if settings.BaseUrl != "" {
+		baseUrl = strings.TrimSuffix(settings.BaseUrl, "/")
+	}
+
 	return &serverPilotClient{
+		baseUrl: baseUrl,
 		credentials: Credentials{
 			ClientId: user,
 			ApiKey:   key,
 		},
-		c: http.NewClient(l),
+		c: http.NewClient(l, settings),
 	}
 }
@@ -1,8 +1,141 @@
 package serverpilot
 
 import (
+	"context"
+	"errors"
+	"github.com/jfortunato/serverpilot-tools/internal/http"
+	"gotest.tools/v3/assert"
 	"testing"
 )
 
 func TestServerPilotClient(t *testing.T) {
+	t.Run("it should convert error responses into api errors", func(t *testing.T) {
+		var tests = []struct {
+			name    string
+			status  int
+			body    string
+			want    *APIError
+			wantMsg string
+		}{
+			{
+				"unauthorized",
+				401,
+				`{"error": {"message": "Invalid client id or API key."}}`,
+				&APIError{StatusCode: 401, Message: "Invalid client id or API key."},
+				"serverpilot api error (401): Invalid client id or API key. (invalid client id or API key, check the credentials or profile you are using)",
+			},
+			{
+				"not found",
+				404,
+				`{"error": {"message": "Not found."}}`,
+				&APIError{StatusCode: 404, Message: "Not found."},
+				"serverpilot api error (404): Not found. (the resource does not exist, it may have already been deleted)",
+			},
+			{
+				"server error without an envelope",
+				502,
+				"<html>Bad Gateway</html>",
+				&APIError{StatusCode: 502},
+				"serverpilot api error (502) (ServerPilot is having problems, try again later)",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				statusErr := &http.StatusError{Method: "GET", Url: "https://api.serverpilot.io/v1/apps", StatusCode: tt.status, Body: tt.body}
+				c := &serverPilotClient{c: &stubHttpClient{err: statusErr}}
+
+				_, err := c.Get(context.Background(), "https://api.serverpilot.io/v1/apps")
+
+				var apiErr *APIError
+				assert.Assert(t, errors.As(err, &apiErr))
+				assert.Equal(t, apiErr.StatusCode, tt.want.StatusCode)
+				assert.Equal(t, apiErr.Message, tt.want.Message)
+				assert.Equal(t, err.Error(), tt.wantMsg)
+				assert.Assert(t, errors.Is(err, statusErr))
+			})
+		}
+	})
+
+	t.Run("it should return other errors unchanged", func(t *testing.T) {
+		c := &serverPilotClient{c: &stubHttpClient{err: http.ErrCouldNotMakeRequest}}
+
+		_, err := c.Delete(context.Background(), "https://api.serverpilot.io/v1/apps/1")
+
+		assert.Equal(t, err, http.ErrCouldNotMakeRequest)
+	})
+
+	t.Run("it should resolve paths against the base url", func(t *testing.T) {
+		stub := &stubHttpClient{}
+		c := NewClient(nil, "user", "key", http.ClientSettings{BaseUrl: "http://localhost:8080/v1/"})
+		c.c = stub
+
+		c.Get(context.Background(), "/apps")
+		c.Delete(context.Background(), "/apps/1")
+		c.Get(context.Background(), "https://api.serverpilot.io/v1/servers")
+
+		assert.DeepEqual(t, stub.urls, []string{
+			"http://localhost:8080/v1/apps",
+			"http://localhost:8080/v1/apps/1",
+			"https://api.serverpilot.io/v1/servers",
+		})
+	})
+
+	t.Run("it should invalidate the cached lists after a delete", func(t *testing.T) {
+		stub := &stubHttpClient{}
+		c := NewClient(nil, "user", "key", http.ClientSettings{})
+		c.c = stub
+
+		_, err := c.Delete(context.Background(), "/apps/1")
+
+		assert.NilError(t, err)
+		assert.DeepEqual(t, stub.invalidated, []string{
+			"https://api.serverpilot.io/v1/apps",
+			"https://api.serverpilot.io/v1/servers",
+			"https://api.serverpilot.io/v1/sysusers",
+			"https://api.serverpilot.io/v1/dbs",
+			"https://api.serverpilot.io/v1/apps/1",
+		})
+	})
+
+	t.Run("it should not invalidate anything when a delete fails", func(t *testing.T) {
+		stub := &stubHttpClient{err: http.ErrCouldNotMakeRequest}
+		c := NewClient(nil, "user", "key", http.ClientSettings{})
+		c.c = stub
+
+		c.Delete(context.Background(), "/apps/1")
+
+		assert.Equal(t, len(stub.invalidated), 0)
+	})
+
+	t.Run("it should default to the serverpilot api", func(t *testing.T) {
+		stub := &stubHttpClient{}
+		c := NewClient(nil, "user", "key", http.ClientSettings{})
+		c.c = stub
+
+		c.Get(context.Background(), "/apps")
+
+		assert.DeepEqual(t, stub.urls, []string{"https://api.serverpilot.io/v1/apps"})
+	})
+}
+
+type stubHttpClient struct {
+	body        string
+	err         error
+	urls        []string
+	invalidated []string
+}
+
+func (c *stubHttpClient) GetFromCacheOrFetchWithRateLimit(ctx context.Context, req http.Request) (string, error) {
+	c.urls = append(c.urls, req.Url)
+	return c.body, c.err
+}
+
+func (c *stubHttpClient) FetchWithRateLimit(ctx context.Context, req http.Request) (string, error) {
+	c.urls = append(c.urls, req.Url)
+	return c.body, c.err
+}
+
+func (c *stubHttpClient) Invalidate(req http.Request) {
+	c.invalidated = append(c.invalidated, req.Url)
 }
@@ -0,0 +1,316 @@
+package serverpilot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Runtime is the language runtime of an app, such as "php8.2".
+type Runtime string
+
+// Version parses the numeric version out of the runtime. Only PHP runtimes are supported.
+func (r Runtime) Version() (Version, error) {
+	// Ensure the runtime is prefixed with "php".
+	if !strings.HasPrefix(string(r), "php") {
+		return Version{}, fmt.Errorf("%w: %q (only php runtimes are supported)", ErrInvalidRuntime, r)
+	}
+
+	// Remove the "php" prefix.
+	v, err := ParseVersion(string(r[3:]))
+	if err != nil {
+		return Version{}, fmt.Errorf("%w: %q: %s", ErrInvalidRuntime, r, err)
+	}
+
+	return v, nil
+}
+
+// Version is a comparable runtime version. Missing components are treated as 0, so "8.2" is 8.2.0.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion parses a version such as "8", "8.2" or "8.2.1".
+func ParseVersion(s string) (Version, error) {
+	p, err := parsePartialVersion(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if p.wildcard {
+		return Version{}, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+	}
+
+	return p.v, nil
+}
+
+// Compare returns -1 if v is lower than o, 1 if it is higher, and 0 if they are equal.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func (v Version) String() string {
+	if v.Patch != 0 {
+		return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	}
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// RuntimeRange is a set of version constraints. Constraints separated by whitespace must all match, and
+// groups separated by "||" are alternatives, e.g. ">=7.4 <8.1 || 8.3.x". A zero value matches everything.
+type RuntimeRange []constraints
+
+// constraint is a half open bound on a version. Every range expression is reduced to these.
+type constraint struct {
+	op string
+	v  Version
+}
+
+type constraints []constraint
+
+// ParseRuntimeRange parses a range expression. Versions may optionally be prefixed with "php". Supported
+// operators are >=, >, <=, <, = and !=. A version without an operator, or with "x" or "*" wildcards,
+// matches every version that starts with it, so "8" and "8.x" both match 8.0 up to (but not including) 9.0.
+func ParseRuntimeRange(expr string) (RuntimeRange, error) {
+	var r RuntimeRange
+
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+
+	for _, group := range strings.Split(expr, "||") {
+		var g constraints
+
+		fields := strings.Fields(group)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%w: %q: empty alternative", ErrInvalidRuntimeRange, expr)
+		}
+
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			op, version := splitOperator(field)
+
+			// Allow a space between the operator and the version, e.g. ">= 7.4"
+			if version == "" && i+1 < len(fields) {
+				i++
+				version = fields[i]
+			}
+
+			c, err := newConstraints(op, version)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q: %s", ErrInvalidRuntimeRange, expr, err)
+			}
+
+			g = append(g, c...)
+		}
+
+		r = append(r, g)
+	}
+
+	return r, nil
+}
+
+// RuntimeRangeFromBounds creates an inclusive range from a minimum and maximum runtime. Either can be
+// empty to leave that side unbounded.
+func RuntimeRangeFromBounds(min, max Runtime) (RuntimeRange, error) {
+	var g constraints
+
+	if min != "" {
+		if _, err := min.Version(); err != nil {
+			return nil, err
+		}
+		c, err := newConstraints(">=", string(min))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %s", ErrInvalidRuntime, min, err)
+		}
+		g = append(g, c...)
+	}
+
+	if max != "" {
+		if _, err := max.Version(); err != nil {
+			return nil, err
+		}
+		c, err := newConstraints("<=", string(max))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %s", ErrInvalidRuntime, max, err)
+		}
+		g = append(g, c...)
+	}
+
+	if len(g) == 0 {
+		return nil, nil
+	}
+
+	return RuntimeRange{g}, nil
+}
+
+// Intersect returns a range that only matches versions matched by both ranges.
+func (r RuntimeRange) Intersect(o RuntimeRange) RuntimeRange {
+	if len(r) == 0 {
+		return o
+	}
+	if len(o) == 0 {
+		return r
+	}
+
+	var result RuntimeRange
+	for _, a := range r {
+		for _, b := range o {
+			group := append(append(constraints{}, a...), b...)
+			result = append(result, group)
+		}
+	}
+
+	return result
+}
+
+// Contains reports whether the version satisfies the range.
+func (r RuntimeRange) Contains(v Version) bool {
+	if len(r) == 0 {
+		return true
+	}
+
+	for _, group := range r {
+		if group.matches(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g constraints) matches(v Version) bool {
+	for _, c := range g {
+		if !c.matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c constraint) matches(v Version) bool {
+	cmp := v.Compare(c.v)
+
+	switch c.op {
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "!=":
+		return cmp != 0
+	}
+
+	return false
+}
+
+func splitOperator(s string) (string, string) {
+	for _, op := range []string{">=", "<=", "!=", "==", ">", "<", "="} {
+		if strings.HasPrefix(s, op) {
+			return op, s[len(op):]
+		}
+	}
+	return "", s
+}
+
+// newConstraints reduces an operator and a (possibly partial) version to ">=", "<" and "!=" constraints.
+func newConstraints(op, version string) ([]constraint, error) {
+	p, err := parsePartialVersion(strings.TrimPrefix(version, "php"))
+	if err != nil {
+		return nil, err
+	}
+
+	lower, upper := p.v, p.next()
+
+	switch op {
+	case ">=":
+		return []constraint{{">=", lower}}, nil
+	case ">":
+		return []constraint{{">=", upper}}, nil
+	case "<=":
+		return []constraint{{"<", upper}}, nil
+	case "<":
+		return []constraint{{"<", lower}}, nil
+	case "!=":
+		if p.parts == 3 {
+			return []constraint{{"!=", lower}}, nil
+		}
+		return nil, fmt.Errorf("%w: != requires a full version, got %q", ErrInvalidVersion, version)
+	case "", "=", "==":
+		if p.parts == 0 {
+			// A lone wildcard matches everything
+			return nil, nil
+		}
+		return []constraint{{">=", lower}, {"<", upper}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+// partialVersion is a version that may be missing components, such as "8" or "8.x".
+type partialVersion struct {
+	v Version
+	// parts is the number of numeric components that were specified.
+	parts int
+	// wildcard is set when the version ends in an explicit "x" or "*".
+	wildcard bool
+}
+
+func parsePartialVersion(s string) (partialVersion, error) {
+	var p partialVersion
+
+	if s == "" {
+		return p, fmt.Errorf("%w: empty version", ErrInvalidVersion)
+	}
+
+	components := strings.Split(s, ".")
+	if len(components) > 3 {
+		return p, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+	}
+
+	var numbers [3]int
+	for i, component := range components {
+		if component == "x" || component == "X" || component == "*" {
+			// Nothing may follow a wildcard
+			if i != len(components)-1 {
+				return p, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+			}
+			p.wildcard = true
+			break
+		}
+
+		n, err := strconv.Atoi(component)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+		}
+		numbers[i] = n
+		p.parts++
+	}
+
+	p.v = Version{numbers[0], numbers[1], numbers[2]}
+
+	return p, nil
+}
+
+// next returns the lowest version that no longer has this partial version as its prefix.
+func (p partialVersion) next() Version {
+	switch p.parts {
+	case 0:
+		// Nothing is above a lone wildcard
+		return Version{int(^uint(0) >> 1), 0, 0}
+	case 1:
+		return Version{p.v.Major + 1, 0, 0}
+	case 2:
+		return Version{p.v.Major, p.v.Minor + 1, 0}
+	}
+	return Version{p.v.Major, p.v.Minor, p.v.Patch + 1}
+}
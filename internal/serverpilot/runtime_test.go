@@ -0,0 +1,145 @@
+package serverpilot
+
+import (
+	"gotest.tools/v3/assert"
+	"testing"
+)
+
+func TestRuntime(t *testing.T) {
+	t.Run("it should parse the version of a runtime", func(t *testing.T) {
+		var tests = []struct {
+			runtime Runtime
+			want    Version
+		}{
+			{"php7.4", Version{7, 4, 0}},
+			{"php8.10", Version{8, 10, 0}},
+			{"php8.2.1", Version{8, 2, 1}},
+		}
+
+		for _, tt := range tests {
+			t.Run(string(tt.runtime), func(t *testing.T) {
+				got, err := tt.runtime.Version()
+
+				assert.NilError(t, err)
+				assert.Equal(t, got, tt.want)
+			})
+		}
+	})
+
+	t.Run("it should return an error for unknown or non-php runtimes", func(t *testing.T) {
+		for _, runtime := range []Runtime{"", "7.4", "nodejs18", "php", "phpx.y", "php8.2.1.0"} {
+			t.Run(string(runtime), func(t *testing.T) {
+				_, err := runtime.Version()
+
+				assert.ErrorIs(t, err, ErrInvalidRuntime)
+			})
+		}
+	})
+
+	t.Run("it should compare versions numerically", func(t *testing.T) {
+		var tests = []struct {
+			a, b Version
+			want int
+		}{
+			{Version{8, 2, 0}, Version{8, 10, 0}, -1},
+			{Version{8, 10, 0}, Version{8, 2, 0}, 1},
+			{Version{8, 2, 0}, Version{8, 2, 0}, 0},
+			{Version{7, 4, 33}, Version{8, 0, 0}, -1},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.a.String()+" vs "+tt.b.String(), func(t *testing.T) {
+				assert.Equal(t, tt.a.Compare(tt.b), tt.want)
+			})
+		}
+	})
+
+	t.Run("it should match versions against a range expression", func(t *testing.T) {
+		var tests = []struct {
+			expr    string
+			version Version
+			want    bool
+		}{
+			{"", Version{5, 6, 0}, true},
+			{">=7.4 <8.1", Version{7, 4, 0}, true},
+			{">=7.4 <8.1", Version{8, 0, 0}, true},
+			{">=7.4 <8.1", Version{8, 1, 0}, false},
+			{">= php7.4 < php8.1", Version{7, 3, 0}, false},
+			{"8.x", Version{8, 3, 0}, true},
+			{"8.x", Version{9, 0, 0}, false},
+			{"8", Version{8, 10, 0}, true},
+			{"8.2", Version{8, 2, 5}, true},
+			{"8.2", Version{8, 10, 0}, false},
+			{"=8.2.1", Version{8, 2, 1}, true},
+			{"=8.2.1", Version{8, 2, 2}, false},
+			{">8.2", Version{8, 2, 9}, false},
+			{">8.2", Version{8, 10, 0}, true},
+			{"<=8.0", Version{8, 0, 30}, true},
+			{"<=8.0", Version{8, 1, 0}, false},
+			{">=8.0 !=8.1.0", Version{8, 1, 0}, false},
+			{"7.x || >=8.2", Version{7, 4, 0}, true},
+			{"7.x || >=8.2", Version{8, 1, 0}, false},
+			{"7.x || >=8.2", Version{8, 2, 0}, true},
+			{"*", Version{5, 6, 0}, true},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.expr+" "+tt.version.String(), func(t *testing.T) {
+				r, err := ParseRuntimeRange(tt.expr)
+
+				assert.NilError(t, err)
+				assert.Equal(t, r.Contains(tt.version), tt.want)
+			})
+		}
+	})
+
+	t.Run("it should return an error for an invalid range expression", func(t *testing.T) {
+		for _, expr := range []string{">=", "8.x.1", "nodejs", ">=7.4 ||", "!=8.1", "~8.1"} {
+			t.Run(expr, func(t *testing.T) {
+				_, err := ParseRuntimeRange(expr)
+
+				assert.ErrorIs(t, err, ErrInvalidRuntimeRange)
+			})
+		}
+	})
+
+	t.Run("it should create an inclusive range from a min and max runtime", func(t *testing.T) {
+		r, err := RuntimeRangeFromBounds("php7.4", "php8.0")
+
+		assert.NilError(t, err)
+		assert.Equal(t, r.Contains(Version{7, 3, 0}), false)
+		assert.Equal(t, r.Contains(Version{7, 4, 0}), true)
+		assert.Equal(t, r.Contains(Version{8, 0, 0}), true)
+		assert.Equal(t, r.Contains(Version{8, 1, 0}), false)
+	})
+
+	t.Run("it should return an error when supplied an invalid min or max runtime", func(t *testing.T) {
+		var tests = []struct {
+			name     string
+			min, max Runtime
+		}{
+			{"invalid min runtime", "7.4", "php8.2"},
+			{"invalid max runtime", "php7.4", "8.2"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := RuntimeRangeFromBounds(tt.min, tt.max)
+
+				assert.ErrorIs(t, err, ErrInvalidRuntime)
+			})
+		}
+	})
+
+	t.Run("it should intersect two ranges", func(t *testing.T) {
+		a, _ := ParseRuntimeRange("7.x || 8.x")
+		b, _ := ParseRuntimeRange(">=7.4 <8.1")
+
+		r := a.Intersect(b)
+
+		assert.Equal(t, r.Contains(Version{7, 3, 0}), false)
+		assert.Equal(t, r.Contains(Version{7, 4, 0}), true)
+		assert.Equal(t, r.Contains(Version{8, 0, 0}), true)
+		assert.Equal(t, r.Contains(Version{8, 1, 0}), false)
+	})
+}
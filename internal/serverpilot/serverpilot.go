@@ -3,13 +3,17 @@ package serverpilot
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"net"
+	"strconv"
 	"time"
 )
 
 var (
-	ErrInvalidRuntime    = errors.New("invalid runtime")
-	ErrInvalidDateString = errors.New("invalid date string")
+	ErrInvalidRuntime      = errors.New("invalid runtime")
+	ErrInvalidVersion      = errors.New("invalid version")
+	ErrInvalidRuntimeRange = errors.New("invalid runtime range")
+	ErrInvalidDateString   = errors.New("invalid date string")
+	ErrInvalidRelativeDate = errors.New("invalid relative date")
 )
 
 type Credentials struct {
@@ -21,21 +25,63 @@ type App struct {
 	Id          string      `json:"id"`
 	Name        string      `json:"name"`
 	Serverid    string      `json:"serverid"`
+	Sysuserid   string      `json:"sysuserid"`
 	Runtime     Runtime     `json:"runtime"`
 	Domains     []string    `json:"domains"`
 	Datecreated DateCreated `json:"datecreated"`
 }
 
 type Server struct {
-	Id          string      `json:"id"`
-	Name        string      `json:"name"`
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Ipaddress is the address the server last connected from, which is an IPv6 address for IPv6-only servers. The
+	// API only reports this one address, so the IPv6 address of a dual-stack server isn't known.
 	Ipaddress   string      `json:"lastaddress"`
 	Datecreated DateCreated `json:"datecreated"`
 }
 
+// IPv4 returns the server's IPv4 address, or an empty string if it doesn't have one.
+func (s Server) IPv4() string {
+	return s.addressOf(false)
+}
+
+// IPv6 returns the server's IPv6 address, or an empty string if it doesn't have one (or it isn't known).
+func (s Server) IPv6() string {
+	return s.addressOf(true)
+}
+
+func (s Server) addressOf(v6 bool) string {
+	ip := net.ParseIP(s.Ipaddress)
+	if ip != nil && (ip.To4() == nil) == v6 {
+		return s.Ipaddress
+	}
+	return ""
+}
+
+type Sysuser struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Serverid string `json:"serverid"`
+}
+
+type DatabaseUser struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Database struct {
+	Id       string       `json:"id"`
+	Name     string       `json:"name"`
+	Appid    string       `json:"appid"`
+	Serverid string       `json:"serverid"`
+	User     DatabaseUser `json:"user"`
+}
+
 type AppServer struct {
 	App
 	Server Server
+	// Account is the name of the profile the app was fetched with. It is empty when only one account is used.
+	Account string
 }
 
 type AppResponse struct {
@@ -46,16 +92,12 @@ type ServerResponse struct {
 	Data []Server `json:"data"`
 }
 
-type Runtime string
-
-func (r Runtime) Version() (string, error) {
-	// Ensure the runtime is prefixed with "php".
-	if !strings.HasPrefix(string(r), "php") {
-		return "", fmt.Errorf("%w: %s", ErrInvalidRuntime, r)
-	}
+type SysuserResponse struct {
+	Data []Sysuser `json:"data"`
+}
 
-	// Remove the "php" prefix.
-	return string(r[3:]), nil
+type DatabaseResponse struct {
+	Data []Database `json:"data"`
 }
 
 type DateCreated int64
@@ -81,3 +123,38 @@ func DateCreatedFromDate(date string) (DateCreated, error) {
 	t, _ := time.Parse(time.RFC3339, date)
 	return DateCreated(t.Unix()), nil
 }
+
+// DateCreatedFromRelative converts a relative age such as "90d" into the date that long before now. The supported
+// units are h (hours), d (days), w (weeks), m (months) and y (years). An empty string is a zero value.
+func DateCreatedFromRelative(age string, now time.Time) (DateCreated, error) {
+	if age == "" {
+		return DateCreated(0), nil
+	}
+
+	if len(age) < 2 {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidRelativeDate, age)
+	}
+
+	n, err := strconv.Atoi(age[:len(age)-1])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidRelativeDate, age)
+	}
+
+	var t time.Time
+	switch age[len(age)-1] {
+	case 'h':
+		t = now.Add(-time.Duration(n) * time.Hour)
+	case 'd':
+		t = now.AddDate(0, 0, -n)
+	case 'w':
+		t = now.AddDate(0, 0, -7*n)
+	case 'm':
+		t = now.AddDate(0, -n, 0)
+	case 'y':
+		t = now.AddDate(-n, 0, 0)
+	default:
+		return 0, fmt.Errorf("%w: %s (units are h, d, w, m or y)", ErrInvalidRelativeDate, age)
+	}
+
+	return DateCreated(t.Unix()), nil
+}
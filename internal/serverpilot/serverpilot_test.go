@@ -0,0 +1,98 @@
+package serverpilot
+
+import (
+	"encoding/json"
+	"gotest.tools/v3/assert"
+	"testing"
+	"time"
+)
+
+func TestDateCreated(t *testing.T) {
+	now := time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	t.Run("it should convert a relative age into a date", func(t *testing.T) {
+		var tests = []struct {
+			age  string
+			want time.Time
+		}{
+			{"12h", time.Date(2023, 6, 15, 0, 0, 0, 0, time.UTC)},
+			{"90d", time.Date(2023, 3, 17, 12, 0, 0, 0, time.UTC)},
+			{"2w", time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)},
+			{"6m", time.Date(2022, 12, 15, 12, 0, 0, 0, time.UTC)},
+			{"2y", time.Date(2021, 6, 15, 12, 0, 0, 0, time.UTC)},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.age, func(t *testing.T) {
+				got, err := DateCreatedFromRelative(tt.age, now)
+
+				assert.NilError(t, err)
+				assert.Equal(t, got, DateCreated(tt.want.Unix()))
+			})
+		}
+	})
+
+	t.Run("it should use a zero value for an empty relative age", func(t *testing.T) {
+		got, err := DateCreatedFromRelative("", now)
+
+		assert.NilError(t, err)
+		assert.Equal(t, got, DateCreated(0))
+	})
+
+	t.Run("it should return an error for an invalid relative age", func(t *testing.T) {
+		for _, age := range []string{"d", "90", "-1d", "2 years", "3x"} {
+			t.Run(age, func(t *testing.T) {
+				_, err := DateCreatedFromRelative(age, now)
+
+				assert.ErrorIs(t, err, ErrInvalidRelativeDate)
+			})
+		}
+	})
+}
+
+func TestServer(t *testing.T) {
+	t.Run("it should return the address of each family", func(t *testing.T) {
+		var tests = []struct {
+			name     string
+			server   Server
+			wantIPv4 string
+			wantIPv6 string
+		}{
+			{"ipv4 only", Server{Ipaddress: "127.0.0.1"}, "127.0.0.1", ""},
+			{"ipv6 only", Server{Ipaddress: "2001:db8::1"}, "", "2001:db8::1"},
+			{"no address", Server{}, "", ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.server.IPv4(), tt.wantIPv4)
+				assert.Equal(t, tt.server.IPv6(), tt.wantIPv6)
+			})
+		}
+	})
+
+	t.Run("it should decode a server returned by the API", func(t *testing.T) {
+		var tests = []struct {
+			name     string
+			body     string
+			wantIPv4 string
+			wantIPv6 string
+		}{
+			{"ipv4", `{"id":"FqHWrrcUfRI18F0l","name":"www1","lastaddress":"1.2.3.4","datecreated":1403130552}`, "1.2.3.4", ""},
+			{"ipv6", `{"id":"FqHWrrcUfRI18F0l","name":"www1","lastaddress":"2001:db8::1","datecreated":1403130552}`, "", "2001:db8::1"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var got Server
+				assert.NilError(t, json.Unmarshal([]byte(tt.body), &got))
+
+				assert.Equal(t, got.Id, "FqHWrrcUfRI18F0l")
+				assert.Equal(t, got.Name, "www1")
+				assert.Equal(t, got.Datecreated, DateCreated(1403130552))
+				assert.Equal(t, got.IPv4(), tt.wantIPv4)
+				assert.Equal(t, got.IPv6(), tt.wantIPv6)
+			})
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package servers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,10 +15,10 @@ var (
 	ErrInvalidJson    = errors.New("error while decoding json")
 )
 
-func GetServers(c filter.HttpClient) ([]serverpilot.Server, error) {
-	resp, err := c.Get("https://api.serverpilot.io/v1/servers")
+func GetServers(ctx context.Context, c filter.HttpClient) ([]serverpilot.Server, error) {
+	resp, err := c.Get(ctx, "/servers")
 	if err != nil {
-		return nil, fmt.Errorf("%w: %s", ErrInvalidRequest, err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
 	}
 
 	// Transform the JSON response into a slice of App structs.
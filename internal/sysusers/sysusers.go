@@ -0,0 +1,34 @@
+package sysusers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/filter"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"strings"
+)
+
+var (
+	ErrInvalidRequest = errors.New("error while making request")
+	ErrInvalidJson    = errors.New("error while decoding json")
+)
+
+func GetSysusers(ctx context.Context, c filter.HttpClient) ([]serverpilot.Sysuser, error) {
+	resp, err := c.Get(ctx, "/sysusers")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+
+	// Transform the JSON response into a slice of Sysuser structs.
+	var sysuserResponse serverpilot.SysuserResponse
+
+	decoder := json.NewDecoder(strings.NewReader(resp))
+	err = decoder.Decode(&sysuserResponse)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidJson, err)
+	}
+
+	return sysuserResponse.Data, nil
+}
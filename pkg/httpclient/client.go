@@ -0,0 +1,185 @@
+// Package httpclient makes HTTP requests through the cache, rate limiter and retries used by the ServerPilot and
+// Cloudflare clients, so other API clients can share them.
+//
+// GET responses are cached on disk, and revalidated with their ETag or Last-Modified once they expire. Requests to
+// each host are rate limited, and requests that fail with a network error, 5xx or 429 are retried.
+//
+//	c := httpclient.NewClient(httpclient.WithLogger(logger))
+//
+//	body, err := c.Get(ctx, "https://api.example.com/v1/things", map[string]string{"Authorization": "Bearer " + token})
+//
+// This package follows semantic versioning: exported identifiers will not be removed or changed incompatibly
+// within a major version.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"github.com/jfortunato/serverpilot-tools/internal/http"
+	"io"
+	"log"
+	nethttp "net/http"
+	"time"
+)
+
+var (
+	// ErrCouldNotMakeRequest is returned when a request can't be made, such as after a network error.
+	ErrCouldNotMakeRequest = http.ErrCouldNotMakeRequest
+	// ErrCouldNotCache is returned when a response was fetched but couldn't be cached.
+	ErrCouldNotCache = http.ErrCouldNotCache
+)
+
+// Hosts of the APIs the ServerPilot and Cloudflare clients make requests to, for use with SetRateLimit.
+const (
+	ServerPilotHost = http.ServerPilotHost
+	CloudflareHost  = http.CloudflareHost
+)
+
+// CacheMode controls whether responses are read from and written to the cache.
+type CacheMode int
+
+const (
+	// CacheOn reads responses from the cache, and caches new responses.
+	CacheOn CacheMode = iota
+	// CacheRefresh always makes the request, and caches the new response.
+	CacheRefresh
+	// CacheOff always makes the request, and never caches the response.
+	CacheOff
+)
+
+// CacheSettings configure how responses are cached. The zero value caches responses for 24 hours under the user's
+// cache directory, up to 50MB.
+type CacheSettings struct {
+	Mode    CacheMode
+	Dir     string
+	TTL     time.Duration
+	MaxSize int64
+}
+
+// RetrySettings configure how failed requests are retried. Network errors, 5xx responses and 429 Too Many Requests
+// are retried, waiting longer after each attempt. Other 4xx responses are never retried. A DELETE that finds the
+// resource gone when it is retried after a network error or 5xx response is treated as a success. The zero value
+// never retries.
+type RetrySettings struct {
+	MaxRetries int
+	// BaseDelay is the delay before the first retry, which doubles for each retry after that, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetrySettings retry 3 times, starting after half a second.
+var DefaultRetrySettings = RetrySettings(http.DefaultRetrySettings)
+
+// RateLimit is the number of requests per second that can be made to a host. Up to Burst requests can be made at once
+// before they are spread out at the rate.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// SetRateLimit changes the rate limit of a host. Rate limits are shared by every client, including the ServerPilot
+// and Cloudflare clients. Hosts without their own rate limit are limited to 5 requests per second.
+func SetRateLimit(host string, limit RateLimit) {
+	http.SetRateLimit(host, http.RateLimit(limit))
+}
+
+// StatusError is returned when a request completes with a status other than 2xx or 304. The Body is kept so callers
+// can parse the error message sent by the API.
+type StatusError struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return (*http.StatusError)(e).Error()
+}
+
+// Request is a request made with Do. The Method defaults to GET when empty.
+type Request struct {
+	Method  string
+	Url     string
+	Headers map[string]string
+}
+
+// Client makes rate limited requests, caching the responses to GET requests. Create one with NewClient. It is safe
+// for concurrent use.
+type Client struct {
+	c        *http.Client
+	logger   *log.Logger
+	settings http.ClientSettings
+}
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithLogger logs each request, and whether it was served from the cache. Logging is disabled by default.
+func WithLogger(l *log.Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// WithCache configures how responses are cached.
+func WithCache(s CacheSettings) Option {
+	return func(c *Client) {
+		c.settings.Cache = http.CacheSettings{Mode: http.CacheMode(s.Mode), Dir: s.Dir, TTL: s.TTL, MaxSize: s.MaxSize}
+	}
+}
+
+// WithRetry configures how failed requests are retried. By default, requests are retried with DefaultRetrySettings.
+func WithRetry(s RetrySettings) Option {
+	return func(c *Client) {
+		c.settings.Retry = http.RetrySettings(s)
+	}
+}
+
+// WithTransport makes the requests with the given transport, instead of http.DefaultTransport.
+func WithTransport(t nethttp.RoundTripper) Option {
+	return func(c *Client) {
+		c.settings.Transport = t
+	}
+}
+
+// NewClient creates a Client.
+func NewClient(opts ...Option) *Client {
+	c := &Client{logger: log.New(io.Discard, "", 0), settings: http.ClientSettings{Retry: http.DefaultRetrySettings}}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.c = http.NewClient(c.logger, c.settings)
+
+	return c
+}
+
+// Get makes a GET request to the url with the given headers, and returns the response body. A cached response is
+// returned while it is fresh. Authorization headers are part of the cache key, so responses are never shared between
+// credentials.
+func (c *Client) Get(ctx context.Context, url string, headers map[string]string) (string, error) {
+	body, err := c.c.GetFromCacheOrFetchWithRateLimit(ctx, http.Request{Url: url, Headers: headers})
+	return body, convertError(err)
+}
+
+// Do makes the request and returns the response body, without reading or writing the cache. Use it for requests
+// that modify data, followed by Invalidate for any cached responses it makes out of date.
+func (c *Client) Do(ctx context.Context, req Request) (string, error) {
+	body, err := c.c.FetchWithRateLimit(ctx, http.Request{Url: req.Url, Headers: req.Headers, Method: req.Method})
+	return body, convertError(err)
+}
+
+// Invalidate removes the cached response to a GET request, so the next Get fetches it again.
+func (c *Client) Invalidate(url string, headers map[string]string) {
+	c.c.Invalidate(http.Request{Url: url, Headers: headers})
+}
+
+// convertError replaces an unsuccessful response with a StatusError. Other errors are returned unchanged.
+func convertError(err error) error {
+	var statusErr *http.StatusError
+	if !errors.As(err, &statusErr) {
+		return err
+	}
+	return (*StatusError)(statusErr)
+}
@@ -0,0 +1,84 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/http"
+	"gotest.tools/v3/assert"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	t.Run("it should only request a cached url once", func(t *testing.T) {
+		server, requests := newCountingServer(t, nethttp.StatusOK)
+		c := NewClient(WithCache(CacheSettings{Dir: t.TempDir()}))
+
+		first, err := c.Get(context.Background(), server.URL+"/things", nil)
+		assert.NilError(t, err)
+		second, err := c.Get(context.Background(), server.URL+"/things", nil)
+		assert.NilError(t, err)
+
+		assert.Equal(t, first, "things")
+		assert.Equal(t, second, "things")
+		assert.Equal(t, *requests, 1)
+	})
+
+	t.Run("it should request the url again once it is invalidated", func(t *testing.T) {
+		server, requests := newCountingServer(t, nethttp.StatusOK)
+		c := NewClient(WithCache(CacheSettings{Dir: t.TempDir()}))
+
+		c.Get(context.Background(), server.URL+"/things", nil)
+		c.Invalidate(server.URL+"/things", nil)
+		c.Get(context.Background(), server.URL+"/things", nil)
+
+		assert.Equal(t, *requests, 2)
+	})
+
+	t.Run("it should never cache requests made with Do", func(t *testing.T) {
+		server, requests := newCountingServer(t, nethttp.StatusOK)
+		c := NewClient(WithCache(CacheSettings{Dir: t.TempDir()}))
+
+		c.Do(context.Background(), Request{Method: nethttp.MethodPost, Url: server.URL + "/things"})
+		c.Do(context.Background(), Request{Method: nethttp.MethodPost, Url: server.URL + "/things"})
+
+		assert.Equal(t, *requests, 2)
+	})
+
+	t.Run("it should return a StatusError for an unsuccessful response", func(t *testing.T) {
+		server, _ := newCountingServer(t, nethttp.StatusNotFound)
+		c := NewClient(WithCache(CacheSettings{Mode: CacheOff}), WithRetry(RetrySettings{}))
+
+		_, err := c.Get(context.Background(), server.URL+"/things", nil)
+
+		var statusErr *StatusError
+		assert.Assert(t, errors.As(err, &statusErr))
+		assert.Equal(t, statusErr.StatusCode, nethttp.StatusNotFound)
+		assert.Equal(t, statusErr.Body, "things")
+		assert.Error(t, err, "GET "+server.URL+"/things: 404 Not Found")
+	})
+
+	t.Run("it should convert an unsuccessful response that has been wrapped", func(t *testing.T) {
+		err := convertError(fmt.Errorf("could not get things: %w", &http.StatusError{Method: "GET", Url: "/things", StatusCode: nethttp.StatusNotFound}))
+
+		var statusErr *StatusError
+		assert.Assert(t, errors.As(err, &statusErr))
+		assert.Equal(t, statusErr.StatusCode, nethttp.StatusNotFound)
+	})
+}
+
+// newCountingServer returns a server that responds to every request with the status, and the number of requests it
+// has received.
+func newCountingServer(t *testing.T, status int) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		requests++
+		w.WriteHeader(status)
+		w.Write([]byte("things"))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
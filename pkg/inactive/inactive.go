@@ -0,0 +1,435 @@
+// Package inactive finds ServerPilot app domains whose DNS records no longer point at the server the app lives on.
+//
+// Domains behind Cloudflare are proxied, so their A records can't be compared with the server's IP address. For
+// those, the real records are looked up with the Cloudflare API using the credentials from a CredentialsProvider.
+// Domains without credentials are reported as UNKNOWN.
+//
+//	checker := inactive.NewChecker(inactive.WithCredentialsProvider(provider))
+//
+//	statuses, err := checker.Check(ctx, apps)
+//	needsAttention := inactive.FilterInactive(statuses, false)
+//
+// This package follows semantic versioning: exported identifiers will not be removed or changed incompatibly
+// within a major version.
+package inactive
+
+import (
+	"context"
+	"github.com/jfortunato/serverpilot-tools/internal/convert"
+	"github.com/jfortunato/serverpilot-tools/internal/dns"
+	"github.com/jfortunato/serverpilot-tools/internal/http"
+	"github.com/jfortunato/serverpilot-tools/pkg/serverpilot"
+	"io"
+	"log"
+	"net"
+	nethttp "net/http"
+)
+
+const (
+	// OK means the domain resolves to the app's server.
+	OK int = iota
+	// INACTIVE means the domain resolves somewhere other than the app's server.
+	INACTIVE
+	// UNKNOWN means the domain couldn't be resolved, is behind Cloudflare without credentials, or its records couldn't
+	// be compared with the server's address (see NotComparable).
+	UNKNOWN
+	// PARTIAL means the domain resolves to the app's server, and to other addresses too.
+	PARTIAL
+)
+
+// DefaultCloudflareBaseUrl is the base url of the Cloudflare API.
+const DefaultCloudflareBaseUrl = dns.DefaultCloudflareBaseUrl
+
+// DomainStatus is the status (OK, INACTIVE, PARTIAL or UNKNOWN) of a single app domain, along with how its A and
+// AAAA records compare with the server's addresses.
+type DomainStatus struct {
+	AppId      string
+	Domain     string
+	ServerName string
+	Status     int
+	AddressMatches
+	// Trace is the steps taken to determine the status, and is only recorded WithExplain.
+	Trace *Trace
+}
+
+// AppStatus is the status of an app, rolled up from the statuses of its domains: INACTIVE when all of them are
+// inactive, PARTIAL when only some of them are, UNKNOWN when any of the others couldn't be checked, and OK otherwise.
+type AppStatus struct {
+	serverpilot.AppServer
+	Status int
+	// DomainsByStatus are the app's checked domains, grouped by their status.
+	DomainsByStatus map[int][]string
+}
+
+// Trace is a step taken while checking a domain, along with the steps taken within it. Its String method renders
+// it as a tree.
+type Trace struct {
+	Step  string
+	Steps []*Trace
+}
+
+// String renders the trace as a tree.
+func (t *Trace) String() string {
+	return traceTo(t).String()
+}
+
+// FamilyMatch is how the records of one address family (IPv4 or IPv6) compare with the server's address.
+type FamilyMatch int
+
+const (
+	// NoRecords means the domain has no records of the family.
+	NoRecords FamilyMatch = iota
+	// Match means one of the records is the server's address.
+	Match
+	// Mismatch means none of the records are the server's address.
+	Mismatch
+	// NotComparable means the domain has records of the family, but the server's address of the family isn't known,
+	// such as the IPv6 address of a dual-stack server.
+	NotComparable
+)
+
+func (m FamilyMatch) String() string {
+	switch m {
+	case Match:
+		return "match"
+	case Mismatch:
+		return "mismatch"
+	case NotComparable:
+		return "not comparable"
+	}
+	return "none"
+}
+
+// AddressMatches is how a domain's A and AAAA records compare with the server's IPv4 and IPv6 addresses.
+type AddressMatches struct {
+	IPv4 FamilyMatch
+	IPv6 FamilyMatch
+	// ForeignIps are the resolved addresses that aren't the server's.
+	ForeignIps []string
+}
+
+// CloudflareAccount is a set of domains that share the same Cloudflare nameservers, and therefore the same account.
+type CloudflareAccount struct {
+	Nameservers []string
+	Domains     []string
+	// Credentials are set by a CredentialsProvider for the accounts that should be checked with the Cloudflare API.
+	Credentials *CloudflareCredentials
+}
+
+// CloudflareCredentials are used to authenticate with the Cloudflare API.
+type CloudflareCredentials struct {
+	Email    string
+	ApiToken string
+}
+
+// CredentialsProvider is given every Cloudflare account that was detected, and returns the accounts with Credentials
+// set for the ones that should be checked using the Cloudflare API.
+type CredentialsProvider func(accounts []CloudflareAccount) []CloudflareAccount
+
+// Prompter asks the user a question, and returns their response, or the defaultResponse when they don't give one.
+// When validResponses are given, the response should be one of them.
+type Prompter interface {
+	Prompt(msg, defaultResponse string, validResponses []string) string
+}
+
+// IPLookupFunc looks up the IP addresses of a host.
+type IPLookupFunc func(ctx context.Context, host string) ([]net.IP, error)
+
+// NSLookupFunc looks up the nameservers of a host.
+type NSLookupFunc func(ctx context.Context, host string) ([]*net.NS, error)
+
+// Progress is notified as each domain is processed.
+type Progress interface {
+	Tick()
+	Done()
+}
+
+// Checker checks the DNS records of app domains. Create one with NewChecker.
+type Checker struct {
+	logger   *log.Logger
+	provider CredentialsProvider
+	prompter Prompter
+	progress func(stage string, total int) Progress
+	settings http.ClientSettings
+	ipLookup IPLookupFunc
+	nsLookup NSLookupFunc
+	explain  bool
+}
+
+// Option configures a Checker.
+type Option func(c *Checker)
+
+// WithLogger logs each lookup. Logging is disabled by default.
+func WithLogger(l *log.Logger) Option {
+	return func(c *Checker) {
+		c.logger = l
+	}
+}
+
+// WithCredentialsProvider supplies the Cloudflare credentials. Without one, domains behind Cloudflare are UNKNOWN.
+func WithCredentialsProvider(p CredentialsProvider) Option {
+	return func(c *Checker) {
+		c.provider = p
+	}
+}
+
+// WithPrompter interactively asks for the credentials of each Cloudflare account. It is ignored when a
+// CredentialsProvider is given.
+func WithPrompter(p Prompter) Option {
+	return func(c *Checker) {
+		c.prompter = p
+	}
+}
+
+// WithProgress reports progress for each stage of the check ("Evaluating domains" and "Checking domains").
+func WithProgress(f func(stage string, total int) Progress) Option {
+	return func(c *Checker) {
+		c.progress = f
+	}
+}
+
+// WithCache configures how Cloudflare API responses are cached.
+func WithCache(s serverpilot.CacheSettings) Option {
+	return func(c *Checker) {
+		c.settings.Cache = http.CacheSettings{Mode: http.CacheMode(s.Mode), Dir: s.Dir, TTL: s.TTL, MaxSize: s.MaxSize}
+	}
+}
+
+// WithRetry configures how failed Cloudflare API requests are retried.
+func WithRetry(s serverpilot.RetrySettings) Option {
+	return func(c *Checker) {
+		c.settings.Retry = http.RetrySettings(s)
+	}
+}
+
+// WithTransport makes the Cloudflare API requests with the given transport, instead of http.DefaultTransport.
+func WithTransport(t nethttp.RoundTripper) Option {
+	return func(c *Checker) {
+		c.settings.Transport = t
+	}
+}
+
+// WithCloudflareUrl makes the Cloudflare API requests to a base url other than DefaultCloudflareBaseUrl, such as a
+// local stand-in API.
+func WithCloudflareUrl(url string) Option {
+	return func(c *Checker) {
+		c.settings.BaseUrl = url
+	}
+}
+
+// WithLookups looks up IP addresses and nameservers with the given funcs, instead of the system resolver. Either can
+// be nil to keep using the system resolver for it.
+func WithLookups(ip IPLookupFunc, ns NSLookupFunc) Option {
+	return func(c *Checker) {
+		c.ipLookup = ip
+		c.nsLookup = ns
+	}
+}
+
+// WithExplain records the steps taken to check each domain, such as the nameservers found, the Cloudflare records
+// matched and the addresses compared, in the Trace of its DomainStatus.
+func WithExplain() Option {
+	return func(c *Checker) {
+		c.explain = true
+	}
+}
+
+// NewChecker creates a Checker.
+func NewChecker(opts ...Option) *Checker {
+	c := &Checker{
+		logger:   log.New(io.Discard, "", 0),
+		progress: func(stage string, total int) Progress { return noProgress{} },
+		settings: http.ClientSettings{Retry: http.DefaultRetrySettings},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Check returns the status of every domain of the given apps. If the context is cancelled, it returns the statuses of
+// the domains that were checked so far, along with the context's error.
+func (c *Checker) Check(ctx context.Context, apps []serverpilot.AppServer) ([]DomainStatus, error) {
+	cfChecker := dns.NewCloudflareCredentialsChecker(c.logger, c.prompter, dns.NsLookupFunc(c.nsLookup))
+	dnsChecker := dns.NewDnsChecker(dns.NewResolver(nil, cfChecker, dns.IpLookupFunc(c.ipLookup), c.logger, c.settings), cfChecker)
+
+	var domains []string
+	for _, app := range apps {
+		domains = append(domains, app.Domains...)
+	}
+
+	p := c.progress("Evaluating domains", len(domains))
+	unresolved, err := dnsChecker.EvaluateDomains(ctx, p, domains)
+	p.Done()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c.provider != nil:
+		unresolved = cfChecker.AssignCredentials(unresolved, c.provider.adapt())
+	case c.prompter != nil:
+		unresolved = cfChecker.PromptForCredentials(unresolved)
+	}
+
+	if c.explain {
+		ctx = dns.WithTracing(ctx)
+	}
+
+	p = c.progress("Checking domains", len(unresolved))
+	statuses, err := dnsChecker.GetAppDomainStatuses(ctx, p, unresolved, convert.AppServers(apps))
+	p.Done()
+
+	return convert.All(statuses, domainStatusFrom), err
+}
+
+// FilterInactive returns only the INACTIVE and PARTIAL domains, and optionally the UNKNOWN ones. PARTIAL domains still
+// resolve to the server, along with other addresses, so callers that only want the domains that have moved away
+// entirely should also check for a Status of INACTIVE.
+func FilterInactive(statuses []DomainStatus, includeUnknown bool) []DomainStatus {
+	var results []DomainStatus
+	for _, status := range statuses {
+		if status.Status == INACTIVE || status.Status == PARTIAL || (includeUnknown && status.Status == UNKNOWN) {
+			results = append(results, status)
+		}
+	}
+	return results
+}
+
+// ByApp rolls up the domain statuses into the status of each app, leaving out the apps without any checked domains.
+func ByApp(statuses []DomainStatus, apps []serverpilot.AppServer) []AppStatus {
+	rolledUp := dns.RollUpByApp(convert.All(statuses, domainStatusTo), convert.AppServers(apps))
+
+	// The rolled up apps are in the same order as the apps, so each one is matched with the app it came from
+	var results []AppStatus
+	i := 0
+	for _, app := range rolledUp {
+		for apps[i].Id != app.Id {
+			i++
+		}
+		results = append(results, AppStatus{apps[i], statusFrom(app.Status), domainsByStatusFrom(app.DomainsByStatus)})
+		i++
+	}
+	return results
+}
+
+// FilterInactiveApps returns only the INACTIVE and PARTIAL apps, and optionally the UNKNOWN ones. Like FilterInactive,
+// callers that only want the apps that have moved away entirely should also check for a Status of INACTIVE.
+func FilterInactiveApps(apps []AppStatus, includeUnknown bool) []AppStatus {
+	var results []AppStatus
+	for _, app := range apps {
+		if app.Status == INACTIVE || app.Status == PARTIAL || (includeUnknown && app.Status == UNKNOWN) {
+			results = append(results, app)
+		}
+	}
+	return results
+}
+
+type noProgress struct{}
+
+func (noProgress) Tick() {}
+func (noProgress) Done() {}
+
+// The checks are done with the internal types, which are converted into the exported ones here so that changes to the
+// internal types never change the exported ones.
+
+func (p CredentialsProvider) adapt() dns.CredentialsProvider {
+	return func(accounts []dns.NameserverDomains) []dns.NameserverDomains {
+		return convert.All(p(convert.All(accounts, accountFrom)), accountTo)
+	}
+}
+
+func accountFrom(a dns.NameserverDomains) CloudflareAccount {
+	account := CloudflareAccount{Nameservers: a.Nameservers, Domains: a.Domains}
+	if a.Credentials != nil {
+		account.Credentials = &CloudflareCredentials{a.Credentials.Email, a.Credentials.ApiToken}
+	}
+	return account
+}
+
+func accountTo(a CloudflareAccount) dns.NameserverDomains {
+	account := dns.NameserverDomains{Nameservers: a.Nameservers, Domains: a.Domains}
+	if a.Credentials != nil {
+		account.Credentials = &dns.Credentials{Email: a.Credentials.Email, ApiToken: a.Credentials.ApiToken}
+	}
+	return account
+}
+
+func domainStatusFrom(s dns.AppDomainStatus) DomainStatus {
+	return DomainStatus{
+		AppId:      s.AppId,
+		Domain:     s.Domain,
+		ServerName: s.ServerName,
+		Status:     statusFrom(s.Status),
+		AddressMatches: AddressMatches{
+			IPv4:       familyMatchFrom(s.IPv4),
+			IPv6:       familyMatchFrom(s.IPv6),
+			ForeignIps: s.ForeignIps,
+		},
+		Trace: traceFrom(s.Trace),
+	}
+}
+
+// domainStatusTo only converts what is needed to roll up the statuses by app.
+func domainStatusTo(s DomainStatus) dns.AppDomainStatus {
+	return dns.AppDomainStatus{AppId: s.AppId, Domain: s.Domain, ServerName: s.ServerName, Status: statusTo(s.Status)}
+}
+
+func domainsByStatusFrom(domains map[int][]string) map[int][]string {
+	results := make(map[int][]string, len(domains))
+	for status, d := range domains {
+		results[statusFrom(status)] = d
+	}
+	return results
+}
+
+var exportedStatuses = map[int]int{dns.OK: OK, dns.INACTIVE: INACTIVE, dns.UNKNOWN: UNKNOWN, dns.PARTIAL: PARTIAL}
+
+func statusFrom(status int) int {
+	return exportedStatuses[status]
+}
+
+func statusTo(status int) int {
+	for internal, s := range exportedStatuses {
+		if s == status {
+			return internal
+		}
+	}
+	return dns.UNKNOWN
+}
+
+func familyMatchFrom(m dns.FamilyMatch) FamilyMatch {
+	switch m {
+	case dns.Match:
+		return Match
+	case dns.Mismatch:
+		return Mismatch
+	case dns.NotComparable:
+		return NotComparable
+	}
+	return NoRecords
+}
+
+func traceFrom(t *dns.Trace) *Trace {
+	if t == nil {
+		return nil
+	}
+	result := &Trace{Step: t.Step}
+	for _, step := range t.Steps {
+		result.Steps = append(result.Steps, traceFrom(step))
+	}
+	return result
+}
+
+func traceTo(t *Trace) *dns.Trace {
+	if t == nil {
+		return nil
+	}
+	result := &dns.Trace{Step: t.Step}
+	for _, step := range t.Steps {
+		result.Steps = append(result.Steps, traceTo(step))
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package inactive
+
+import (
+	"context"
+	"github.com/jfortunato/serverpilot-tools/pkg/serverpilot"
+	"gotest.tools/v3/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestFilterInactive(t *testing.T) {
+	statuses := []DomainStatus{
+		{AppId: "1", Domain: "ok.com", Status: OK},
+		{AppId: "2", Domain: "inactive.com", Status: INACTIVE},
+		{AppId: "3", Domain: "partial.com", Status: PARTIAL},
+		{AppId: "4", Domain: "unknown.com", Status: UNKNOWN},
+	}
+
+	var tests = []struct {
+		name           string
+		includeUnknown bool
+		want           []DomainStatus
+	}{
+		{"it should only return inactive and partial domains", false, statuses[1:3]},
+		{"it should include unknown domains", true, statuses[1:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.DeepEqual(t, FilterInactive(statuses, tt.includeUnknown), tt.want)
+		})
+	}
+}
+
+func TestFilterInactiveApps(t *testing.T) {
+	apps := []AppStatus{
+		{Status: OK},
+		{Status: INACTIVE},
+		{Status: PARTIAL},
+		{Status: UNKNOWN},
+	}
+
+	assert.DeepEqual(t, FilterInactiveApps(apps, false), apps[1:3])
+	assert.DeepEqual(t, FilterInactiveApps(apps, true), apps[1:])
+}
+
+func TestByApp(t *testing.T) {
+	apps := []serverpilot.AppServer{
+		{App: serverpilot.App{Id: "1", Name: "blog"}, Account: "work"},
+		{App: serverpilot.App{Id: "2", Name: "shop"}},
+		{App: serverpilot.App{Id: "3", Name: "unchecked"}},
+	}
+	statuses := []DomainStatus{
+		{AppId: "1", Domain: "blog.com", Status: OK},
+		{AppId: "1", Domain: "old-blog.com", Status: INACTIVE},
+		{AppId: "2", Domain: "shop.com", Status: UNKNOWN},
+	}
+
+	got := ByApp(statuses, apps)
+
+	assert.DeepEqual(t, got, []AppStatus{
+		{AppServer: apps[0], Status: PARTIAL, DomainsByStatus: map[int][]string{OK: {"blog.com"}, INACTIVE: {"old-blog.com"}}},
+		{AppServer: apps[1], Status: UNKNOWN, DomainsByStatus: map[int][]string{UNKNOWN: {"shop.com"}}},
+	})
+}
+
+func TestExplain(t *testing.T) {
+	apps := []serverpilot.AppServer{
+		{App: serverpilot.App{Id: "1", Domains: []string{"example.com"}}, Server: serverpilot.Server{Name: "server1", Ipaddress: "127.0.0.1"}},
+	}
+	lookups := WithLookups(
+		func(ctx context.Context, host string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		},
+		func(ctx context.Context, host string) ([]*net.NS, error) {
+			return []*net.NS{{Host: "ns1.example.com."}}, nil
+		},
+	)
+
+	t.Run("it should record a trace of each domain with WithExplain", func(t *testing.T) {
+		statuses, err := NewChecker(lookups, WithExplain()).Check(context.Background(), apps)
+
+		assert.NilError(t, err)
+		assert.Equal(t, statuses[0].Status, INACTIVE)
+		assert.Assert(t, strings.Contains(statuses[0].Trace.String(), "looked up example.com: 10.0.0.1"))
+	})
+
+	t.Run("it should not record a trace by default", func(t *testing.T) {
+		statuses, err := NewChecker(lookups).Check(context.Background(), apps)
+
+		assert.NilError(t, err)
+		assert.Assert(t, statuses[0].Trace == nil)
+	})
+}
+
+func TestCredentialsProvider(t *testing.T) {
+	t.Run("it should give the provider each Cloudflare account", func(t *testing.T) {
+		apps := []serverpilot.AppServer{
+			{App: serverpilot.App{Id: "1", Domains: []string{"example.com"}}, Server: serverpilot.Server{Ipaddress: "127.0.0.1"}},
+		}
+		lookups := WithLookups(
+			func(ctx context.Context, host string) ([]net.IP, error) {
+				return []net.IP{net.ParseIP("104.16.0.1")}, nil
+			},
+			func(ctx context.Context, host string) ([]*net.NS, error) {
+				return []*net.NS{{Host: "bar.ns.cloudflare.com."}, {Host: "foo.ns.cloudflare.com."}}, nil
+			},
+		)
+		var got []CloudflareAccount
+		provider := WithCredentialsProvider(func(accounts []CloudflareAccount) []CloudflareAccount {
+			got = accounts
+			return accounts
+		})
+
+		statuses, err := NewChecker(lookups, provider).Check(context.Background(), apps)
+
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, []CloudflareAccount{{Nameservers: []string{"bar.ns.cloudflare.com", "foo.ns.cloudflare.com"}, Domains: []string{"example.com"}}})
+		assert.Equal(t, statuses[0].Status, UNKNOWN)
+	})
+}
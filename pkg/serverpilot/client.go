@@ -0,0 +1,169 @@
+package serverpilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jfortunato/serverpilot-tools/internal/databases"
+	"github.com/jfortunato/serverpilot-tools/internal/filter"
+	"github.com/jfortunato/serverpilot-tools/internal/http"
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"github.com/jfortunato/serverpilot-tools/internal/servers"
+	"github.com/jfortunato/serverpilot-tools/internal/sysusers"
+	"github.com/jfortunato/serverpilot-tools/pkg/httpclient"
+	"io"
+	"log"
+	nethttp "net/http"
+)
+
+// Client makes requests to the ServerPilot API. Create one with NewClient.
+type Client struct {
+	c        apiClient
+	logger   *log.Logger
+	settings http.ClientSettings
+}
+
+type apiClient interface {
+	Get(ctx context.Context, url string) (string, error)
+	Delete(ctx context.Context, url string) (string, error)
+}
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithLogger logs each request, and whether it was served from the cache. Logging is disabled by default.
+func WithLogger(l *log.Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// WithCache configures how responses are cached. By default, responses are cached for 24 hours under the user's
+// cache directory.
+func WithCache(s CacheSettings) Option {
+	return func(c *Client) {
+		c.settings.Cache = http.CacheSettings{Mode: http.CacheMode(s.Mode), Dir: s.Dir, TTL: s.TTL, MaxSize: s.MaxSize}
+	}
+}
+
+// WithRetry configures how failed requests are retried. By default, requests are retried 3 times.
+func WithRetry(s RetrySettings) Option {
+	return func(c *Client) {
+		c.settings.Retry = http.RetrySettings(s)
+	}
+}
+
+// WithTransport makes the requests with the given transport, instead of http.DefaultTransport.
+func WithTransport(t nethttp.RoundTripper) Option {
+	return func(c *Client) {
+		c.settings.Transport = t
+	}
+}
+
+// WithBaseUrl makes the requests to a base url other than DefaultBaseUrl, such as a local stand-in API.
+func WithBaseUrl(url string) Option {
+	return func(c *Client) {
+		c.settings.BaseUrl = url
+	}
+}
+
+// NewClient creates a Client that authenticates with the given ServerPilot client id and API key.
+func NewClient(clientId, apiKey string, opts ...Option) *Client {
+	c := &Client{logger: log.New(io.Discard, "", 0), settings: http.ClientSettings{Retry: http.DefaultRetrySettings}}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.c = serverpilot.NewClient(c.logger, clientId, apiKey, c.settings)
+
+	return c
+}
+
+// Get makes an authenticated GET request to an API url, or a path relative to the base url such as /apps, and returns
+// the raw response body.
+func (c *Client) Get(ctx context.Context, url string) (string, error) {
+	body, err := c.c.Get(ctx, url)
+	return body, convertError(err)
+}
+
+// Delete makes an authenticated DELETE request to an API url, or a path relative to the base url such as /apps/:id.
+// Deletions are never cached.
+func (c *Client) Delete(ctx context.Context, url string) (string, error) {
+	body, err := c.c.Delete(ctx, url)
+	return body, convertError(err)
+}
+
+// Apps returns every app in the account.
+func (c *Client) Apps(ctx context.Context) ([]App, error) {
+	return c.FilterApps(ctx, RuntimeRange{}, 0, 0)
+}
+
+// FilterApps returns the apps with a runtime in the given range, created within the given dates. A zero range or
+// zero date leaves that side unbounded.
+func (c *Client) FilterApps(ctx context.Context, runtimes RuntimeRange, createdAfter, createdBefore DateCreated) ([]App, error) {
+	apps, err := filter.FilterApps(ctx, c, runtimes.r, serverpilot.DateCreated(createdAfter), serverpilot.DateCreated(createdBefore))
+	return convertAll(apps, appFrom), err
+}
+
+// Servers returns every server in the account.
+func (c *Client) Servers(ctx context.Context) ([]Server, error) {
+	srvers, err := servers.GetServers(ctx, c)
+	return convertAll(srvers, serverFrom), err
+}
+
+// AppServers returns every app, joined with the server it lives on.
+func (c *Client) AppServers(ctx context.Context) ([]AppServer, error) {
+	srvers, err := c.Servers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting servers: %w", err)
+	}
+
+	apps, err := c.Apps(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting apps: %w", err)
+	}
+
+	return JoinAppServers(apps, srvers), nil
+}
+
+// Sysusers returns every system user in the account.
+func (c *Client) Sysusers(ctx context.Context) ([]Sysuser, error) {
+	users, err := sysusers.GetSysusers(ctx, c)
+	return convertAll(users, func(u serverpilot.Sysuser) Sysuser { return Sysuser(u) }), err
+}
+
+// Databases returns every database in the account.
+func (c *Client) Databases(ctx context.Context) ([]Database, error) {
+	dbs, err := databases.GetDatabases(ctx, c)
+	return convertAll(dbs, databaseFrom), err
+}
+
+// JoinAppServers adds the matching server to each app. Apps whose server can't be found get an empty Server.
+func JoinAppServers(apps []App, srvers []Server) []AppServer {
+	var appServers []AppServer
+	for _, app := range apps {
+		var server Server
+		for _, s := range srvers {
+			if s.Id == app.Serverid {
+				server = s
+				break
+			}
+		}
+		appServers = append(appServers, AppServer{App: app, Server: server})
+	}
+	return appServers
+}
+
+// convertError replaces an error response from the API with an APIError. Other errors are returned unchanged.
+func convertError(err error) error {
+	var apiErr *serverpilot.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	var statusErr *http.StatusError
+	errors.As(apiErr, &statusErr)
+
+	return &APIError{StatusCode: apiErr.StatusCode, Message: apiErr.Message, msg: apiErr.Error(), err: (*httpclient.StatusError)(statusErr)}
+}
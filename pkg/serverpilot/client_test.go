@@ -0,0 +1,71 @@
+package serverpilot
+
+import (
+	"context"
+	"errors"
+	"gotest.tools/v3/assert"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	t.Run("it should join apps with their servers", func(t *testing.T) {
+		c := &Client{c: &stubApiClient{map[string]string{
+			"/apps":    `{"data": [{"id": "a1", "name": "blog", "serverid": "s1", "runtime": "php8.2"}]}`,
+			"/servers": `{"data": [{"id": "s1", "name": "web-01"}]}`,
+		}}}
+
+		apps, err := c.AppServers(context.Background())
+
+		assert.NilError(t, err)
+		assert.Equal(t, len(apps), 1)
+		assert.Equal(t, apps[0].Name, "blog")
+		assert.Equal(t, apps[0].Server.Name, "web-01")
+	})
+
+	t.Run("it should filter apps by runtime", func(t *testing.T) {
+		c := &Client{c: &stubApiClient{map[string]string{
+			"/apps": `{"data": [{"id": "a1", "runtime": "php7.4"}, {"id": "a2", "runtime": "php8.2"}]}`,
+		}}}
+		runtimes, _ := ParseRuntimeRange(">=8")
+
+		apps, err := c.FilterApps(context.Background(), runtimes, 0, 0)
+
+		assert.NilError(t, err)
+		assert.Equal(t, len(apps), 1)
+		assert.Equal(t, apps[0].Id, "a2")
+	})
+
+	t.Run("it should return an APIError wrapping a StatusError for an error response", func(t *testing.T) {
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			w.WriteHeader(nethttp.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "Invalid credentials."}}`))
+		}))
+		defer server.Close()
+		c := NewClient("id", "key", WithBaseUrl(server.URL), WithCache(CacheSettings{Mode: CacheOff}), WithRetry(RetrySettings{}))
+
+		_, err := c.Apps(context.Background())
+
+		var apiErr *APIError
+		assert.Assert(t, errors.As(err, &apiErr))
+		assert.Equal(t, apiErr.StatusCode, nethttp.StatusUnauthorized)
+		assert.Equal(t, apiErr.Message, "Invalid credentials.")
+		assert.ErrorContains(t, err, "serverpilot api error (401): Invalid credentials.")
+		var statusErr *StatusError
+		assert.Assert(t, errors.As(err, &statusErr))
+		assert.Equal(t, statusErr.Url, server.URL+"/apps")
+	})
+}
+
+type stubApiClient struct {
+	responses map[string]string
+}
+
+func (c *stubApiClient) Get(ctx context.Context, url string) (string, error) {
+	return c.responses[url], nil
+}
+
+func (c *stubApiClient) Delete(ctx context.Context, url string) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,15 @@
+// Package serverpilot is a client for the ServerPilot API (https://serverpilot.io).
+//
+// All requests are made through a caching, rate-limited HTTP client, so repeated calls (for example fetching the
+// apps and then the servers they live on) don't hammer the API. The same client is available on its own in the
+// httpclient package, and its settings types are shared with it.
+//
+//	c := serverpilot.NewClient(clientId, apiKey, serverpilot.WithLogger(logger))
+//
+//	apps, err := c.AppServers(ctx)
+//
+// Every request takes a context, which can be used to cancel it or set a deadline.
+//
+// This package follows semantic versioning: exported identifiers will not be removed or changed incompatibly
+// within a major version.
+package serverpilot
@@ -0,0 +1,257 @@
+package serverpilot
+
+import (
+	"github.com/jfortunato/serverpilot-tools/internal/serverpilot"
+	"github.com/jfortunato/serverpilot-tools/pkg/httpclient"
+	"time"
+)
+
+var (
+	// ErrInvalidRuntime is returned when a runtime is not a PHP runtime, or its version can't be parsed.
+	ErrInvalidRuntime = serverpilot.ErrInvalidRuntime
+	// ErrInvalidVersion is returned when a version can't be parsed.
+	ErrInvalidVersion = serverpilot.ErrInvalidVersion
+	// ErrInvalidRuntimeRange is returned when a runtime range expression can't be parsed.
+	ErrInvalidRuntimeRange = serverpilot.ErrInvalidRuntimeRange
+	// ErrInvalidDateString is returned when a date is not in the format YYYY-MM-DD.
+	ErrInvalidDateString = serverpilot.ErrInvalidDateString
+	// ErrInvalidRelativeDate is returned when a relative date (such as "90d") can't be parsed.
+	ErrInvalidRelativeDate = serverpilot.ErrInvalidRelativeDate
+)
+
+// Cache modes for CacheSettings. The settings of the client are the ones of the httpclient package, so they can be
+// shared between the two.
+const (
+	// CacheOn reads responses from the cache, and caches new responses.
+	CacheOn = httpclient.CacheOn
+	// CacheRefresh always makes the request, and caches the new response.
+	CacheRefresh = httpclient.CacheRefresh
+	// CacheOff always makes the request, and never caches the response.
+	CacheOff = httpclient.CacheOff
+)
+
+// Hosts of the APIs requests are made to, for use with SetRateLimit.
+const (
+	ServerPilotHost = httpclient.ServerPilotHost
+	CloudflareHost  = httpclient.CloudflareHost
+)
+
+// DefaultBaseUrl is the base url of the ServerPilot API, which can be changed with WithBaseUrl.
+const DefaultBaseUrl = serverpilot.DefaultBaseUrl
+
+// RateLimit is the number of requests per second that can be made to a host. See httpclient.RateLimit.
+type RateLimit = httpclient.RateLimit
+
+// SetRateLimit changes the rate limit of a host. Rate limits are shared by every client, including the Cloudflare
+// client used by the inactive package.
+func SetRateLimit(host string, limit RateLimit) {
+	httpclient.SetRateLimit(host, limit)
+}
+
+// RetrySettings configure how failed requests are retried. See httpclient.RetrySettings.
+type RetrySettings = httpclient.RetrySettings
+
+// CacheSettings configure how API responses are cached. See httpclient.CacheSettings.
+type CacheSettings = httpclient.CacheSettings
+
+// StatusError is returned when an API responds with a status other than 2xx or 304. It is wrapped by APIError.
+type StatusError = httpclient.StatusError
+
+// APIError is returned when the ServerPilot API responds with an error. Use errors.As to get the status code and the
+// message sent by the API.
+type APIError struct {
+	StatusCode int
+	Message    string
+	msg        string
+	err        *StatusError
+}
+
+func (e *APIError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the StatusError of the response.
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// Credentials are used to authenticate with the ServerPilot API.
+type Credentials struct {
+	ClientId string
+	ApiKey   string
+}
+
+// App is a ServerPilot app.
+type App struct {
+	Id          string      `json:"id"`
+	Name        string      `json:"name"`
+	Serverid    string      `json:"serverid"`
+	Sysuserid   string      `json:"sysuserid"`
+	Runtime     Runtime     `json:"runtime"`
+	Domains     []string    `json:"domains"`
+	Datecreated DateCreated `json:"datecreated"`
+}
+
+// Server is a server managed by ServerPilot.
+type Server struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Ipaddress is the address the server last connected from, which is an IPv6 address for IPv6-only servers. The
+	// API only reports this one address, so the IPv6 address of a dual-stack server isn't known.
+	Ipaddress   string      `json:"lastaddress"`
+	Datecreated DateCreated `json:"datecreated"`
+}
+
+// IPv4 returns the server's IPv4 address, or an empty string if it doesn't have one.
+func (s Server) IPv4() string {
+	return serverpilot.Server{Ipaddress: s.Ipaddress}.IPv4()
+}
+
+// IPv6 returns the server's IPv6 address, or an empty string if it doesn't have one (or it isn't known).
+func (s Server) IPv6() string {
+	return serverpilot.Server{Ipaddress: s.Ipaddress}.IPv6()
+}
+
+// Sysuser is a system user on a server. Every app belongs to a sysuser.
+type Sysuser struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Serverid string `json:"serverid"`
+}
+
+// Database is a MySQL database that belongs to an app.
+type Database struct {
+	Id       string       `json:"id"`
+	Name     string       `json:"name"`
+	Appid    string       `json:"appid"`
+	Serverid string       `json:"serverid"`
+	User     DatabaseUser `json:"user"`
+}
+
+// DatabaseUser is the user that has access to a database.
+type DatabaseUser struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// AppServer is an app along with the server it lives on.
+type AppServer struct {
+	App
+	Server Server
+	// Account is the name of the profile the app was fetched with. It is empty when only one account is used.
+	Account string
+}
+
+// Runtime is the language runtime of an app, such as "php8.2".
+type Runtime string
+
+// Version parses the numeric version out of the runtime. Only PHP runtimes are supported.
+func (r Runtime) Version() (Version, error) {
+	v, err := serverpilot.Runtime(r).Version()
+	return Version(v), err
+}
+
+// Version is a comparable runtime version. Missing components are treated as 0, so "8.2" is 8.2.0.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// Compare returns -1 if v is lower than o, 1 if it is higher, and 0 if they are equal.
+func (v Version) Compare(o Version) int {
+	return serverpilot.Version(v).Compare(serverpilot.Version(o))
+}
+
+func (v Version) String() string {
+	return serverpilot.Version(v).String()
+}
+
+// RuntimeRange is a set of version constraints, such as ">=7.4 <8.1" or "8.x". Create one with ParseRuntimeRange or
+// RuntimeRangeFromBounds. The zero value matches every version.
+type RuntimeRange struct {
+	r serverpilot.RuntimeRange
+}
+
+// Contains reports whether the version satisfies the range.
+func (r RuntimeRange) Contains(v Version) bool {
+	return r.r.Contains(serverpilot.Version(v))
+}
+
+// Intersect returns a range that only matches versions matched by both ranges.
+func (r RuntimeRange) Intersect(o RuntimeRange) RuntimeRange {
+	return RuntimeRange{r.r.Intersect(o.r)}
+}
+
+// DateCreated is the unix timestamp a resource was created at.
+type DateCreated int64
+
+// String formats the date as YYYY-MM-DD.
+func (d DateCreated) String() string {
+	return serverpilot.DateCreated(d).String()
+}
+
+// ParseVersion parses a version such as "8", "8.2" or "8.2.1".
+func ParseVersion(s string) (Version, error) {
+	v, err := serverpilot.ParseVersion(s)
+	return Version(v), err
+}
+
+// ParseRuntimeRange parses a range expression such as ">=7.4 <8.1 || 8.3.x".
+func ParseRuntimeRange(expr string) (RuntimeRange, error) {
+	r, err := serverpilot.ParseRuntimeRange(expr)
+	return RuntimeRange{r}, err
+}
+
+// RuntimeRangeFromBounds creates an inclusive range from a minimum and maximum runtime, either of which may be empty.
+func RuntimeRangeFromBounds(min, max Runtime) (RuntimeRange, error) {
+	r, err := serverpilot.RuntimeRangeFromBounds(serverpilot.Runtime(min), serverpilot.Runtime(max))
+	return RuntimeRange{r}, err
+}
+
+// DateCreatedFromDate parses a date in the format YYYY-MM-DD.
+func DateCreatedFromDate(date string) (DateCreated, error) {
+	d, err := serverpilot.DateCreatedFromDate(date)
+	return DateCreated(d), err
+}
+
+// DateCreatedFromRelative converts a relative age such as "90d" or "2y" into the date that long before now.
+func DateCreatedFromRelative(age string, now time.Time) (DateCreated, error) {
+	d, err := serverpilot.DateCreatedFromRelative(age, now)
+	return DateCreated(d), err
+}
+
+// The API is decoded into the internal types, which are converted into the exported ones here so that changes to
+// the internal types never change the exported ones.
+
+func appFrom(a serverpilot.App) App {
+	return App{
+		Id:          a.Id,
+		Name:        a.Name,
+		Serverid:    a.Serverid,
+		Sysuserid:   a.Sysuserid,
+		Runtime:     Runtime(a.Runtime),
+		Domains:     a.Domains,
+		Datecreated: DateCreated(a.Datecreated),
+	}
+}
+
+func serverFrom(s serverpilot.Server) Server {
+	return Server{Id: s.Id, Name: s.Name, Ipaddress: s.Ipaddress, Datecreated: DateCreated(s.Datecreated)}
+}
+
+func databaseFrom(d serverpilot.Database) Database {
+	return Database{Id: d.Id, Name: d.Name, Appid: d.Appid, Serverid: d.Serverid, User: DatabaseUser(d.User)}
+}
+
+// convertAll converts every item of a slice, keeping a nil slice nil.
+func convertAll[T, U any](items []T, f func(T) U) []U {
+	if items == nil {
+		return nil
+	}
+	results := make([]U, len(items))
+	for i, item := range items {
+		results[i] = f(item)
+	}
+	return results
+}